@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -13,13 +14,23 @@ import (
 	"time"
 
 	"gitlab.angkas.com/avengers/microservice/incentive-service/config"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/fakejob"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/achievement"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/kafka"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/penalty"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/reward"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/tier"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/logging"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/open_loyalty"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/server"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/storage/postgres"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/storage/redis"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/talon_one"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/telemetry"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
 )
@@ -27,12 +38,21 @@ import (
 const (
 	modeServer = "server"
 	modeWorker = "worker"
+	modeReplay = "replay"
+)
+
+const (
+	listenerKafka  = "kafka"
+	listenerMemory = "memory"
+	listenerFile   = "file"
 )
 
 type App struct {
 	config   *config.Config
 	server   *server.Server
 	worker   *worker.Worker
+	admin    *server.Server
+	replayer *replayer
 	logger   *slog.Logger
 	version  server.Version
 	closerFn func() error
@@ -40,10 +60,10 @@ type App struct {
 
 func (a *App) Setup() error {
 	// Init PostgresClient
-	// postgresClient, err := postgres.NewClient(a.config.Postgres, a.logger)
-	// if err != nil {
-	// 	return fmt.Errorf("could not setup postgres: %s", err)
-	// }
+	postgresClient, err := postgres.NewClient(a.config.Postgres, a.logger)
+	if err != nil {
+		return fmt.Errorf("could not setup postgres: %s", err)
+	}
 
 	auth := &server.JWTAuth{NoVerify: true}
 	tsi := telemetry.NewServerInstrumentation(a.config.Telemetry.ServiceName)
@@ -79,7 +99,18 @@ func (a *App) Setup() error {
 		cacheService,
 		a.logger,
 	)
-	providerService := open_loyalty.NewProviderSerivce(*openLoyaltyClient, a.logger)
+	openLoyaltyService := open_loyalty.NewProviderSerivce(*openLoyaltyClient, cacheService, a.logger)
+
+	var providerService driver.ProviderService
+	switch a.config.LoyaltyProvider {
+	case "openloyalty":
+		providerService = openLoyaltyService
+	case "talonone":
+		talonOneClient := talon_one.NewTalonOneClient(&a.config.TalonOne, a.logger)
+		providerService = talon_one.NewProviderService(*talonOneClient, a.logger)
+	default:
+		return fmt.Errorf("unknown loyalty provider: %s", a.config.LoyaltyProvider)
+	}
 
 	//svc := foo.NewService(postgresClient, a.logger)
 	//service := telemetry.TraceFooService(svc, a.logger)
@@ -88,44 +119,138 @@ func (a *App) Setup() error {
 
 	leaderboardsvc := leaderboard.NewLeaderboardService(cacheService, driversvc, a.logger)
 
+	penaltysvc := penalty.NewService(a.config.Penalty, cacheService, driversvc, a.logger)
+
+	awardsvc := award.NewService(a.config.Award, leaderboardsvc, providerService, cacheService, a.logger)
+
+	rewardsvc := reward.NewService(a.config.Reward, postgresClient, leaderboardsvc, kafkaWriter, a.logger)
+
+	tiersvc := tier.NewService(a.config.Tier, postgresClient, cacheService, kafkaWriter, a.logger)
+
+	achievementsvc := achievement.NewService(a.config.Achievement, cacheService, leaderboardsvc, kafkaWriter, a.logger)
+	leaderboardsvc.OnTripScored(achievementsvc)
+
 	//Generate Fake Drivers
 	// driver := faker.GenerateFakeDrivers(15)
 	// for _, d := range driver {
 	// 	cacheService.RefreshLeaderboard(context.Background(), d)
 	// }
 
-	a.server = server.New(a.config.Server, driversvc, leaderboardsvc, auth, tsi, a.version, a.logger)
+	a.server = server.New(a.config.Server, driversvc, leaderboardsvc, driversvc, rewardsvc, tiersvc, achievementsvc, auth, tsi, a.version, a.logger)
 
-	//tiersvc.RefreshTier(context.Background())
+	schemaRegistry, err := trip.NewRegistry(trip.DefaultSchemas...)
+	if err != nil {
+		return fmt.Errorf("could not setup schema registry: %s", err)
+	}
+	tripDecoder := trip.NewDecoder(schemaRegistry)
 
-	// Check if we need to use same implementation.
-	// fj := fakejob.New(time.Second)
+	listener, err := a.jobListener(kafkaWriter)
+	if err != nil {
+		return fmt.Errorf("could not setup job listener: %s", err)
+	}
 
-	a.worker = worker.New(kafkaWriter, 1, a.logger)
+	a.worker = worker.New(listener, a.config.WorkerQueueSize, a.logger)
 	a.worker.Use(worker.LoggingMiddleware(a.logger), telemetry.TraceWorker)
-	a.worker.HandleFunc("trips", worker.ConsumeTripCompleted(leaderboardsvc))
-
-	// refreshTierWeekly, err := worker.NewSchedule(
-	// 	// a.conf.ConfigResetClock.Format(time.Kitchen),
-	// 	"",
-	// 	time.Hour*24,
-	// 	func(ctx context.Context) error { return tiersvc.RefreshTier(ctx) },
-	// )
-	// if err != nil {
-	// 	return fmt.Errorf("could not setup refreshing tier weekly: %s", err)
-	// }
-	// a.worker.SetSchedule(refreshTierWeekly)
+	capSource := redis.NewCapSource(redisClient, a.logger)
+	leaderboardsvc.CapTrips(worker.NewTripCapper(capSource, a.config.TripCaps, a.logger))
+	a.worker.HandleFunc(stream.TripTopic, worker.ConsumeTripCompleted(leaderboardsvc, tripDecoder))
+	a.worker.KeyBy(stream.TripTopic, worker.TripDriverKey(tripDecoder))
+	a.worker.HandleFunc(stream.DriverCancellationTopic, worker.ConsumeDriverPenalty(penaltysvc, penalty.KindCancellation))
+	a.worker.KeyBy(stream.DriverCancellationTopic, worker.PenaltyDriverKey)
+	a.worker.HandleFunc(stream.DriverComplaintTopic, worker.ConsumeDriverPenalty(penaltysvc, penalty.KindComplaint))
+	a.worker.KeyBy(stream.DriverComplaintTopic, worker.PenaltyDriverKey)
+	a.worker.HandleFunc(stream.DriverProfileTopic, worker.ConsumeDriverProfile(driversvc))
+	a.worker.SetLocker(redis.NewLocker(redisClient, a.logger), a.config.ScheduleLeaseTTL)
+	a.worker.SetRunRecorder(redis.NewScheduleRuns(redisClient, a.logger))
+
+	if a.config.LoyaltyProvider == "openloyalty" {
+		reconcileImports, err := worker.NewCronSchedule(a.config.OpenLoyalty.ImportReconcileSchedule, openLoyaltyService.ReconcileImports)
+		if err != nil {
+			return fmt.Errorf("could not setup reconciling loyalty imports: %s", err)
+		}
+		reconcileImports.Name = "reconcile-loyalty-imports"
+		a.worker.SetSchedule(reconcileImports)
+	}
+
+	if a.config.Award.Enabled() {
+		closePeriod, err := worker.NewCronSchedule(
+			fmt.Sprintf("CRON_TZ=%s %s", a.config.Award.Location, a.config.Award.Schedule),
+			awardsvc.CloseLastPeriod,
+		)
+		if err != nil {
+			return fmt.Errorf("could not setup closing award period: %s", err)
+		}
+		closePeriod.Name = "close-period"
+		a.worker.SetSchedule(closePeriod)
+	}
+
+	closeRewardPeriod, err := worker.NewCronSchedule(
+		fmt.Sprintf("CRON_TZ=%s %s", a.config.Reward.Location, a.config.Reward.Schedule),
+		rewardsvc.CloseLastPeriod,
+	)
+	if err != nil {
+		return fmt.Errorf("could not setup closing reward period: %s", err)
+	}
+	closeRewardPeriod.Name = "close-reward-period"
+	a.worker.SetSchedule(closeRewardPeriod)
+
+	publishPayouts, err := worker.NewCronSchedule(a.config.Reward.PayoutSchedule, rewardsvc.PublishPayouts)
+	if err != nil {
+		return fmt.Errorf("could not setup publishing reward payouts: %s", err)
+	}
+	publishPayouts.Name = "publish-reward-payouts"
+	a.worker.SetSchedule(publishPayouts)
+
+	monitorBudgets, err := worker.NewIntervalSchedule(a.config.Reward.BudgetInterval, rewardsvc.MonitorBudgets)
+	if err != nil {
+		return fmt.Errorf("could not setup monitoring campaign budgets: %s", err)
+	}
+	monitorBudgets.Name = "monitor-campaign-budgets"
+	a.worker.SetSchedule(monitorBudgets)
+
+	a.admin = server.NewAdmin(a.config.WorkerAdmin, a.worker, openLoyaltyService, rewardsvc, auth, tsi, a.version, a.logger)
+
+	a.replayer = &replayer{
+		kafka:   kafkaWriter,
+		trips:   leaderboardsvc,
+		driver:  driversvc,
+		decoder: tripDecoder,
+		logger:  a.logger,
+	}
+
+	refreshTierWeekly, err := worker.NewCronSchedule(
+		fmt.Sprintf("CRON_TZ=%s %s", a.config.Tier.Location, a.config.Tier.Schedule),
+		tiersvc.RefreshTiers,
+	)
+	if err != nil {
+		return fmt.Errorf("could not setup refreshing tier weekly: %s", err)
+	}
+	refreshTierWeekly.Name = "refresh-tier-weekly"
+	a.worker.SetSchedule(refreshTierWeekly)
+
+	closeAchievementPeriod, err := worker.NewCronSchedule(
+		fmt.Sprintf("CRON_TZ=%s %s", a.config.Achievement.Location, a.config.Achievement.Schedule),
+		achievementsvc.CloseLastPeriod,
+	)
+	if err != nil {
+		return fmt.Errorf("could not setup closing achievement period: %s", err)
+	}
+	closeAchievementPeriod.Name = "close-achievement-period"
+	a.worker.SetSchedule(closeAchievementPeriod)
 
 	a.closerFn = func() error {
-		// if err = postgresClient.Close(); err != nil {
-		// 	return fmt.Errorf("could not close postgres: %s", err)
-		// }
-		if err = cacheService.Client.Close(); err != nil {
+		if err = postgresClient.Close(); err != nil {
 			return fmt.Errorf("could not close postgres: %s", err)
 		}
+		if err = cacheService.Client.Close(); err != nil {
+			return fmt.Errorf("could not close redis: %s", err)
+		}
 		if err = a.server.Close(); err != nil {
 			return fmt.Errorf("could not close server: %s", err)
 		}
+		if err = a.admin.Close(); err != nil {
+			return fmt.Errorf("could not close admin server: %s", err)
+		}
 		return nil
 	}
 	return nil
@@ -136,7 +261,11 @@ func (a *App) Run(mode string) error {
 	case modeServer:
 		return appRunner(a.server)
 	case modeWorker:
-		return appRunner(a.worker)
+		return appRunner(runners{a.worker, a.admin})
+	case modeReplay:
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return a.replayer.Run(ctx, os.Args[2:])
 	default:
 		return fmt.Errorf("app mode not supported: %s", mode)
 	}
@@ -174,7 +303,7 @@ func main() {
 	}
 	defer func() {
 		if err = shutdown(context.Background()); err != nil {
-			log.Error("failed to shutdown TracerProvider: %w", err)
+			log.Error("failed to shutdown TracerProvider", "err", err)
 		}
 	}()
 	log = telemetry.TraceLogger(log)
@@ -195,6 +324,28 @@ func main() {
 	log.Info("app: exited!")
 }
 
+// jobListener returns the worker job listener base on config, memory and
+// file listeners runs the worker without kafka for local development.
+func (a *App) jobListener(kafkaClient *kafka.Client) (jobListener, error) {
+	switch strings.ToLower(a.config.WorkerListener) {
+	case listenerKafka:
+		return kafkaClient, nil
+	case listenerFile:
+		return fakejob.NewFile(a.config.FakeJob, a.logger), nil
+	case listenerMemory:
+		// Feeds the worker with generated trip events.
+		m := fakejob.NewMemory(a.logger)
+		m.Produce(stream.TripTopic, a.config.FakeJob.Delay, func() (string, []byte) {
+			e := trip.GenerateFakeTripEvent()
+			b, _ := json.Marshal(e)
+			return e.TripRequestID, b
+		})
+		return m, nil
+	default:
+		return nil, fmt.Errorf("worker listener not supported: %s", a.config.WorkerListener)
+	}
+}
+
 // appMode returns application mode base on arguments.
 func appMode() (string, error) {
 	if len(os.Args) < 2 {
@@ -224,6 +375,33 @@ type runner interface {
 	Stop() error
 }
 
+// runners runs all runners in order and stops them in the same order, the
+// last runner may block on Run.
+type runners []runner
+
+func (rr runners) Run() error {
+	for _, r := range rr {
+		if err := r.Run(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rr runners) Stop() error {
+	for _, r := range rr {
+		if err := r.Stop(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jobListener interface {
+	Listen(topics []string, q chan<- worker.Job) (stop func(), err error)
+	Close() error
+}
+
 // version data will set during build time using go build -ldflags.
 var vTag, vCommit, vBuilt string
 
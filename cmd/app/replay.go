@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/kafka"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/telemetry"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+const defaultReplayGroup = "incentive-service-replay"
+
+// tripWriter scores and reverses trips in the leaderboard.
+type tripWriter interface {
+	UpdateLeaderboard(ctx context.Context, trip trip.Event) error
+	ReverseTrip(ctx context.Context, trip trip.Event) error
+	RescoreTrip(ctx context.Context, trip trip.Event) error
+}
+
+// rescoreWriter scores trips again replacing the contribution of trips
+// already scored.
+type rescoreWriter struct {
+	tripWriter
+}
+
+func (w rescoreWriter) UpdateLeaderboard(ctx context.Context, t trip.Event) error {
+	return w.RescoreTrip(ctx, t)
+}
+
+// replayer replays trip events of a topic time range into the trip scoring
+// pipeline using the same handler and trip writer as the worker.
+type replayer struct {
+	kafka   *kafka.Client
+	trips   tripWriter
+	driver  *driver.Service
+	decoder *trip.Decoder
+	logger  *slog.Logger
+}
+
+// Run parses replay arguments and replays the topic.
+//
+//	app replay --from 72h --to 2024-05-04T00:00:00+08:00 --topic trips --dry-run
+//
+// Trips already scored are skipped unless --rescore is set.
+func (r *replayer) Run(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet(modeReplay, flag.ContinueOnError)
+	from := fs.String("from", "", "replay start time in RFC3339 or duration ago ex. 72h (required)")
+	to := fs.String("to", "", "replay end time in RFC3339 or duration ago, defaults to now")
+	topic := fs.String("topic", stream.TripTopic, "trip events topic to replay")
+	group := fs.String("group", defaultReplayGroup, "replay consumer group, must not be the worker group")
+	dryRun := fs.Bool("dry-run", false, "report score deltas without applying them")
+	rescore := fs.Bool("rescore", false, "score trips already scored again replacing their contribution")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dryRun && *rescore {
+		return fmt.Errorf("--rescore can't be used with --dry-run")
+	}
+
+	now := time.Now()
+	rc := kafka.ReplayConfig{Topic: *topic, GroupID: *group, To: now}
+	var err error
+	if rc.From, err = parseReplayTime(*from, now); err != nil {
+		return fmt.Errorf("invalid --from: %s", err)
+	}
+	if *to != "" {
+		if rc.To, err = parseReplayTime(*to, now); err != nil {
+			return fmt.Errorf("invalid --to: %s", err)
+		}
+	}
+
+	var writer interface {
+		UpdateLeaderboard(ctx context.Context, trip trip.Event) error
+		ReverseTrip(ctx context.Context, trip trip.Event) error
+	} = r.trips
+	dr := leaderboard.NewDryRun(r.driver)
+	switch {
+	case *dryRun:
+		writer = dr
+	case *rescore:
+		writer = rescoreWriter{r.trips}
+	}
+
+	handle := telemetry.TraceWorker(worker.ConsumeTripCompleted(writer, r.decoder))
+	res, err := r.kafka.Replay(ctx, rc, handle)
+	if err != nil {
+		return err
+	}
+	r.logger.Info("replay result", "messages", res.Messages, "failed", res.Failed, "dry_run", *dryRun, "rescore", *rescore)
+
+	if *dryRun {
+		return printScoreDeltas(dr.Deltas())
+	}
+	return nil
+}
+
+func printScoreDeltas(dd []leaderboard.ScoreDelta) error {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DRIVER\tZONE\tTRIPS\tEARNINGS\tBEFORE\tAFTER\tDELTA")
+	for _, d := range dd {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%.2f\t%.4f\t%.4f\t%+.4f\n",
+			d.DriverID, d.ServiceZone, d.Trips, d.Earnings, d.Before, d.After, d.Delta())
+	}
+	return tw.Flush()
+}
+
+// parseReplayTime parses RFC3339 time or duration before now.
+func parseReplayTime(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("time required")
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -2,16 +2,28 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/fakejob"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/achievement"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/kafka"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/penalty"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/reward"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/tier"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/logging"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/open_loyalty"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/server"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/storage/postgres"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/storage/redis"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/talon_one"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/telemetry"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
 )
 
 const DefaultFile = ".env"
@@ -20,12 +32,24 @@ const DefaultFile = ".env"
 type Config struct {
 	Server                       server.Config
 	WorkerQueueSize              int
+	WorkerListener               string
+	WorkerAdmin                  server.Config
+	ScheduleLeaseTTL             time.Duration
+	TripCaps                     worker.TripCaps
+	Penalty                      penalty.Config
+	Award                        award.Config
+	Reward                       reward.Config
+	Tier                         tier.Config
+	Achievement                  achievement.Config
+	FakeJob                      fakejob.Config
 	Logging                      logging.Config
 	Telemetry                    telemetry.Config
 	GoogleApplicationCredentials string
 	Postgres                     postgres.Config
 	Redis                        redis.Config
+	LoyaltyProvider              string
 	OpenLoyalty                  open_loyalty.Config
+	TalonOne                     talon_one.Config
 	KafkaWriter                  kafka.WriterConfig
 }
 
@@ -37,6 +61,32 @@ func Load(file string) (*Config, error) {
 
 	// Set Default Values for Kafka
 	viper.SetDefault("BUDGET_MONITORING_INTERVAL", 10)
+	viper.SetDefault("WORKER_LISTENER", "kafka")
+	viper.SetDefault("WORKER_REPLAY_DELAY", "1s")
+	viper.SetDefault("SCHEDULE_LEASE_TTL", "30s")
+	viper.SetDefault("LOYALTY_PROVIDER", "openloyalty")
+	viper.SetDefault("AWARD_TOP", 10)
+	viper.SetDefault("AWARD_SCHEDULE", "0 0 * * MON")
+	viper.SetDefault("AWARD_TIMEZONE", "Asia/Manila")
+	viper.SetDefault("REWARD_SCHEDULE", "0 0 * * MON")
+	viper.SetDefault("REWARD_TIMEZONE", "Asia/Manila")
+	viper.SetDefault("REWARD_PAYOUT_SCHEDULE", "* * * * *")
+	viper.SetDefault("BUDGET_ALERT_THRESHOLDS", "80,100")
+	viper.SetDefault("TIER_SILVER_SCORE", 1.5)
+	viper.SetDefault("TIER_GOLD_SCORE", 2.5)
+	viper.SetDefault("TIER_PLATINUM_SCORE", 3.5)
+	viper.SetDefault("TIER_SCHEDULE", "0 0 * * MON")
+	viper.SetDefault("TIER_TIMEZONE", "Asia/Manila")
+	viper.SetDefault("ACHIEVEMENT_SCHEDULE", "0 0 * * *")
+	viper.SetDefault("ACHIEVEMENT_TIMEZONE", "Asia/Manila")
+	viper.SetDefault("OPENLOYALTY_IMPORT_RECONCILE_SCHEDULE", "*/5 * * * *")
+	viper.SetDefault("OPENLOYALTY_MAX_RETRIES", 3)
+	viper.SetDefault("TALONONE_MAX_RETRIES", 3)
+	viper.SetDefault("TRIP_CAP_RESET_CLOCK", "12:00AM")
+	viper.SetDefault("TRIP_CAP_TIMEZONE", "Asia/Manila")
+	viper.SetDefault("PENALTY_CANCELLATION_POINTS", 0.25)
+	viper.SetDefault("PENALTY_COMPLAINT_POINTS", 1)
+	viper.SetDefault("PENALTY_COMPLAINT_INELIGIBLE", "72h")
 	viper.SetDefault("KAFKA_PRODUCER_BATCH_SIZE", 100000)
 	viper.SetDefault("KAFKA_PRODUCER_LINGER_MS", 10)
 	viper.SetDefault("KAFKA_PRODUCER_COMPRESSION_TYPE", "lz4")
@@ -47,13 +97,87 @@ func Load(file string) (*Config, error) {
 		return nil, err
 	}
 
+	capReset, err := time.Parse(time.Kitchen, viper.GetString("TRIP_CAP_RESET_CLOCK"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRIP_CAP_RESET_CLOCK: %s", err)
+	}
+	capLoc, err := time.LoadLocation(viper.GetString("TRIP_CAP_TIMEZONE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TRIP_CAP_TIMEZONE: %s", err)
+	}
+
+	awardLoc, err := time.LoadLocation(viper.GetString("AWARD_TIMEZONE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AWARD_TIMEZONE: %s", err)
+	}
+	rewardLoc, err := time.LoadLocation(viper.GetString("REWARD_TIMEZONE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid REWARD_TIMEZONE: %s", err)
+	}
+	achievementLoc, err := time.LoadLocation(viper.GetString("ACHIEVEMENT_TIMEZONE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACHIEVEMENT_TIMEZONE: %s", err)
+	}
+	tierLoc, err := time.LoadLocation(viper.GetString("TIER_TIMEZONE"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TIER_TIMEZONE: %s", err)
+	}
+	tierConf := tier.Config{
+		Silver:   viper.GetFloat64("TIER_SILVER_SCORE"),
+		Gold:     viper.GetFloat64("TIER_GOLD_SCORE"),
+		Platinum: viper.GetFloat64("TIER_PLATINUM_SCORE"),
+		Schedule: viper.GetString("TIER_SCHEDULE"),
+		Location: tierLoc,
+	}
+	if err := tierConf.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid TIER_*_SCORE: %s", err)
+	}
+	var budgetThresholds []int
+	for _, v := range strings.Split(viper.GetString("BUDGET_ALERT_THRESHOLDS"), ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid BUDGET_ALERT_THRESHOLDS: %q", v)
+		}
+		budgetThresholds = append(budgetThresholds, n)
+	}
+	olMaxRetries := viper.GetInt("OPENLOYALTY_MAX_RETRIES")
+	toMaxRetries := viper.GetInt("TALONONE_MAX_RETRIES")
+
 	c := &Config{
 		Server: server.Config{
-			Addr:         viper.GetString("SERVER_ADDR"),
+			Addr:                 viper.GetString("SERVER_ADDR"),
+			ReadTimeout:          viper.GetDuration("SERVER_READ_TIMEOUT"),
+			WriteTimeout:         viper.GetDuration("SERVER_WRITE_TIMEOUT"),
+			LoyaltyWebhookSecret: viper.GetString("OPENLOYALTY_WEBHOOK_SECRET"),
+		},
+		WorkerQueueSize: viper.GetInt("WORKER_QUEUE_SIZE"),
+		WorkerListener:  viper.GetString("WORKER_LISTENER"),
+		WorkerAdmin: server.Config{
+			Addr:         viper.GetString("WORKER_ADMIN_ADDR"),
 			ReadTimeout:  viper.GetDuration("SERVER_READ_TIMEOUT"),
 			WriteTimeout: viper.GetDuration("SERVER_WRITE_TIMEOUT"),
 		},
-		WorkerQueueSize: viper.GetInt("WORKER_QUEUE_SIZE"),
+		ScheduleLeaseTTL: viper.GetDuration("SCHEDULE_LEASE_TTL"),
+		TripCaps: worker.TripCaps{
+			MaxTrips:    viper.GetInt("TRIP_CAP_MAX_TRIPS"),
+			MaxEarnings: viper.GetFloat64("TRIP_CAP_MAX_EARNINGS"),
+			ResetClock:  capReset,
+			Location:    capLoc,
+		},
+		Penalty: penalty.Config{
+			Cancellation: penalty.Rule{
+				Points:     viper.GetFloat64("PENALTY_CANCELLATION_POINTS"),
+				Ineligible: viper.GetDuration("PENALTY_CANCELLATION_INELIGIBLE"),
+			},
+			Complaint: penalty.Rule{
+				Points:     viper.GetFloat64("PENALTY_COMPLAINT_POINTS"),
+				Ineligible: viper.GetDuration("PENALTY_COMPLAINT_INELIGIBLE"),
+			},
+		},
+		FakeJob: fakejob.Config{
+			File:  viper.GetString("WORKER_REPLAY_FILE"),
+			Delay: viper.GetDuration("WORKER_REPLAY_DELAY"),
+		},
 		Logging: logging.Config{
 			Level: viper.GetString("LOGGING_LEVEL"),
 		},
@@ -74,11 +198,48 @@ func Load(file string) (*Config, error) {
 			Password: viper.GetString("REDIS_PASSWORD"),
 			Port:     viper.GetString("REDIS_PORT"),
 		},
+		Award: award.Config{
+			Top:      viper.GetInt("AWARD_TOP"),
+			Points:   viper.GetFloat64("AWARD_POINTS"),
+			Scopes:   viper.GetStringSlice("AWARD_SCOPES"),
+			Schedule: viper.GetString("AWARD_SCHEDULE"),
+			Location: awardLoc,
+		},
+		Reward: reward.Config{
+			Schedule:         viper.GetString("REWARD_SCHEDULE"),
+			Location:         rewardLoc,
+			PayoutSchedule:   viper.GetString("REWARD_PAYOUT_SCHEDULE"),
+			BudgetInterval:   time.Duration(viper.GetInt("BUDGET_MONITORING_INTERVAL")) * time.Minute,
+			BudgetThresholds: budgetThresholds,
+		},
+		Tier: tierConf,
+		Achievement: achievement.Config{
+			Scopes:   viper.GetStringSlice("ACHIEVEMENT_SCOPES"),
+			Schedule: viper.GetString("ACHIEVEMENT_SCHEDULE"),
+			Location: achievementLoc,
+		},
+		LoyaltyProvider: viper.GetString("LOYALTY_PROVIDER"),
 		OpenLoyalty: open_loyalty.Config{
-			URL:      viper.GetString("OPENLOYALTY_URL"),
-			Username: viper.GetString("OPENLOYALTY_USERNAME"),
-			Password: viper.GetString("OPENLOYALTY_PASSWORD"),
-			StoreID:  viper.GetString("OPENLOYALTY_STORE_ID"),
+			URL:                     viper.GetString("OPENLOYALTY_URL"),
+			Username:                viper.GetString("OPENLOYALTY_USERNAME"),
+			Password:                viper.GetString("OPENLOYALTY_PASSWORD"),
+			StoreID:                 viper.GetString("OPENLOYALTY_STORE_ID"),
+			MaxRetries:              &olMaxRetries,
+			RetryBackoff:            viper.GetDuration("OPENLOYALTY_RETRY_BACKOFF"),
+			RetryMaxBackoff:         viper.GetDuration("OPENLOYALTY_RETRY_MAX_BACKOFF"),
+			BreakerThreshold:        viper.GetInt("OPENLOYALTY_BREAKER_THRESHOLD"),
+			BreakerCooldown:         viper.GetDuration("OPENLOYALTY_BREAKER_COOLDOWN"),
+			TokenRefreshBefore:      viper.GetDuration("OPENLOYALTY_TOKEN_REFRESH_BEFORE"),
+			ImportReconcileSchedule: viper.GetString("OPENLOYALTY_IMPORT_RECONCILE_SCHEDULE"),
+		},
+		TalonOne: talon_one.Config{
+			URL:              viper.GetString("TALONONE_URL"),
+			APIKey:           viper.GetString("TALONONE_API_KEY"),
+			MaxRetries:       &toMaxRetries,
+			RetryBackoff:     viper.GetDuration("TALONONE_RETRY_BACKOFF"),
+			RetryMaxBackoff:  viper.GetDuration("TALONONE_RETRY_MAX_BACKOFF"),
+			BreakerThreshold: viper.GetInt("TALONONE_BREAKER_THRESHOLD"),
+			BreakerCooldown:  viper.GetDuration("TALONONE_BREAKER_COOLDOWN"),
 		},
 		KafkaWriter: kafka.WriterConfig{
 			Servers:          viper.GetString("KAFKA_SERVERS"),
@@ -1,53 +1,157 @@
+// Package fakejob provides job listeners that runs the worker without a
+// message broker, for local development and tests.
 package fakejob
 
 import (
-	"fmt"
-	"log"
+	"errors"
+	"log/slog"
+	"sync"
 	"time"
 
 	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
 )
 
-type Job struct {
-	sleep time.Duration
+// ErrClosed is returned when publishing on a closed or stopped listener.
+var ErrClosed = errors.New("listener closed")
+
+// Config represents fake job listener config.
+type Config struct {
+	// File is the NDJSON file path of recorded jobs to replay.
+	File string
+	// Delay is the pause between replayed or generated jobs.
+	Delay time.Duration
 }
 
-func New(sleep time.Duration) *Job {
-	return &Job{sleep}
+// Memory is an in-memory job listener, jobs are published with Publish.
+type Memory struct {
+	mu        sync.Mutex
+	topics    map[string]bool
+	queue     chan<- worker.Job
+	committed map[string]int
+	offsets   map[string]int64
+	closed    bool
+
+	quit    chan struct{}
+	pending sync.WaitGroup
+	logger  *slog.Logger
 }
 
-func (j *Job) Listen(topics []string, q chan<- worker.Job, stop chan struct{}) error {
-	log.Println("fake subscribed to", topics)
+// NewMemory returns new instance of in-memory job listener.
+func NewMemory(logger *slog.Logger) *Memory {
+	return &Memory{
+		topics:    map[string]bool{},
+		committed: map[string]int{},
+		offsets:   map[string]int64{},
+		quit:      make(chan struct{}),
+		logger:    logger.With("pkg", "fakejob"),
+	}
+}
+
+// Listen subscribes to topics and publishes jobs to q.
+func (m *Memory) Listen(topics []string, q chan<- worker.Job) (stop func(), err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+	if m.queue != nil {
+		return nil, errors.New("listener already listening")
+	}
+
+	for _, t := range topics {
+		m.topics[t] = true
+	}
+	m.queue = q
+	m.logger.Info("listening", "topics", topics)
+	return func() { m.logger.Info("listener stopped") }, nil
+}
+
+// Publish sends job to the worker, jobs of un-subscribed topics are dropped.
+// It blocks until the job is queued or the listener is closed.
+func (m *Memory) Publish(topic, key string, payload []byte) error {
+	m.mu.Lock()
+	if m.closed || m.queue == nil {
+		m.mu.Unlock()
+		return ErrClosed
+	}
+	if !m.topics[topic] {
+		m.mu.Unlock()
+		m.logger.Debug("topic not subscribed", "topic", topic)
+		return nil
+	}
+	m.pending.Add(1)
+	q := m.queue
+	offset := m.offsets[topic]
+	m.offsets[topic]++
+	m.mu.Unlock()
+	defer m.pending.Done()
+
+	job := worker.Job{
+		Topic:   topic,
+		Key:     key,
+		Payload: payload,
+		Offset:  offset,
+		Done: func() error {
+			m.mu.Lock()
+			m.committed[topic]++
+			m.mu.Unlock()
+			return nil
+		},
+	}
+	select {
+	case q <- job:
+		return nil
+	case <-m.quit:
+		return ErrClosed
+	}
+}
 
+// Produce publishes generated jobs on topic every interval until closed,
+// without interval jobs are published as fast as the worker takes them.
+func (m *Memory) Produce(topic string, every time.Duration, gen func() (key string, payload []byte)) {
 	go func() {
-		var i int
+		var tick <-chan time.Time
+		if every > 0 {
+			t := time.NewTicker(every)
+			defer t.Stop()
+			tick = t.C
+		}
 		for {
-			select {
-			case <-stop:
-				log.Println("listener stopped")
-				return
-
-			default:
-				i++
-				log.Println("fake producing job...", i)
-				q <- worker.Job{
-					Topic:   "demo",
-					Payload: []byte(fmt.Sprintf(`{"faker": %d}`, i)),
-					Done: func() error {
-						log.Println("fake done!", i)
-						return nil
-					},
+			if tick != nil {
+				select {
+				case <-m.quit:
+					return
+				case <-tick:
 				}
-				log.Println("fake produced job", i)
-				time.Sleep(j.sleep)
+			}
+			key, payload := gen()
+			if err := m.Publish(topic, key, payload); err != nil {
+				return
 			}
 		}
 	}()
+}
 
-	return nil
+// Committed returns number of successfully processed jobs by topic.
+func (m *Memory) Committed(topic string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.committed[topic]
 }
 
-func (j *Job) Close() error {
-	log.Println("fake invoked close")
+// Close stops publishing and waits for blocked publishers to return so the
+// worker can safely close its job queue.
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	close(m.quit)
+	m.mu.Unlock()
+
+	m.pending.Wait()
+	m.logger.Info("listener closed")
 	return nil
 }
@@ -0,0 +1,207 @@
+package fakejob
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestMemory_worker(t *testing.T) {
+	l := NewMemory(discardLogger)
+	tw := &mockTripWriter{}
+	w := newTripWorker(l, tw)
+	if err := w.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var want []string
+	for i := 0; i < 20; i++ {
+		e := tripEvent(fmt.Sprintf("trip-%d", i), "driver-1", trip.StatusComplete)
+		want = append(want, e.Data.TripRequestID)
+		if err := l.Publish("trips", e.Data.TripRequestID, mustJSON(t, e)); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	// Invalid and non-complete events should not update leaderboard.
+	l.Publish("trips", "trip-invalid", mustJSON(t, tripEvent("trip-invalid", "", trip.StatusComplete)))
+	l.Publish("trips", "trip-accepted", mustJSON(t, tripEvent("trip-accepted", "driver-1", trip.StatusAccepted)))
+	l.Publish("other", "other-1", []byte(`{}`))
+
+	waitFor(t, func() bool { return l.Committed("trips") == 21 })
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	if err := l.Publish("trips", "late", []byte(`{}`)); err != ErrClosed {
+		t.Errorf("Publish() after close error = %v, want %v", err, ErrClosed)
+	}
+
+	if got := tw.tripIDs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("leaderboard updates = %v, want %v", got, want)
+	}
+}
+
+func TestMemory_Produce(t *testing.T) {
+	for _, every := range []time.Duration{time.Millisecond, 0, -time.Second} {
+		t.Run(every.String(), func(t *testing.T) {
+			l := NewMemory(discardLogger)
+			q := make(chan worker.Job)
+			if _, err := l.Listen([]string{"trips"}, q); err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+
+			n := 0
+			l.Produce("trips", every, func() (string, []byte) {
+				n++
+				return fmt.Sprintf("trip-%d", n), []byte(`{}`)
+			})
+			for i := 0; i < 3; i++ {
+				select {
+				case <-q:
+				case <-time.After(time.Second):
+					t.Fatalf("job %d not produced", i)
+				}
+			}
+			if err := l.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFile_worker(t *testing.T) {
+	var lines []string
+	for i, driverID := range []string{"driver-1", "driver-2", "driver-1"} {
+		e := tripEvent(fmt.Sprintf("trip-%d", i), driverID, trip.StatusComplete)
+		lines = append(lines, fmt.Sprintf(`{"topic":"trips","key":%q,"payload":%s}`, e.Data.TripRequestID, mustJSON(t, e)))
+	}
+	lines = append(lines, "", `{"topic":"unknown","payload":{}}`)
+	file := filepath.Join(t.TempDir(), "trips.ndjson")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := NewFile(Config{File: file}, discardLogger)
+	tw := &mockTripWriter{}
+	w := newTripWorker(l, tw)
+	if err := w.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	waitFor(t, func() bool { return l.Committed("trips") == 3 })
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	got := tw.tripIDs()
+	if len(got) != 3 {
+		t.Fatalf("leaderboard updates = %v, want 3 trips", got)
+	}
+	if d1 := tw.driverTrips("driver-1"); !reflect.DeepEqual(d1, []string{"trip-0", "trip-2"}) {
+		t.Errorf("driver-1 updates = %v, want in recorded order", d1)
+	}
+}
+
+func TestFile_missingFile(t *testing.T) {
+	l := NewFile(Config{File: filepath.Join(t.TempDir(), "missing.ndjson")}, discardLogger)
+	if _, err := l.Listen([]string{"trips"}, make(chan worker.Job)); err == nil {
+		t.Error("Listen() error = nil, want missing file error")
+	}
+}
+
+func newTripWorker(l interface {
+	Listen(topics []string, q chan<- worker.Job) (stop func(), err error)
+	Close() error
+}, tw *mockTripWriter) *worker.Worker {
+	dec := trip.NewDecoder(nil)
+	w := worker.New(l, 4, discardLogger)
+	w.Mode = worker.ModeConsumerOnly
+	w.HandleFunc("trips", worker.ConsumeTripCompleted(tw, dec))
+	w.KeyBy("trips", worker.TripDriverKey(dec))
+	return w
+}
+
+func tripEvent(id, driverID, status string) trip.Envelope {
+	e := trip.Envelope{
+		Type:       trip.EventType,
+		Version:    trip.VersionV1,
+		ID:         "evt-" + id,
+		OccurredAt: time.Now(),
+	}
+	e.Data.TripRequestID = id
+	e.Data.DriverID = driverID
+	e.Data.Status = status
+	e.Data.Pickup = trip.TripLocation{Latitude: "14.5995", Longitude: "120.9842"}
+	e.Data.Dropoff = trip.TripLocation{Latitude: "14.6760", Longitude: "121.0437"}
+	return e
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for jobs")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type mockTripWriter struct {
+	mu    sync.Mutex
+	trips []trip.Event
+}
+
+func (m *mockTripWriter) UpdateLeaderboard(ctx context.Context, e trip.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.trips = append(m.trips, e)
+	return nil
+}
+
+func (m *mockTripWriter) ReverseTrip(ctx context.Context, e trip.Event) error {
+	return nil
+}
+
+func (m *mockTripWriter) tripIDs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for _, e := range m.trips {
+		ids = append(ids, e.TripRequestID)
+	}
+	return ids
+}
+
+func (m *mockTripWriter) driverTrips(driverID string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ids []string
+	for _, e := range m.trips {
+		if e.DriverID == driverID {
+			ids = append(ids, e.TripRequestID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,96 @@
+package fakejob
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+// maxLineSize is the maximum size of a recorded job line.
+const maxLineSize = 1 << 20
+
+// record represents a recorded job line of the NDJSON file.
+//
+//	{"topic": "trips", "key": "trip-1", "payload": {...}}
+type record struct {
+	Topic   string          `json:"topic"`
+	Key     string          `json:"key"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// File is a job listener that replays recorded jobs from an NDJSON file.
+type File struct {
+	*Memory
+	path  string
+	delay time.Duration
+}
+
+// NewFile returns new instance of NDJSON file replay job listener.
+func NewFile(conf Config, logger *slog.Logger) *File {
+	return &File{
+		Memory: NewMemory(logger),
+		path:   conf.File,
+		delay:  conf.Delay,
+	}
+}
+
+// Listen subscribes to topics and starts replaying recorded jobs.
+func (f *File) Listen(topics []string, q chan<- worker.Job) (stop func(), err error) {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open replay file: %s", err)
+	}
+	if stop, err = f.Memory.Listen(topics, q); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	go func() {
+		defer file.Close()
+		n, err := f.replay(file)
+		if err != nil {
+			f.logger.Error("replay", "err", err, "file", f.path, "line", n)
+			return
+		}
+		f.logger.Info("replay finished", "file", f.path, "lines", n)
+	}()
+	return stop, nil
+}
+
+func (f *File) replay(file *os.File) (line int, err error) {
+	s := bufio.NewScanner(file)
+	s.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for s.Scan() {
+		line++
+		b := bytes.TrimSpace(s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		var r record
+		if err = json.Unmarshal(b, &r); err != nil {
+			return line, fmt.Errorf("json unmarshall: %s", err)
+		}
+		if r.Topic == "" || len(r.Payload) == 0 {
+			return line, fmt.Errorf("topic and payload required")
+		}
+		if err = f.Publish(r.Topic, r.Key, r.Payload); err != nil {
+			return line, err
+		}
+
+		if f.delay > 0 {
+			select {
+			case <-f.quit:
+				return line, ErrClosed
+			case <-time.After(f.delay):
+			}
+		}
+	}
+	return line, s.Err()
+}
@@ -0,0 +1,200 @@
+// Package httpretry retries requests of provider clients with backoff and
+// stops calling the provider with a circuit breaker.
+package httpretry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Client defaults, zero config values uses these.
+const (
+	DefaultMaxRetries       = 3
+	DefaultBackoff          = 200 * time.Millisecond
+	DefaultMaxBackoff       = 5 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without sending the request while the circuit
+// breaker is open after consecutive failures.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ErrNotSent wraps errors returned before sending the request, they are
+// neither retried nor counted by the breaker.
+var ErrNotSent = errors.New("request not sent")
+
+// ResponseError is implemented by errors of non 2xx responses.
+type ResponseError interface {
+	error
+	// Response returns the status code and Retry-After of the response.
+	Response() (status int, retryAfter time.Duration)
+}
+
+// Policy represents the retries of a request.
+type Policy struct {
+	// MaxRetries of 429, 5xx and transport errors after the first attempt.
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Idempotent requests are retried on all retryable errors, others only
+	// on 429 responses rejected before processing.
+	Idempotent bool
+	// OnRetry is called before waiting for the next attempt.
+	OnRetry func(attempt int, wait time.Duration, err error)
+}
+
+// Do calls send until it succeeds, fails with an error not retryable or the
+// retries run out. Failures are recorded in b, send is not called while b is
+// open. Nil b never opens.
+func Do(ctx context.Context, p Policy, b *Breaker, send func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := b.allow(); err != nil {
+			return fmt.Errorf("%w: %w", ErrNotSent, err)
+		}
+
+		err := send()
+		if errors.Is(err, ErrNotSent) {
+			b.release()
+			return err
+		}
+		retry := retryable(ctx, err)
+		b.record(retry)
+		if !p.Idempotent && !rateLimited(err) {
+			retry = false
+		}
+		if !retry || attempt >= p.MaxRetries {
+			return err
+		}
+
+		wait := p.backoff(attempt, err)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt+1, wait, err)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// backoff returns exponential backoff with full jitter, Retry-After of the
+// response is used when longer.
+func (p Policy) backoff(attempt int, err error) time.Duration {
+	wait := p.Backoff << attempt
+	if wait <= 0 || wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	wait = rand.N(wait) + 1
+
+	var respErr ResponseError
+	if errors.As(err, &respErr) {
+		if _, after := respErr.Response(); after > wait {
+			wait = min(after, p.MaxBackoff)
+		}
+	}
+	return wait
+}
+
+// Temporary reports whether the response status can be retried.
+func Temporary(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// rateLimited reports whether the request was rejected before processing.
+func rateLimited(err error) bool {
+	var respErr ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	status, _ := respErr.Response()
+	return status == http.StatusTooManyRequests
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var respErr ResponseError
+	if errors.As(err, &respErr) {
+		status, _ := respErr.Response()
+		return Temporary(status)
+	}
+	// Decoding errors are from the response, not the transport.
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return !errors.As(err, &syntaxErr) && !errors.As(err, &typeErr)
+}
+
+// Breaker stops calling the provider after threshold consecutive failures
+// until cooldown, then lets one request through to close it.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// NewBreaker returns breaker opening after threshold consecutive failures.
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{threshold: threshold, cooldown: cooldown, Now: time.Now}
+}
+
+func (b *Breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+	if b.trial || b.Now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	b.trial = true
+	return nil
+}
+
+// release ends the trial of a request that was not sent.
+func (b *Breaker) release() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *Breaker) record(failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = b.Now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,91 @@
+package achievement
+
+import "time"
+
+// Badges unlocked by driver milestones.
+const (
+	BadgeFirst100Trips   = "first_100_trips"
+	BadgeStreak7Days     = "streak_7_days"
+	BadgeTop10ThreeWeeks = "top_10_three_weeks"
+	BadgeTopEarnerOfDay  = "top_earner_of_day"
+)
+
+// Milestones of the badges.
+const (
+	tripsMilestone = 100
+	streakDays     = 7
+	topRank        = 10
+	topWeeksInARow = 3
+)
+
+// Streaks of days with trips and weeks in the top 10.
+const (
+	streakTrips    = "trips"
+	streakTopWeeks = "top_weeks"
+)
+
+const dayFormat = "2006-01-02"
+
+// Config represents the leaderboards and schedule closing days and weeks.
+type Config struct {
+	// Scopes are the leaderboards of the top 10 and top earner badges.
+	Scopes []string
+	// Schedule is the cron expression closing the previous day, the week is
+	// closed after Sunday.
+	Schedule string
+	Location *time.Location
+}
+
+func (c Config) scopes() []string {
+	if len(c.Scopes) == 0 {
+		// Leaderboard of drivers without service zone.
+		return []string{""}
+	}
+	return c.Scopes
+}
+
+// periodicBadges are unlocked once per scope and period, other badges once
+// per driver.
+var periodicBadges = map[string]bool{
+	BadgeTop10ThreeWeeks: true,
+	BadgeTopEarnerOfDay:  true,
+}
+
+// Badge represents a milestone unlocked by a driver.
+type Badge struct {
+	ID       string `json:"id"`
+	DriverID string `json:"driver_id"`
+	// Zone and Period are the leaderboard scope and day or week the badge
+	// was earned in, empty for trip milestones.
+	Zone       string    `json:"zone,omitempty"`
+	Period     string    `json:"period,omitempty"`
+	UnlockedAt time.Time `json:"unlocked_at"`
+}
+
+// Key identifies the badge among the badges of the driver.
+func (b Badge) Key() string {
+	if !periodicBadges[b.ID] {
+		return b.ID
+	}
+	return b.ID + ":" + b.Zone + ":" + b.Period
+}
+
+// Streak represents consecutive days or weeks, Last is the latest day or
+// week counted.
+type Streak struct {
+	Last  string `json:"last"`
+	Count int    `json:"count"`
+}
+
+// next returns the streak counting current, prev is the day or week before
+// current. Days and weeks before the latest counted are already counted.
+func (s Streak) next(current, prev string) Streak {
+	switch {
+	case current <= s.Last:
+		return s
+	case prev == s.Last:
+		return Streak{Last: current, Count: s.Count + 1}
+	default:
+		return Streak{Last: current, Count: 1}
+	}
+}
@@ -0,0 +1,222 @@
+package achievement
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+)
+
+// Service represents achievement service.
+type Service struct {
+	config      Config
+	cache       CacheRepository
+	leaderboard LeaderboardService
+	events      EventWriter
+	logger      *slog.Logger
+}
+
+type LeaderboardService interface {
+	GetLeaderboard(ctx context.Context, scope string) (leaderboard.Leaderboard, error)
+}
+
+// CacheRepository manages redis or any nosql storage operations
+type CacheRepository interface {
+	// UnlockBadge saves the badge, returns false when the driver already
+	// unlocked it.
+	UnlockBadge(ctx context.Context, b Badge) (bool, error)
+	// HasBadge reports whether the driver unlocked the badge of the key.
+	HasBadge(ctx context.Context, driverID, key string) (bool, error)
+	ListBadges(ctx context.Context, driverID string) ([]Badge, error)
+	GetStreak(ctx context.Context, name, driverID string) (Streak, error)
+	SetStreak(ctx context.Context, name, driverID string, s Streak) error
+	AddDailyEarnings(ctx context.Context, zone, day, driverID string, earnings float64) error
+	// TopDailyEarner returns the driver with the highest earnings of the
+	// zone in the day, ok is false without trips.
+	TopDailyEarner(ctx context.Context, zone, day string) (driverID string, ok bool, err error)
+}
+
+// EventWriter publishes events to the stream.
+type EventWriter interface {
+	// ProduceSync returns after the event is delivered.
+	ProduceSync(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error
+}
+
+// NewService returns new achievement service.
+func NewService(conf Config, c CacheRepository, lb LeaderboardService, w EventWriter, l *slog.Logger) *Service {
+	return &Service{
+		config:      conf,
+		cache:       c,
+		leaderboard: lb,
+		events:      w,
+		logger:      l,
+	}
+}
+
+func (s Service) location() *time.Location {
+	if s.config.Location == nil {
+		return time.Local
+	}
+	return s.config.Location
+}
+
+// TripScored counts the capped trip earnings in the daily earnings and the
+// trip streak of the driver on the day the trip completed and unlocks the
+// trip and streak badges.
+func (s Service) TripScored(ctx context.Context, d driver.Driver, t trip.Event) error {
+	day := t.CompletedAt().In(s.location())
+	tripDay := day.Format(dayFormat)
+	if err := s.cache.AddDailyEarnings(ctx, d.ServiceZone, tripDay, d.DriverID, t.Price.DriverEarnings); err != nil {
+		return err
+	}
+
+	streak, err := s.cache.GetStreak(ctx, streakTrips, d.DriverID)
+	if err != nil {
+		return err
+	}
+	if next := streak.next(tripDay, day.AddDate(0, 0, -1).Format(dayFormat)); next != streak {
+		if err = s.cache.SetStreak(ctx, streakTrips, d.DriverID, next); err != nil {
+			return err
+		}
+		streak = next
+	}
+
+	now := time.Now()
+	var errs []error
+	if d.NumberOfCompletedTrips >= tripsMilestone {
+		errs = append(errs, s.unlock(ctx, Badge{ID: BadgeFirst100Trips, DriverID: d.DriverID, UnlockedAt: now}))
+	}
+	if streak.Count >= streakDays {
+		errs = append(errs, s.unlock(ctx, Badge{ID: BadgeStreak7Days, DriverID: d.DriverID, Period: streak.Last, UnlockedAt: now}))
+	}
+	return errors.Join(errs...)
+}
+
+// TripReversed subtracts the contribution earnings from the daily earnings of
+// the day the trip completed.
+func (s Service) TripReversed(ctx context.Context, d driver.Driver, c driver.Contribution) error {
+	at := c.TripAt
+	if at.IsZero() {
+		at = c.AppliedAt
+	}
+	return s.cache.AddDailyEarnings(ctx, d.ServiceZone, at.In(s.location()).Format(dayFormat), d.DriverID, -c.Earnings)
+}
+
+// CloseLastPeriod closes the day before today and the week when the day is
+// Sunday.
+func (s Service) CloseLastPeriod(ctx context.Context) error {
+	yesterday := time.Now().In(s.location()).AddDate(0, 0, -1)
+	err := s.CloseDay(ctx, yesterday)
+	if yesterday.Weekday() == time.Sunday {
+		err = errors.Join(err, s.CloseWeek(ctx, yesterday))
+	}
+	return err
+}
+
+// CloseDay unlocks the top earner badge of the highest earner of each scope
+// in the day.
+func (s Service) CloseDay(ctx context.Context, day time.Time) error {
+	d := day.Format(dayFormat)
+	var errs []error
+	for _, scope := range s.config.scopes() {
+		driverID, ok, err := s.cache.TopDailyEarner(ctx, scope, d)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		errs = append(errs, s.unlock(ctx, Badge{
+			ID:         BadgeTopEarnerOfDay,
+			DriverID:   driverID,
+			Zone:       scope,
+			Period:     d,
+			UnlockedAt: time.Now(),
+		}))
+	}
+	return errors.Join(errs...)
+}
+
+// CloseWeek counts the top 10 drivers of each scope in the week of t and
+// unlocks the badge of drivers in the top 10 three weeks in a row. Closing
+// the week again does not count it twice.
+func (s Service) CloseWeek(ctx context.Context, t time.Time) error {
+	week, prev := award.Period(t), award.Period(t.AddDate(0, 0, -7))
+	var errs []error
+	for _, scope := range s.config.scopes() {
+		lb, err := s.leaderboard.GetLeaderboard(ctx, scope)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for i, e := range lb.Drivers {
+			if i >= topRank {
+				break
+			}
+			if err = s.topWeek(ctx, e.DriverID, scope, week, prev); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s Service) topWeek(ctx context.Context, driverID, scope, week, prev string) error {
+	streak, err := s.cache.GetStreak(ctx, streakTopWeeks, driverID)
+	if err != nil {
+		return err
+	}
+	next := streak.next(week, prev)
+	if next == streak {
+		return nil
+	}
+	if err = s.cache.SetStreak(ctx, streakTopWeeks, driverID, next); err != nil {
+		return err
+	}
+	if next.Count < topWeeksInARow {
+		return nil
+	}
+	return s.unlock(ctx, Badge{ID: BadgeTop10ThreeWeeks, DriverID: driverID, Zone: scope, Period: week, UnlockedAt: time.Now()})
+}
+
+// unlock publishes the badge before saving it, badges not delivered are not
+// unlocked and published again when earned again.
+func (s Service) unlock(ctx context.Context, b Badge) error {
+	ok, err := s.cache.HasBadge(ctx, b.DriverID, b.Key())
+	if err != nil || ok {
+		return err
+	}
+
+	v, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+	if err = s.events.ProduceSync(ctx, []byte(b.DriverID), v, stream.BadgeTopic); err != nil {
+		return fmt.Errorf("failed to publish badge %s of %s: %w", b.ID, b.DriverID, err)
+	}
+	if ok, err = s.cache.UnlockBadge(ctx, b); err != nil || !ok {
+		return err
+	}
+	s.logger.InfoContext(ctx, "badge unlocked", "driver_id", b.DriverID, "badge", b.ID, "zone", b.Zone, "period", b.Period)
+	return nil
+}
+
+// ListBadges returns the badges of the driver, oldest first.
+func (s Service) ListBadges(ctx context.Context, driverID string) ([]Badge, error) {
+	list, err := s.cache.ListBadges(ctx, driverID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UnlockedAt.Before(list[j].UnlockedAt) })
+	return list, nil
+}
@@ -0,0 +1,287 @@
+package achievement
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+)
+
+func TestService_TripScored(t *testing.T) {
+	today := time.Now().In(time.UTC)
+	day := func(days int) string {
+		return today.AddDate(0, 0, days).Format(dayFormat)
+	}
+	tests := []struct {
+		name   string
+		trips  int
+		streak Streak
+		// tripDays is the day the trip completed relative to today.
+		tripDays   int
+		want       []string
+		wantStreak Streak
+	}{
+		{"no milestone", 12, Streak{}, 0, nil, Streak{Last: day(0), Count: 1}},
+		{"100 trips", 100, Streak{}, 0, []string{BadgeFirst100Trips}, Streak{Last: day(0), Count: 1}},
+		{"seventh day in a row", 40, Streak{Last: day(-1), Count: 6}, 0, []string{BadgeStreak7Days}, Streak{Last: day(0), Count: 7}},
+		{"streak broken", 40, Streak{Last: day(-2), Count: 6}, 0, nil, Streak{Last: day(0), Count: 1}},
+		{"late trip counts its day", 40, Streak{Last: day(-3), Count: 6}, -2, []string{BadgeStreak7Days}, Streak{Last: day(-2), Count: 7}},
+		{"late trip of a counted day", 40, Streak{Last: day(0), Count: 3}, -2, nil, Streak{Last: day(0), Count: 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newMockCache()
+			cache.streaks[streakTrips+":driver-1"] = tt.streak
+			var published []string
+			s := NewService(Config{Location: time.UTC}, cache, nil, &mockEventWriter{&published}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			d := driver.Driver{DriverID: "driver-1", ServiceZone: "MNL", NumberOfCompletedTrips: tt.trips}
+			tr := trip.Event{DriverID: "driver-1", UpdatedAt: today.AddDate(0, 0, tt.tripDays), Price: trip.PriceInfo{DriverEarnings: 150}}
+			if err := s.TripScored(context.Background(), d, tr); err != nil {
+				t.Fatalf("TripScored() error = %v", err)
+			}
+			// Scoring another trip the same day unlocks nothing again.
+			if err := s.TripScored(context.Background(), d, tr); err != nil {
+				t.Fatalf("TripScored() again error = %v", err)
+			}
+			if !reflect.DeepEqual(published, tt.want) {
+				t.Errorf("published = %v, want %v", published, tt.want)
+			}
+			if got := cache.earnings["MNL:"+day(tt.tripDays)]["driver-1"]; got != 300 {
+				t.Errorf("daily earnings = %v, want 300", got)
+			}
+			if got := cache.streaks[streakTrips+":driver-1"]; got != tt.wantStreak {
+				t.Errorf("streak = %+v, want %+v", got, tt.wantStreak)
+			}
+		})
+	}
+}
+
+func TestService_UnlockRetriesFailedPublish(t *testing.T) {
+	cache := newMockCache()
+	var published []string
+	events := &failingEventWriter{mockEventWriter{&published}, errors.New("message timed out")}
+	s := NewService(Config{Location: time.UTC}, cache, nil, events, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	d := driver.Driver{DriverID: "driver-1", ServiceZone: "MNL", NumberOfCompletedTrips: 100}
+	tr := trip.Event{DriverID: "driver-1", Price: trip.PriceInfo{DriverEarnings: 150}}
+	if err := s.TripScored(context.Background(), d, tr); !errors.Is(err, events.err) {
+		t.Fatalf("TripScored() error = %v, want %v", err, events.err)
+	}
+	if badges, _ := cache.ListBadges(context.Background(), "driver-1"); len(badges) > 0 {
+		t.Fatalf("badges = %v, want none after failed publish", badges)
+	}
+
+	// The badge is published and unlocked with the next trip, then only once.
+	events.err = nil
+	for i := 0; i < 2; i++ {
+		if err := s.TripScored(context.Background(), d, tr); err != nil {
+			t.Fatalf("TripScored() error = %v", err)
+		}
+	}
+	if want := []string{BadgeFirst100Trips}; !reflect.DeepEqual(published, want) {
+		t.Errorf("published = %v, want %v", published, want)
+	}
+	if _, ok := cache.badges["driver-1"][BadgeFirst100Trips]; !ok {
+		t.Error("badge not unlocked")
+	}
+}
+
+func TestService_TripReversed(t *testing.T) {
+	cache := newMockCache()
+	s := NewService(Config{Location: time.UTC}, cache, nil, &mockEventWriter{new([]string)}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// The trip completed before midnight is scored and reversed the next day.
+	completed := time.Date(2024, 5, 12, 23, 50, 0, 0, time.UTC)
+	d := driver.Driver{DriverID: "driver-1", ServiceZone: "MNL"}
+	tr := trip.Event{TripRequestID: "trip-1", DriverID: "driver-1", UpdatedAt: completed, Price: trip.PriceInfo{DriverEarnings: 150}}
+	for _, other := range []trip.Event{tr, {DriverID: "driver-1", UpdatedAt: completed, Price: trip.PriceInfo{DriverEarnings: 80}}} {
+		if err := s.TripScored(context.Background(), d, other); err != nil {
+			t.Fatalf("TripScored() error = %v", err)
+		}
+	}
+	c := driver.NewContribution(tr, completed.Add(30*time.Minute))
+	if err := s.TripReversed(context.Background(), d, c); err != nil {
+		t.Fatalf("TripReversed() error = %v", err)
+	}
+
+	if got := cache.earnings["MNL:2024-05-12"]["driver-1"]; got != 80 {
+		t.Errorf("daily earnings = %v, want 80", got)
+	}
+	if got, ok := cache.earnings["MNL:2024-05-13"]; ok {
+		t.Errorf("next day earnings = %v, want none", got)
+	}
+}
+
+func TestService_CloseDay(t *testing.T) {
+	cache := newMockCache()
+	var published []string
+	s := NewService(Config{Scopes: []string{"MNL"}}, cache, nil, &mockEventWriter{&published}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	monday := time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC)
+	for _, d := range []time.Time{monday, monday, monday.AddDate(0, 0, 1)} {
+		cache.earnings["MNL:"+d.Format(dayFormat)] = map[string]float64{"driver-1": 500, "driver-2": 300}
+		if err := s.CloseDay(context.Background(), d); err != nil {
+			t.Fatalf("CloseDay() error = %v", err)
+		}
+	}
+
+	// The badge is unlocked each day, closing a day again unlocks nothing.
+	want := []string{BadgeTopEarnerOfDay, BadgeTopEarnerOfDay}
+	if !reflect.DeepEqual(published, want) {
+		t.Errorf("published = %v, want %v", published, want)
+	}
+	if got := len(cache.badges["driver-1"]); got != 2 {
+		t.Errorf("badges = %+v, want 2 days", cache.badges["driver-1"])
+	}
+}
+
+func TestService_CloseWeek(t *testing.T) {
+	entry := func(id string) leaderboard.Entry {
+		return leaderboard.Entry{Driver: driver.Driver{DriverID: id}}
+	}
+	lb := &mockLeaderboard{board: leaderboard.Leaderboard{Drivers: []leaderboard.Entry{entry("driver-1"), entry("driver-2")}}}
+	cache := newMockCache()
+	var published []string
+	s := NewService(Config{}, cache, lb, &mockEventWriter{&published}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	sunday := time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC)
+	for i, week := range []time.Time{sunday.AddDate(0, 0, -14), sunday.AddDate(0, 0, -7), sunday.AddDate(0, 0, -7), sunday} {
+		if i == 3 {
+			// driver-2 left the top 10 in the third week.
+			lb.board.Drivers = lb.board.Drivers[:1]
+		}
+		if err := s.CloseWeek(context.Background(), week); err != nil {
+			t.Fatalf("CloseWeek() error = %v", err)
+		}
+	}
+
+	want := []string{BadgeTop10ThreeWeeks}
+	if !reflect.DeepEqual(published, want) {
+		t.Errorf("published = %v, want %v", published, want)
+	}
+	if _, ok := cache.badges["driver-1"][BadgeTop10ThreeWeeks+"::2024-W19"]; !ok {
+		t.Errorf("badges = %+v, want driver-1 in 2024-W19", cache.badges)
+	}
+	if got := cache.streaks[streakTopWeeks+":driver-2"]; got.Count != 2 {
+		t.Errorf("driver-2 streak = %+v, want 2 weeks", got)
+	}
+}
+
+type mockLeaderboard struct {
+	board leaderboard.Leaderboard
+}
+
+func (m *mockLeaderboard) GetLeaderboard(ctx context.Context, scope string) (leaderboard.Leaderboard, error) {
+	return m.board, nil
+}
+
+type mockCache struct {
+	badges   map[string]map[string]Badge
+	streaks  map[string]Streak
+	earnings map[string]map[string]float64
+}
+
+func newMockCache() *mockCache {
+	return &mockCache{
+		badges:   map[string]map[string]Badge{},
+		streaks:  map[string]Streak{},
+		earnings: map[string]map[string]float64{},
+	}
+}
+
+func (m *mockCache) UnlockBadge(ctx context.Context, b Badge) (bool, error) {
+	if _, ok := m.badges[b.DriverID][b.Key()]; ok {
+		return false, nil
+	}
+	if m.badges[b.DriverID] == nil {
+		m.badges[b.DriverID] = map[string]Badge{}
+	}
+	m.badges[b.DriverID][b.Key()] = b
+	return true, nil
+}
+
+func (m *mockCache) HasBadge(ctx context.Context, driverID, key string) (bool, error) {
+	_, ok := m.badges[driverID][key]
+	return ok, nil
+}
+
+func (m *mockCache) ListBadges(ctx context.Context, driverID string) ([]Badge, error) {
+	var list []Badge
+	for _, b := range m.badges[driverID] {
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+func (m *mockCache) GetStreak(ctx context.Context, name, driverID string) (Streak, error) {
+	return m.streaks[name+":"+driverID], nil
+}
+
+func (m *mockCache) SetStreak(ctx context.Context, name, driverID string, s Streak) error {
+	m.streaks[name+":"+driverID] = s
+	return nil
+}
+
+func (m *mockCache) AddDailyEarnings(ctx context.Context, zone, day, driverID string, earnings float64) error {
+	key := zone + ":" + day
+	if m.earnings[key] == nil {
+		m.earnings[key] = map[string]float64{}
+	}
+	m.earnings[key][driverID] += earnings
+	return nil
+}
+
+func (m *mockCache) TopDailyEarner(ctx context.Context, zone, day string) (string, bool, error) {
+	var ids []string
+	for id := range m.earnings[zone+":"+day] {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return "", false, nil
+	}
+	e := m.earnings[zone+":"+day]
+	sort.Slice(ids, func(i, j int) bool { return e[ids[i]] > e[ids[j]] })
+	return ids[0], true, nil
+}
+
+// failingEventWriter fails to publish while err is set.
+type failingEventWriter struct {
+	mockEventWriter
+	err error
+}
+
+func (m *failingEventWriter) ProduceSync(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error {
+	if m.err != nil {
+		return m.err
+	}
+	return m.mockEventWriter.ProduceSync(ctx, key, value, optionalTopic...)
+}
+
+type mockEventWriter struct {
+	published *[]string
+}
+
+func (m *mockEventWriter) ProduceSync(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error {
+	if optionalTopic[0] != stream.BadgeTopic {
+		return nil
+	}
+	var b struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(value, &b); err != nil {
+		return err
+	}
+	*m.published = append(*m.published, b.ID)
+	return nil
+}
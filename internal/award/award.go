@@ -0,0 +1,54 @@
+package award
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+// Award statuses.
+const (
+	StatusPending = "pending"
+	StatusAwarded = "awarded"
+	// StatusUnknown is an award the provider may have applied, it is kept
+	// claimed and needs checking in the provider.
+	StatusUnknown = "unknown"
+)
+
+// Config represents points awarded to the top drivers when a period closes.
+type Config struct {
+	// Top drivers of each scope are awarded.
+	Top int
+	// Points of the first rank, rank r receives Points * (Top-r+1) / Top.
+	Points float64
+	// Scopes are the leaderboards awarded.
+	Scopes []string
+	// Schedule is the cron expression closing the period.
+	Schedule string
+	Location *time.Location
+}
+
+// Enabled reports whether awards are configured.
+func (c Config) Enabled() bool {
+	return c.Top > 0 && c.Points > 0
+}
+
+func (c Config) points(rank int) float64 {
+	return math.Round(c.Points * float64(c.Top-rank+1) / float64(c.Top))
+}
+
+// Record represents an award and its status.
+type Record struct {
+	driver.Award
+	Status    string    `json:"status"`
+	Err       string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Period returns the ISO week of t, ex. "2024-W19".
+func Period(t time.Time) string {
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
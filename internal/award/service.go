@@ -0,0 +1,155 @@
+package award
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+)
+
+// Service represents award service.
+type Service struct {
+	config      Config
+	leaderboard LeaderboardService
+	provider    Provider
+	cache       CacheRepository
+	logger      *slog.Logger
+}
+
+type LeaderboardService interface {
+	GetLeaderboard(ctx context.Context, scope string) (leaderboard.Leaderboard, error)
+}
+
+// Provider manages loyalty points of the external service.
+type Provider interface {
+	AwardPoints(ctx context.Context, a driver.Award) error
+}
+
+// CacheRepository manages redis or any nosql storage operations
+type CacheRepository interface {
+	// ClaimAward saves the pending award, returns false when the award key
+	// is already claimed.
+	ClaimAward(ctx context.Context, r Record) (bool, error)
+	SaveAward(ctx context.Context, r Record) error
+	ReleaseAward(ctx context.Context, key string) error
+	// FreezeStandings saves the ranked drivers of the period scope unless
+	// frozen, returns the frozen drivers.
+	FreezeStandings(ctx context.Context, period, scope string, driverIDs []string) ([]string, error)
+}
+
+// NewService returns new award service.
+func NewService(conf Config, lb LeaderboardService, p Provider, c CacheRepository, l *slog.Logger) *Service {
+	return &Service{
+		config:      conf,
+		leaderboard: lb,
+		provider:    p,
+		cache:       c,
+		logger:      l,
+	}
+}
+
+// CloseLastPeriod closes the period before today.
+func (s Service) CloseLastPeriod(ctx context.Context) error {
+	loc := s.config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	return s.ClosePeriod(ctx, Period(time.Now().In(loc).AddDate(0, 0, -1)))
+}
+
+// ClosePeriod awards points to the top drivers of each scope by rank. The
+// top drivers are frozen the first time the period closes and awards are
+// claimed per driver per period, closing the period again only awards the
+// frozen drivers not awarded yet.
+func (s Service) ClosePeriod(ctx context.Context, period string) error {
+	if !s.config.Enabled() {
+		return nil
+	}
+
+	scopes := s.config.Scopes
+	if len(scopes) == 0 {
+		// Leaderboard of drivers without service zone.
+		scopes = []string{""}
+	}
+
+	var errs []error
+	for _, scope := range scopes {
+		standings, err := s.freeze(ctx, period, scope)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for i, driverID := range standings {
+			rank := i + 1
+			err = s.award(ctx, driver.Award{
+				Key:      driver.AwardKey(period, driverID),
+				DriverID: driverID,
+				Period:   period,
+				Scope:    scope,
+				Rank:     rank,
+				Points:   s.config.points(rank),
+			})
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// freeze returns the top drivers of the period scope frozen from the live
+// leaderboard when the period first closes.
+func (s Service) freeze(ctx context.Context, period, scope string) ([]string, error) {
+	lb, err := s.leaderboard.GetLeaderboard(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	var top []string
+	for i, e := range lb.Drivers {
+		if i >= s.config.Top {
+			break
+		}
+		top = append(top, e.DriverID)
+	}
+	// An empty leaderboard is not frozen to award when the period closes
+	// again.
+	if len(top) == 0 {
+		return nil, nil
+	}
+	return s.cache.FreezeStandings(ctx, period, scope, top)
+}
+
+func (s Service) award(ctx context.Context, a driver.Award) error {
+	claimed, err := s.cache.ClaimAward(ctx, Record{Award: a, Status: StatusPending, UpdatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		s.logger.InfoContext(ctx, "award already claimed", "award_key", a.Key)
+		return nil
+	}
+
+	err = s.provider.AwardPoints(ctx, a)
+	if err == nil {
+		return s.cache.SaveAward(ctx, Record{Award: a, Status: StatusAwarded, UpdatedAt: time.Now()})
+	}
+
+	// Rejected awards are released to award on the next close.
+	if errors.Is(err, driver.ErrAwardRejected) {
+		if rerr := s.cache.ReleaseAward(ctx, a.Key); rerr != nil {
+			return errors.Join(err, rerr)
+		}
+		return err
+	}
+
+	// The provider may have applied the award, it is kept claimed to never
+	// award twice.
+	s.logger.ErrorContext(ctx, "award may be applied, check the provider", "award_key", a.Key, "err", err)
+	if serr := s.cache.SaveAward(ctx, Record{Award: a, Status: StatusUnknown, Err: err.Error(), UpdatedAt: time.Now()}); serr != nil {
+		return errors.Join(err, serr)
+	}
+	return err
+}
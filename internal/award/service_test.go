@@ -0,0 +1,146 @@
+package award
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+)
+
+func TestService_ClosePeriod(t *testing.T) {
+	lb := leaderboard.Leaderboard{Drivers: []leaderboard.Entry{
+		{Driver: driver.Driver{DriverID: "driver-1"}},
+		{Driver: driver.Driver{DriverID: "driver-2"}},
+		{Driver: driver.Driver{DriverID: "driver-3"}},
+	}}
+	conf := Config{Top: 2, Points: 100, Scopes: []string{"MNL"}}
+
+	tests := []struct {
+		name string
+		// errs are the provider errors by driver of the first close.
+		errs        map[string]error
+		wantFirst   []string
+		wantSecond  []string
+		wantStatus  map[string]string
+		wantErrOnce bool
+	}{
+		{
+			"awards top drivers once",
+			nil,
+			[]string{"driver-1:100", "driver-2:50"},
+			nil,
+			map[string]string{"driver-1": StatusAwarded, "driver-2": StatusAwarded},
+			false,
+		},
+		{
+			"rejected award is retried",
+			map[string]error{"driver-2": fmt.Errorf("member not found: %w", driver.ErrAwardRejected)},
+			[]string{"driver-1:100", "driver-2:50"},
+			[]string{"driver-2:50"},
+			map[string]string{"driver-1": StatusAwarded, "driver-2": StatusAwarded},
+			true,
+		},
+		{
+			"award with unknown outcome is not retried",
+			map[string]error{"driver-2": errors.New("timeout")},
+			[]string{"driver-1:100", "driver-2:50"},
+			nil,
+			map[string]string{"driver-1": StatusAwarded, "driver-2": StatusUnknown},
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var awarded []string
+			errs := tt.errs
+			provider := &mockProvider{AwardPointsFn: func(ctx context.Context, a driver.Award) error {
+				if a.Key != driver.AwardKey("2024-W19", a.DriverID) || a.Scope != "MNL" {
+					t.Errorf("award = %+v", a)
+				}
+				awarded = append(awarded, fmt.Sprintf("%s:%v", a.DriverID, a.Points))
+				return errs[a.DriverID]
+			}}
+			cache := &mockCacheRepository{records: map[string]Record{}, standings: map[string][]string{}}
+			live := &mockLeaderboard{lb}
+			s := NewService(conf, live, provider, cache, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			err := s.ClosePeriod(context.Background(), "2024-W19")
+			if (err != nil) != tt.wantErrOnce {
+				t.Fatalf("ClosePeriod() error = %v, wantErr %v", err, tt.wantErrOnce)
+			}
+			if !reflect.DeepEqual(awarded, tt.wantFirst) {
+				t.Errorf("first close awarded %v, want %v", awarded, tt.wantFirst)
+			}
+
+			// The period closes again with the standings frozen by the first
+			// close.
+			live.lb = leaderboard.Leaderboard{Drivers: []leaderboard.Entry{lb.Drivers[2], lb.Drivers[0], lb.Drivers[1]}}
+			awarded, errs = nil, nil
+			if err = s.ClosePeriod(context.Background(), "2024-W19"); err != nil {
+				t.Fatalf("ClosePeriod() again error = %v", err)
+			}
+			if !reflect.DeepEqual(awarded, tt.wantSecond) {
+				t.Errorf("second close awarded %v, want %v", awarded, tt.wantSecond)
+			}
+			for id, status := range tt.wantStatus {
+				if got := cache.records[driver.AwardKey("2024-W19", id)].Status; got != status {
+					t.Errorf("%s award status = %s, want %s", id, got, status)
+				}
+			}
+		})
+	}
+}
+
+type mockLeaderboard struct {
+	lb leaderboard.Leaderboard
+}
+
+func (m *mockLeaderboard) GetLeaderboard(ctx context.Context, scope string) (leaderboard.Leaderboard, error) {
+	return m.lb, nil
+}
+
+type mockProvider struct {
+	AwardPointsFn func(ctx context.Context, a driver.Award) error
+}
+
+func (m *mockProvider) AwardPoints(ctx context.Context, a driver.Award) error {
+	return m.AwardPointsFn(ctx, a)
+}
+
+type mockCacheRepository struct {
+	records   map[string]Record
+	standings map[string][]string
+}
+
+func (m *mockCacheRepository) FreezeStandings(ctx context.Context, period, scope string, driverIDs []string) ([]string, error) {
+	key := period + ":" + scope
+	if frozen, ok := m.standings[key]; ok {
+		return frozen, nil
+	}
+	m.standings[key] = driverIDs
+	return driverIDs, nil
+}
+
+func (m *mockCacheRepository) ClaimAward(ctx context.Context, r Record) (bool, error) {
+	if _, ok := m.records[r.Key]; ok {
+		return false, nil
+	}
+	m.records[r.Key] = r
+	return true, nil
+}
+
+func (m *mockCacheRepository) SaveAward(ctx context.Context, r Record) error {
+	m.records[r.Key] = r
+	return nil
+}
+
+func (m *mockCacheRepository) ReleaseAward(ctx context.Context, key string) error {
+	delete(m.records, key)
+	return nil
+}
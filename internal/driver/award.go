@@ -0,0 +1,28 @@
+package driver
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAwardRejected wraps provider errors of awards that are known to be not
+// applied, other errors may have been applied and are not retried.
+var ErrAwardRejected = errors.New("award rejected")
+
+// Award represents loyalty points granted to a driver for the leaderboard
+// rank in a period.
+type Award struct {
+	// Key identifies the award per driver per period, providers and award
+	// history use it to never award twice.
+	Key      string  `json:"key"`
+	DriverID string  `json:"driver_id"`
+	Period   string  `json:"period"`
+	Scope    string  `json:"scope"`
+	Rank     int     `json:"rank"`
+	Points   float64 `json:"points"`
+}
+
+// AwardKey returns the award key of the driver in the period.
+func AwardKey(period, driverID string) string {
+	return fmt.Sprintf("%s:%s", period, driverID)
+}
@@ -0,0 +1,63 @@
+package driver
+
+import (
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+)
+
+// Contribution represents the earnings and trips a completed trip added to
+// the driver rating, kept to reverse them exactly.
+type Contribution struct {
+	TripRequestID string  `json:"trip_request_id"`
+	DriverID      string  `json:"driver_id"`
+	Earnings      float64 `json:"earnings"`
+	Trips         int     `json:"trips"`
+	// TripAt is when the trip completed, zero for contributions saved
+	// before it was kept.
+	TripAt    time.Time `json:"trip_at"`
+	AppliedAt time.Time `json:"applied_at"`
+	// ReversedStatus is the trip status that reversed the contribution.
+	ReversedStatus string     `json:"reversed_status,omitempty"`
+	ReversedAt     *time.Time `json:"reversed_at,omitempty"`
+}
+
+// NewContribution returns contribution of the completed trip.
+func NewContribution(t trip.Event, now time.Time) Contribution {
+	return Contribution{
+		TripRequestID: t.TripRequestID,
+		DriverID:      t.DriverID,
+		Earnings:      t.Price.DriverEarnings,
+		Trips:         1,
+		TripAt:        t.CompletedAt(),
+		AppliedAt:     now,
+	}
+}
+
+func (c Contribution) Reversed() bool {
+	return c.ReversedAt != nil
+}
+
+// Reverse returns the contribution flagged as reversed by the trip status.
+func (c Contribution) Reverse(status string, now time.Time) Contribution {
+	c.ReversedStatus = status
+	c.ReversedAt = &now
+	return c
+}
+
+// ReverseContribution returns the driver with the contribution earnings and
+// trips subtracted and rating recomputed.
+func (d Driver) ReverseContribution(c Contribution, highestNetEarnings float64) Driver {
+	nd := d
+	nd.NetIncome = max(d.NetIncome-c.Earnings, 0)
+	nd.NumberOfCompletedTrips = max(d.NumberOfCompletedTrips-c.Trips, 0)
+	nd.Rating = Rating{
+		RFM: RFM{
+			Recency:   nd.CalculateRecency(),
+			Frequency: nd.CalculateFrequency(),
+			Monetary:  nd.CalculateMonetary(highestNetEarnings),
+		},
+	}
+	nd.Rating.Average = nd.CalculateAverage()
+	return nd
+}
@@ -0,0 +1,143 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrLoyaltyUnsupported is returned by providers without member balances.
+var ErrLoyaltyUnsupported = errors.New("loyalty balance not supported by provider")
+
+// Loyalty cache ttl, stale accounts are served when the provider fails.
+const (
+	loyaltyTTL      = time.Minute
+	loyaltyStaleTTL = 24 * time.Hour
+	loyaltyTimeout  = time.Second
+	// loyaltyEventTTL keeps delivered event ids longer than provider
+	// redelivery.
+	loyaltyEventTTL = 7 * 24 * time.Hour
+	// maxLoyaltyEvents is the number of latest events kept per driver.
+	maxLoyaltyEvents = 20
+)
+
+// Loyalty event types sent by the loyalty provider.
+const (
+	LoyaltyEventRewardRedeemed = "reward_redeemed"
+	LoyaltyEventTierChanged    = "tier_changed"
+)
+
+// Loyalty represents the driver member account in the loyalty provider.
+type Loyalty struct {
+	MemberID string  `json:"member_id"`
+	Tier     string  `json:"tier,omitempty"`
+	Points   float64 `json:"points"`
+	// LockedPoints are earned but not yet spendable.
+	LockedPoints float64  `json:"locked_points"`
+	Rewards      []Reward `json:"rewards"`
+	// Events are the latest redemptions and tier changes, newest first.
+	Events    []LoyaltyEvent `json:"events,omitempty"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	// Stale is set when the provider failed and the cached account is older
+	// than the cache ttl.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Reward represents a reward the driver can redeem.
+type Reward struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	CostInPoints float64 `json:"cost_in_points"`
+}
+
+// LoyaltyEvent represents a change of the driver member account made in the
+// loyalty provider.
+type LoyaltyEvent struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	DriverID string `json:"driver_id"`
+	// Tier is the new tier of tier changes.
+	Tier string `json:"tier,omitempty"`
+	// Reward and Points are the redeemed reward and points spent.
+	Reward     *Reward   `json:"reward,omitempty"`
+	Points     float64   `json:"points,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// GetLoyalty returns the driver loyalty account, cached for loyaltyTTL. The
+// provider is called with loyaltyTimeout and the last cached account is
+// returned as stale when it fails.
+func (s Service) GetLoyalty(ctx context.Context, driverID string) (Loyalty, error) {
+	cached, ok, err := s.cache.GetLoyalty(ctx, driverID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get cached loyalty", "driver_id", driverID, "error", err)
+	}
+	if ok && time.Since(cached.UpdatedAt) < loyaltyTTL {
+		return s.withLoyaltyEvents(ctx, driverID, cached), nil
+	}
+
+	pctx, cancel := context.WithTimeout(ctx, loyaltyTimeout)
+	defer cancel()
+	l, err := s.provider.GetLoyalty(pctx, driverID)
+	if err != nil {
+		if ok && !errors.Is(err, ErrLoyaltyUnsupported) {
+			s.logger.WarnContext(ctx, "serving stale loyalty", "driver_id", driverID, "error", err)
+			cached.Stale = true
+			return s.withLoyaltyEvents(ctx, driverID, cached), nil
+		}
+		return Loyalty{}, err
+	}
+
+	l.UpdatedAt = time.Now()
+	if err = s.cache.SetLoyalty(ctx, driverID, l, loyaltyStaleTTL); err != nil {
+		s.logger.WarnContext(ctx, "failed to cache loyalty", "driver_id", driverID, "error", err)
+	}
+	return s.withLoyaltyEvents(ctx, driverID, l), nil
+}
+
+func (s Service) withLoyaltyEvents(ctx context.Context, driverID string, l Loyalty) Loyalty {
+	events, err := s.cache.ListLoyaltyEvents(ctx, driverID, maxLoyaltyEvents)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to list loyalty events", "driver_id", driverID, "error", err)
+	}
+	l.Events = events
+	return l
+}
+
+// ApplyLoyaltyEvent stores the event and updates the cached loyalty account
+// of the driver. Events are applied once by id, ok is false for events
+// already applied.
+func (s Service) ApplyLoyaltyEvent(ctx context.Context, e LoyaltyEvent) (ok bool, err error) {
+	ok, err = s.cache.ClaimLoyaltyEvent(ctx, e.ID, loyaltyEventTTL)
+	if err != nil || !ok {
+		return false, err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		// Released for the provider to redeliver the event.
+		if rerr := s.cache.ReleaseLoyaltyEvent(ctx, e.ID); rerr != nil {
+			s.logger.ErrorContext(ctx, "failed to release loyalty event", "event_id", e.ID, "error", rerr)
+		}
+	}()
+
+	if err = s.cache.AddLoyaltyEvent(ctx, e, maxLoyaltyEvents); err != nil {
+		return false, err
+	}
+
+	l, cached, err := s.cache.GetLoyalty(ctx, e.DriverID)
+	if err != nil || !cached {
+		return err == nil, err
+	}
+	switch e.Type {
+	case LoyaltyEventTierChanged:
+		l.Tier = e.Tier
+	case LoyaltyEventRewardRedeemed:
+		l.Points -= e.Points
+	}
+	if err = s.cache.SetLoyalty(ctx, e.DriverID, l, loyaltyStaleTTL); err != nil {
+		return false, err
+	}
+	return true, nil
+}
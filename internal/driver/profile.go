@@ -0,0 +1,47 @@
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Driver profile statuses.
+const (
+	ProfileActive      = "active"
+	ProfileSuspended   = "suspended"
+	ProfileDeactivated = "deactivated"
+)
+
+// Profile represents driver details from the driver profile topic, it is
+// stored separately from the rating so updates do not affect scores.
+type Profile struct {
+	DriverID  string    `json:"driver_id"`
+	Name      string    `json:"name"`
+	AvatarURL string    `json:"avatar_url"`
+	Plate     string    `json:"plate"`
+	Hub       string    `json:"hub"`
+	JoinedAt  time.Time `json:"joined_at"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks profile required fields.
+func (p Profile) Validate() error {
+	if strings.TrimSpace(p.DriverID) == "" {
+		return fmt.Errorf("invalid driver profile: driver_id required")
+	}
+	return nil
+}
+
+// DisplayName returns the public name with first name and last name
+// initial, ex. "Juan Dela Cruz" -> "Juan C.".
+func (p Profile) DisplayName() string {
+	names := strings.Fields(p.Name)
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	r, _ := utf8.DecodeRuneInString(names[len(names)-1])
+	return fmt.Sprintf("%s %s.", names[0], strings.ToUpper(string(r)))
+}
@@ -23,11 +23,26 @@ type CacheRepository interface {
 	GetDriverRating(ctx context.Context, id string) (driver string, err error)
 	SetDriverRating(ctx context.Context, driver Driver) (err error)
 	CheckHighestNetEarnings(ctx context.Context, netEarning float64, serviceZone string) (highestNetEarnings float64, err error)
+	GetHighestNetEarnings(ctx context.Context, serviceZone string) (highestNetEarnings float64, err error)
+	GetTripContribution(ctx context.Context, tripRequestID string) (c Contribution, ok bool, err error)
+	SetTripContribution(ctx context.Context, c Contribution) (err error)
+	GetDriverProfile(ctx context.Context, id string) (p Profile, ok bool, err error)
+	SetDriverProfile(ctx context.Context, p Profile) (err error)
+	GetLoyalty(ctx context.Context, driverID string) (l Loyalty, ok bool, err error)
+	SetLoyalty(ctx context.Context, driverID string, l Loyalty, ttl time.Duration) (err error)
+	ClaimLoyaltyEvent(ctx context.Context, id string, ttl time.Duration) (ok bool, err error)
+	ReleaseLoyaltyEvent(ctx context.Context, id string) (err error)
+	// AddLoyaltyEvent keeps the latest max events, events are kept once by id
+	// so events redelivered after a failed apply are not listed twice.
+	AddLoyaltyEvent(ctx context.Context, e LoyaltyEvent, max int) (err error)
+	ListLoyaltyEvents(ctx context.Context, driverID string, limit int) (events []LoyaltyEvent, err error)
 }
 
 // providerService manages external service operations
 type ProviderService interface {
 	ImportDriverRating(ctx context.Context, list []Driver) (err error)
+	AwardPoints(ctx context.Context, a Award) (err error)
+	GetLoyalty(ctx context.Context, driverID string) (l Loyalty, err error)
 }
 
 // NewService returns new tier service.
@@ -82,50 +97,93 @@ func (s Service) GetDriver(ctx context.Context, driverID string) (Driver, error)
 }
 
 func (s Service) UpdateUserRating(ctx context.Context, trip trip.Event) (Driver, error) {
-	driverID := trip.DriverID
-	newDriver := Driver{}
+	driver, err := s.GetDriver(ctx, trip.DriverID)
+	if err != nil {
+		return Driver{}, err
+	}
 
-	// Get the tier from the cache
-	c, err := s.cache.GetDriverRating(ctx, driverID)
+	// Check Highest Net Earnings for the service zone, if yes replae
+	newNetIncome := trip.Price.DriverEarnings + driver.NetIncome
+	netEarnings, err := s.cache.CheckHighestNetEarnings(ctx, newNetIncome, driver.ServiceZone)
 	if err != nil {
-		return newDriver, err
+		return Driver{}, err
 	}
 
-	var driver Driver
-	if c != "nodata" {
-		// Convert the tier to a Driver struct from the cache
-		err = json.Unmarshal([]byte(c), &driver)
-		if err != nil {
-			return newDriver, err
-		}
+	return driver.ApplyTrip(trip, netEarnings, time.Now()), nil
+}
+
+// PreviewUserRating returns the driver rating after applying the trip
+// without updating the highest net earnings of the service zone.
+func (s Service) PreviewUserRating(ctx context.Context, driver Driver, trip trip.Event) (Driver, error) {
+	netEarnings, err := s.cache.GetHighestNetEarnings(ctx, driver.ServiceZone)
+	if err != nil {
+		return Driver{}, err
 	}
 
-	// Update the driver rating
 	newNetIncome := trip.Price.DriverEarnings + driver.NetIncome
+	if newNetIncome > netEarnings {
+		netEarnings = newNetIncome
+	}
+	return driver.ApplyTrip(trip, netEarnings, time.Now()), nil
+}
 
-	// Check Highest Net Earnings for the service zone, if yes replae
-	netEarnings, err := s.cache.CheckHighestNetEarnings(ctx, newNetIncome, driver.ServiceZone)
+// GetTripContribution returns the score contribution of the trip, ok is false
+// when the trip has not been scored.
+func (s Service) GetTripContribution(ctx context.Context, tripRequestID string) (Contribution, bool, error) {
+	return s.cache.GetTripContribution(ctx, tripRequestID)
+}
+
+func (s Service) SaveTripContribution(ctx context.Context, c Contribution) error {
+	return s.cache.SetTripContribution(ctx, c)
+}
+
+// ReverseUserRating returns the driver rating with the trip contribution
+// subtracted.
+func (s Service) ReverseUserRating(ctx context.Context, c Contribution) (Driver, error) {
+	driver, err := s.GetDriver(ctx, c.DriverID)
 	if err != nil {
-		return newDriver, err
+		return Driver{}, err
 	}
+	return s.PreviewReverseRating(ctx, driver, c)
+}
 
-	newDriver = Driver{
-		DriverID:                     driverID,
-		LastCompletedTripDate:        time.Now(),
-		NetIncome:                    newNetIncome,
-		NumberOfCompletedTrips:       driver.NumberOfCompletedTrips + 1,
-		UniqueDateWithCompletedTrips: driver.UniqueDateWithCompletedTrips,
-		ServiceZone:                  driver.ServiceZone,
-		Rating: Rating{
-			RFM: RFM{
-				Recency:   driver.CalculateRecency(),
-				Frequency: driver.CalculateFrequency(),
-				Monetary:  driver.CalculateMonetary(netEarnings),
-			},
-		},
+// RescoreUserRating returns the driver rating with the trip contribution
+// replaced by the trip.
+func (s Service) RescoreUserRating(ctx context.Context, c Contribution, trip trip.Event) (Driver, error) {
+	driver, err := s.ReverseUserRating(ctx, c)
+	if err != nil {
+		return Driver{}, err
+	}
+
+	newNetIncome := trip.Price.DriverEarnings + driver.NetIncome
+	netEarnings, err := s.cache.CheckHighestNetEarnings(ctx, newNetIncome, driver.ServiceZone)
+	if err != nil {
+		return Driver{}, err
 	}
+	return driver.ApplyTrip(trip, netEarnings, time.Now()), nil
+}
 
-	newDriver.Rating.Average = newDriver.CalculateAverage()
+// PreviewReverseRating returns the driver rating with the trip contribution
+// subtracted from the given driver.
+func (s Service) PreviewReverseRating(ctx context.Context, driver Driver, c Contribution) (Driver, error) {
+	netEarnings, err := s.cache.GetHighestNetEarnings(ctx, driver.ServiceZone)
+	if err != nil {
+		return Driver{}, err
+	}
+	return driver.ReverseContribution(c, netEarnings), nil
+}
 
-	return newDriver, nil
+// SaveProfile stores the driver profile, updates older than the stored
+// profile are skipped.
+func (s Service) SaveProfile(ctx context.Context, p Profile) error {
+	current, ok, err := s.cache.GetDriverProfile(ctx, p.DriverID)
+	if err != nil {
+		return err
+	}
+	if ok && current.UpdatedAt.After(p.UpdatedAt) {
+		s.logger.InfoContext(ctx, "stale driver profile skipped",
+			"driver_id", p.DriverID, "updated_at", p.UpdatedAt, "current_updated_at", current.UpdatedAt)
+		return nil
+	}
+	return s.cache.SetDriverProfile(ctx, p)
 }
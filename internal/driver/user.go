@@ -2,6 +2,8 @@ package driver
 
 import (
 	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
 )
 
 type RFM struct {
@@ -23,6 +25,34 @@ type Driver struct {
 	UniqueDateWithCompletedTrips int       `json:"unique_date_with_completed_trips"`
 	ServiceZone                  string    `json:"service_zone"`
 	Rating                       Rating    `json:"rating"`
+	// Penalty is the total points deducted from the rating average.
+	Penalty float64 `json:"penalty"`
+	// IneligibleUntil excludes the driver from the leaderboard until then.
+	IneligibleUntil *time.Time `json:"ineligible_until,omitempty"`
+}
+
+// ApplyTrip returns the driver with updated earnings, trips and rating from
+// a completed trip.
+func (d Driver) ApplyTrip(t trip.Event, highestNetEarnings float64, now time.Time) Driver {
+	nd := Driver{
+		DriverID:                     t.DriverID,
+		LastCompletedTripDate:        now,
+		NetIncome:                    t.Price.DriverEarnings + d.NetIncome,
+		NumberOfCompletedTrips:       d.NumberOfCompletedTrips + 1,
+		UniqueDateWithCompletedTrips: d.UniqueDateWithCompletedTrips,
+		ServiceZone:                  d.ServiceZone,
+		Penalty:                      d.Penalty,
+		IneligibleUntil:              d.IneligibleUntil,
+		Rating: Rating{
+			RFM: RFM{
+				Recency:   d.CalculateRecency(),
+				Frequency: d.CalculateFrequency(),
+				Monetary:  d.CalculateMonetary(highestNetEarnings),
+			},
+		},
+	}
+	nd.Rating.Average = nd.CalculateAverage()
+	return nd
 }
 
 func (d Driver) CalculateAverage() float64 {
@@ -37,8 +67,27 @@ func (d Driver) CalculateAverage() float64 {
 	// Normalize RFM values to be on a scale of 0 to 5
 	normalizedRFM := ((d.Rating.RFM.Recency + d.Rating.RFM.Frequency + d.Rating.RFM.Monetary) / 3) * 5 / 4
 
-	// Calculate the weighted average
-	return (0.6 * normalizedTripsScore) + (0.4 * normalizedRFM)
+	// Calculate the weighted average less penalty points
+	avg := (0.6 * normalizedTripsScore) + (0.4 * normalizedRFM)
+	return max(avg-d.Penalty, 0)
+}
+
+// ApplyPenalty returns the driver with penalty points deducted from the
+// rating average and ineligible until the later of the current and given
+// window end, zero until keeps the driver eligible.
+func (d Driver) ApplyPenalty(points float64, until time.Time) Driver {
+	nd := d
+	nd.Penalty += points
+	if !until.IsZero() && (d.IneligibleUntil == nil || until.After(*d.IneligibleUntil)) {
+		nd.IneligibleUntil = &until
+	}
+	nd.Rating.Average = nd.CalculateAverage()
+	return nd
+}
+
+// Eligible reports whether the driver can be ranked in the leaderboard.
+func (d Driver) Eligible(now time.Time) bool {
+	return d.IneligibleUntil == nil || !now.Before(*d.IneligibleUntil)
 }
 
 // CalculateMonetary
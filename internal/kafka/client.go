@@ -3,6 +3,7 @@ package kafka
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -72,22 +73,25 @@ func (c *Client) Listen(topics []string, queue chan<- worker.Job) (stop func(),
 		return nil, err
 	}
 
-	if err = consumer.SubscribeTopics(topics, nil); err != nil {
+	if err = consumer.SubscribeTopics(topics, c.rebalance); err != nil {
 		return nil, err
 	}
+	c.consumer = consumer
 
 	stop = func() {
-		if !consumer.IsClosed() {
+		if consumer.IsClosed() {
 			c.logger.Info("consumer already closed")
 			return
 		}
-		if err = consumer.Close(); err != nil {
+		if err := consumer.Close(); err != nil {
 			c.logger.Error("consumer close", "err", err)
 		}
 		c.logger.Info("consumer closed")
 	}
 
+	c.listening.Add(1)
 	go func(consumer *ckafka.Consumer) {
+		defer c.listening.Done()
 		for {
 			select {
 			case <-c.quit:
@@ -107,14 +111,23 @@ func (c *Client) Listen(topics []string, queue chan<- worker.Job) (stop func(),
 					continue
 				}
 
-				queue <- worker.Job{
-					Topic:   *m.TopicPartition.Topic,
-					Payload: m.Value,
+				job := worker.Job{
+					Topic:     *m.TopicPartition.Topic,
+					Key:       string(m.Key),
+					Payload:   m.Value,
+					Partition: m.TopicPartition.Partition,
+					Offset:    int64(m.TopicPartition.Offset),
 					Done: func() error {
-						_, err = consumer.CommitMessage(m)
+						_, err := consumer.CommitMessage(m)
 						return err
 					},
 				}
+				select {
+				case queue <- job:
+				case <-c.quit:
+					c.logger.Info("consumer quit")
+					return
+				}
 			}
 		}
 	}(consumer)
@@ -122,25 +135,97 @@ func (c *Client) Listen(topics []string, queue chan<- worker.Job) (stop func(),
 	return stop, nil
 }
 
-// PL-53: Added optional topic to parameter
-func (p *Client) Produce(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error {
-	reportingChan := make(chan ckafka.Event)
+// Pause pauses consumption of the topic, partitions assigned later are
+// paused when assigned until the topic is resumed.
+func (c *Client) Pause(topic string) error {
+	c.mu.Lock()
+	if c.paused == nil {
+		c.paused = map[string]bool{}
+	}
+	c.paused[topic] = true
+	c.mu.Unlock()
 
-	// Use the optional topic if provided, otherwise use the default topic
-	topic := p.topic
-	if len(optionalTopic) > 0 && optionalTopic[0] != "" {
-		topic = optionalTopic[0]
+	tps, err := c.assigned(topic)
+	if err != nil {
+		return err
 	}
+	return c.consumer.Pause(tps)
+}
 
-	msg := &ckafka.Message{
-		Key:   key,
-		Value: value,
-		TopicPartition: ckafka.TopicPartition{
-			Topic:     &topic,
-			Partition: ckafka.PartitionAny,
-		},
-		Timestamp: p.now(),
+// Resume resumes consumption of assigned partitions of the topic.
+func (c *Client) Resume(topic string) error {
+	c.mu.Lock()
+	delete(c.paused, topic)
+	c.mu.Unlock()
+
+	tps, err := c.assigned(topic)
+	if err != nil {
+		return err
+	}
+	return c.consumer.Resume(tps)
+}
+
+// rebalance assigns the partitions and pauses the partitions of paused
+// topics, assigned partitions are not paused by the consumer. Revoked
+// partitions are unassigned by the consumer.
+func (c *Client) rebalance(consumer *ckafka.Consumer, ev ckafka.Event) error {
+	e, ok := ev.(ckafka.AssignedPartitions)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	if consumer.GetRebalanceProtocol() == "COOPERATIVE" {
+		err = consumer.IncrementalAssign(e.Partitions)
+	} else {
+		err = consumer.Assign(e.Partitions)
+	}
+	if err != nil {
+		c.logger.Error("assign partitions", "err", err)
+		return err
+	}
+
+	c.mu.Lock()
+	var paused []ckafka.TopicPartition
+	for _, tp := range e.Partitions {
+		if tp.Topic != nil && c.paused[*tp.Topic] {
+			paused = append(paused, tp)
+		}
+	}
+	c.mu.Unlock()
+	if len(paused) == 0 {
+		return nil
+	}
+	if err = consumer.Pause(paused); err != nil {
+		c.logger.Error("pause assigned partitions", "err", err)
+		return err
+	}
+	c.logger.Info("assigned partitions of paused topics paused", "partitions", len(paused))
+	return nil
+}
+
+func (c *Client) assigned(topic string) ([]ckafka.TopicPartition, error) {
+	if c.consumer == nil {
+		return nil, errors.New("consumer not listening")
+	}
+	assignment, err := c.consumer.Assignment()
+	if err != nil {
+		return nil, err
+	}
+
+	var tps []ckafka.TopicPartition
+	for _, tp := range assignment {
+		if tp.Topic != nil && *tp.Topic == topic {
+			tps = append(tps, tp)
+		}
 	}
+	return tps, nil
+}
+
+// PL-53: Added optional topic to parameter
+func (p *Client) Produce(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error {
+	reportingChan := make(chan ckafka.Event)
+	msg := p.message(key, value, optionalTopic...)
 
 	// Produce the message asynchronously
 	if err := p.producer.Produce(msg, reportingChan); err != nil {
@@ -182,7 +267,54 @@ func (p *Client) Produce(ctx context.Context, key []byte, value []byte, optional
 	return nil
 }
 
+// ProduceSync produces the message and waits for its delivery report,
+// returns the delivery error. Produce only queues the message, events that
+// are marked published after producing use ProduceSync.
+func (p *Client) ProduceSync(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error {
+	// Buffered so the delivery report is not blocked when ctx is done first.
+	reportingChan := make(chan ckafka.Event, 1)
+	if err := p.producer.Produce(p.message(key, value, optionalTopic...), reportingChan); err != nil {
+		return err
+	}
+
+	select {
+	case delivery := <-reportingChan:
+		switch report := delivery.(type) {
+		case *ckafka.Message:
+			return report.TopicPartition.Error
+		case ckafka.Error:
+			return report
+		default:
+			return fmt.Errorf("unexpected delivery event: %v", delivery)
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// message returns the message to the optional topic if provided, otherwise
+// to the default topic.
+func (p *Client) message(key []byte, value []byte, optionalTopic ...string) *ckafka.Message {
+	topic := p.topic
+	if len(optionalTopic) > 0 && optionalTopic[0] != "" {
+		topic = optionalTopic[0]
+	}
+	return &ckafka.Message{
+		Key:   key,
+		Value: value,
+		TopicPartition: ckafka.TopicPartition{
+			Topic:     &topic,
+			Partition: ckafka.PartitionAny,
+		},
+		Timestamp: p.now(),
+	}
+}
+
 func (p *Client) Close() error {
+	// Stops consumer from sending jobs before the worker closes its queue.
+	p.quitOnce.Do(func() { close(p.quit) })
+	p.listening.Wait()
+
 	p.producer.Flush(p.timeout)
 	p.producer.Close()
 	p.logger.Info("flushed and closed producer")
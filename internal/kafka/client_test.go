@@ -0,0 +1,89 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+func TestClient_ProduceSync(t *testing.T) {
+	deliveryErr := ckafka.NewError(ckafka.ErrMsgTimedOut, "message timed out", false)
+	tests := []struct {
+		name       string
+		produceErr error
+		report     func(m *ckafka.Message) ckafka.Event
+		wantErr    error
+	}{
+		{
+			"delivered",
+			nil,
+			func(m *ckafka.Message) ckafka.Event { return m },
+			nil,
+		},
+		{
+			"delivery failed",
+			nil,
+			func(m *ckafka.Message) ckafka.Event {
+				m.TopicPartition.Error = deliveryErr
+				return m
+			},
+			deliveryErr,
+		},
+		{
+			"not queued",
+			errors.New("queue full"),
+			nil,
+			errors.New("queue full"),
+		},
+		{
+			"no delivery report before the context is done",
+			nil,
+			func(m *ckafka.Message) ckafka.Event { return nil },
+			context.DeadlineExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := &mockProducer{err: tt.produceErr, report: tt.report}
+			c := NewClient(slog.New(slog.NewTextHandler(io.Discard, nil)), &WriterConfig{Topic: "default"}, producer, time.Now)
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			err := c.ProduceSync(ctx, []byte("key"), []byte("value"), "payouts")
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && (err == nil || err.Error() != tt.wantErr.Error()) {
+				t.Fatalf("ProduceSync() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.produceErr == nil && *producer.msg.TopicPartition.Topic != "payouts" {
+				t.Errorf("topic = %s, want payouts", *producer.msg.TopicPartition.Topic)
+			}
+		})
+	}
+}
+
+type mockProducer struct {
+	err    error
+	report func(m *ckafka.Message) ckafka.Event
+	msg    *ckafka.Message
+}
+
+func (m *mockProducer) Produce(msg *ckafka.Message, deliveryChan chan ckafka.Event) error {
+	m.msg = msg
+	if m.err != nil {
+		return m.err
+	}
+	if e := m.report(msg); e != nil {
+		deliveryChan <- e
+	}
+	return nil
+}
+
+func (m *mockProducer) Flush(timeoutMs int) int {
+	return 0
+}
+
+func (m *mockProducer) Close() {}
@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"log/slog"
@@ -30,7 +31,15 @@ type (
 		now       func() time.Time
 		topic     string
 		quit      chan struct{}
+		quitOnce  sync.Once
+		listening sync.WaitGroup
+		consumer  *ckafka.Consumer
 		timeout   int
+
+		mu sync.Mutex
+		// paused are the topics paused, their partitions are paused again
+		// when assigned by a rebalance.
+		paused map[string]bool
 	}
 	WriterConfig struct {
 		Servers          string
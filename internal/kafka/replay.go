@@ -0,0 +1,189 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ckafka "github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+const replayTimeoutMs = 10000
+
+// ReplayConfig represents the topic time range to replay.
+type ReplayConfig struct {
+	Topic   string
+	From    time.Time
+	To      time.Time
+	GroupID string
+}
+
+// ReplayResult represents replayed messages count.
+type ReplayResult struct {
+	Messages int `json:"messages"`
+	Failed   int `json:"failed"`
+}
+
+// Replay consumes topic messages produced between From and To, each partition
+// is seeked by timestamp and messages are handled sequentially by fn.
+// Handled messages are committed on the replay consumer group.
+func (c *Client) Replay(ctx context.Context, rc ReplayConfig, fn worker.JobHandler) (ReplayResult, error) {
+	var res ReplayResult
+	if rc.GroupID == "" {
+		return res, errors.New("replay consumer group required")
+	}
+	if !rc.From.Before(rc.To) {
+		return res, fmt.Errorf("invalid replay range %s to %s", rc.From, rc.To)
+	}
+
+	ckc := ckafka.ConfigMap{}
+	for k, v := range c.configmap {
+		ckc[k] = v
+	}
+	ckc["enable.auto.commit"] = false
+	ckc["group.id"] = rc.GroupID
+	ckc["auto.offset.reset"] = "earliest"
+	consumer, err := ckafka.NewConsumer(&ckc)
+	if err != nil {
+		return res, err
+	}
+	defer consumer.Close()
+
+	start, end, err := replayOffsets(consumer, rc)
+	if err != nil {
+		return res, err
+	}
+	if len(start) == 0 {
+		c.logger.Info("replay range has no messages", "topic", rc.Topic)
+		return res, nil
+	}
+	if err = consumer.Assign(start); err != nil {
+		return res, fmt.Errorf("assign partitions: %s", err)
+	}
+	c.logger.Info("replay started", "topic", rc.Topic, "partitions", len(start), "from", rc.From, "to", rc.To)
+
+	for len(end) > 0 {
+		if err = ctx.Err(); err != nil {
+			return res, err
+		}
+
+		m, err := consumer.ReadMessage(time.Second)
+		if err != nil {
+			var kerr ckafka.Error
+			if errors.As(err, &kerr) && kerr.IsTimeout() {
+				if err = replayDone(consumer, rc.Topic, end); err != nil {
+					return res, err
+				}
+				continue
+			}
+			return res, fmt.Errorf("read message: %s", err)
+		}
+
+		p := m.TopicPartition.Partition
+		last, ok := end[p]
+		if !ok {
+			continue
+		}
+		if int64(m.TopicPartition.Offset) >= last {
+			delete(end, p)
+		}
+		if int64(m.TopicPartition.Offset) > last {
+			continue
+		}
+
+		res.Messages++
+		job := worker.Job{
+			Topic:   rc.Topic,
+			Key:     string(m.Key),
+			Payload: m.Value,
+		}
+		if err = fn(ctx, job); err != nil {
+			res.Failed++
+			c.logger.Error("replay message", "err", err, "partition", p, "offset", m.TopicPartition.Offset)
+			continue
+		}
+		if _, err = consumer.CommitMessage(m); err != nil {
+			c.logger.Error("replay commit", "err", err, "partition", p, "offset", m.TopicPartition.Offset)
+		}
+	}
+
+	c.logger.Info("replay finished", "topic", rc.Topic, "messages", res.Messages, "failed", res.Failed)
+	return res, nil
+}
+
+// replayDone removes partitions that has consumed past its last offset, last
+// offset might not be delivered when it is a compacted or transaction marker.
+func replayDone(consumer *ckafka.Consumer, topic string, end map[int32]int64) error {
+	tps := make([]ckafka.TopicPartition, 0, len(end))
+	for p := range end {
+		tps = append(tps, ckafka.TopicPartition{Topic: &topic, Partition: p})
+	}
+	pos, err := consumer.Position(tps)
+	if err != nil {
+		return fmt.Errorf("partition position: %s", err)
+	}
+	for _, tp := range pos {
+		if tp.Offset >= 0 && int64(tp.Offset) > end[tp.Partition] {
+			delete(end, tp.Partition)
+		}
+	}
+	return nil
+}
+
+// replayOffsets returns the start offsets of partitions with messages in the
+// range and the last offset to replay per partition.
+func replayOffsets(consumer *ckafka.Consumer, rc ReplayConfig) (start []ckafka.TopicPartition, last map[int32]int64, err error) {
+	md, err := consumer.GetMetadata(&rc.Topic, false, replayTimeoutMs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("topic metadata: %s", err)
+	}
+	tm, ok := md.Topics[rc.Topic]
+	if !ok || len(tm.Partitions) == 0 {
+		return nil, nil, fmt.Errorf("topic %s not found", rc.Topic)
+	}
+
+	from := make([]ckafka.TopicPartition, len(tm.Partitions))
+	to := make([]ckafka.TopicPartition, len(tm.Partitions))
+	for i, p := range tm.Partitions {
+		from[i] = ckafka.TopicPartition{Topic: &rc.Topic, Partition: p.ID, Offset: ckafka.Offset(rc.From.UnixMilli())}
+		to[i] = ckafka.TopicPartition{Topic: &rc.Topic, Partition: p.ID, Offset: ckafka.Offset(rc.To.UnixMilli())}
+	}
+	if from, err = consumer.OffsetsForTimes(from, replayTimeoutMs); err != nil {
+		return nil, nil, fmt.Errorf("offsets for from time: %s", err)
+	}
+	if to, err = consumer.OffsetsForTimes(to, replayTimeoutMs); err != nil {
+		return nil, nil, fmt.Errorf("offsets for to time: %s", err)
+	}
+
+	endOffsets := map[int32]int64{}
+	for _, tp := range to {
+		endOffsets[tp.Partition] = int64(tp.Offset)
+	}
+
+	last = map[int32]int64{}
+	for _, tp := range from {
+		// No messages on partition after from time.
+		if tp.Offset < 0 {
+			continue
+		}
+
+		// No messages after to time, replay until the high watermark.
+		end := endOffsets[tp.Partition]
+		if end < 0 {
+			_, high, err := consumer.QueryWatermarkOffsets(rc.Topic, tp.Partition, replayTimeoutMs)
+			if err != nil {
+				return nil, nil, fmt.Errorf("watermark offsets: %s", err)
+			}
+			end = high
+		}
+		if end <= int64(tp.Offset) {
+			continue
+		}
+
+		start = append(start, tp)
+		last[tp.Partition] = end - 1
+	}
+	return start, last, nil
+}
@@ -0,0 +1,169 @@
+package leaderboard
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+)
+
+// ScoreDelta represents the leaderboard score changes of a driver.
+type ScoreDelta struct {
+	DriverID    string  `json:"driver_id"`
+	ServiceZone string  `json:"service_zone"`
+	Trips       int     `json:"trips"`
+	Earnings    float64 `json:"earnings"`
+	Before      float64 `json:"before"`
+	After       float64 `json:"after"`
+}
+
+// Delta returns the score difference.
+func (d ScoreDelta) Delta() float64 {
+	return d.After - d.Before
+}
+
+// PreviewRepository provides driver ratings without writing them.
+type PreviewRepository interface {
+	GetDriver(ctx context.Context, id string) (driver.Driver, error)
+	PreviewUserRating(ctx context.Context, d driver.Driver, trip trip.Event) (driver.Driver, error)
+	PreviewReverseRating(ctx context.Context, d driver.Driver, c driver.Contribution) (driver.Driver, error)
+	GetTripContribution(ctx context.Context, tripRequestID string) (driver.Contribution, bool, error)
+}
+
+// DryRun computes leaderboard score changes of trips without writing them.
+// Each driver current rating is read once and the following trips are
+// applied on top of the previewed rating.
+type DryRun struct {
+	user PreviewRepository
+
+	mu      sync.Mutex
+	drivers map[string]driver.Driver
+	deltas  map[string]*ScoreDelta
+	// contributions holds contributions of trips by trip request id,
+	// previewed or read once.
+	contributions map[string]driver.Contribution
+}
+
+// NewDryRun returns new instance of dry run leaderboard writer.
+func NewDryRun(u PreviewRepository) *DryRun {
+	return &DryRun{
+		user:          u,
+		drivers:       map[string]driver.Driver{},
+		deltas:        map[string]*ScoreDelta{},
+		contributions: map[string]driver.Contribution{},
+	}
+}
+
+// UpdateLeaderboard records score changes of the trip.
+func (d *DryRun) UpdateLeaderboard(ctx context.Context, t trip.Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if t.TripRequestID != "" {
+		_, ok, err := d.contribution(ctx, t.TripRequestID)
+		if err != nil || ok {
+			return err
+		}
+	}
+
+	current, err := d.driver(ctx, t.DriverID)
+	if err != nil {
+		return err
+	}
+	next, err := d.user.PreviewUserRating(ctx, current, t)
+	if err != nil {
+		return err
+	}
+	d.drivers[t.DriverID] = next
+	if t.TripRequestID != "" {
+		d.contributions[t.TripRequestID] = driver.NewContribution(t, time.Now())
+	}
+
+	delta := d.deltas[t.DriverID]
+	delta.ServiceZone = next.ServiceZone
+	delta.Trips++
+	delta.Earnings += t.Price.DriverEarnings
+	delta.After = next.Rating.Average
+	return nil
+}
+
+// ReverseTrip records score changes of reversing the trip.
+func (d *DryRun) ReverseTrip(ctx context.Context, t trip.Event) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c, ok, err := d.contribution(ctx, t.TripRequestID)
+	if err != nil || !ok || c.Reversed() {
+		return err
+	}
+
+	current, err := d.driver(ctx, c.DriverID)
+	if err != nil {
+		return err
+	}
+	next, err := d.user.PreviewReverseRating(ctx, current, c)
+	if err != nil {
+		return err
+	}
+	d.drivers[c.DriverID] = next
+	d.contributions[t.TripRequestID] = c.Reverse(t.Status, time.Now())
+
+	delta := d.deltas[c.DriverID]
+	delta.ServiceZone = next.ServiceZone
+	delta.Trips -= c.Trips
+	delta.Earnings -= c.Earnings
+	delta.After = next.Rating.Average
+	return nil
+}
+
+// driver returns the previewed driver, reading the current rating once.
+func (d *DryRun) driver(ctx context.Context, id string) (driver.Driver, error) {
+	if current, ok := d.drivers[id]; ok {
+		return current, nil
+	}
+	current, err := d.user.GetDriver(ctx, id)
+	if err != nil {
+		return driver.Driver{}, err
+	}
+	d.drivers[id] = current
+	d.deltas[id] = &ScoreDelta{
+		DriverID: id,
+		Before:   current.Rating.Average,
+	}
+	return current, nil
+}
+
+func (d *DryRun) contribution(ctx context.Context, tripRequestID string) (driver.Contribution, bool, error) {
+	if c, ok := d.contributions[tripRequestID]; ok {
+		return c, true, nil
+	}
+	c, ok, err := d.user.GetTripContribution(ctx, tripRequestID)
+	if err != nil || !ok {
+		return driver.Contribution{}, false, err
+	}
+	d.contributions[tripRequestID] = c
+	return c, true, nil
+}
+
+// Deltas returns score changes sorted by the largest change.
+func (d *DryRun) Deltas() []ScoreDelta {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	dd := make([]ScoreDelta, 0, len(d.deltas))
+	for _, v := range d.deltas {
+		dd = append(dd, *v)
+	}
+	sort.Slice(dd, func(i, j int) bool {
+		di, dj := math.Abs(dd[i].Delta()), math.Abs(dd[j].Delta())
+		if di == dj {
+			return dd[i].DriverID < dd[j].DriverID
+		}
+		return di > dj
+	})
+	return dd
+}
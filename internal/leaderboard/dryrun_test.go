@@ -0,0 +1,128 @@
+package leaderboard
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+)
+
+func TestDryRun_Deltas(t *testing.T) {
+	var reads int
+	repo := &mockPreviewRepository{
+		GetDriverFn: func(ctx context.Context, id string) (driver.Driver, error) {
+			reads++
+			if id == "driver-1" {
+				return driver.Driver{DriverID: id, ServiceZone: "MNL", Rating: driver.Rating{Average: 1}}, nil
+			}
+			return driver.Driver{}, nil
+		},
+		// Each trip adds a point on top of the previewed rating.
+		PreviewUserRatingFn: func(ctx context.Context, d driver.Driver, t trip.Event) (driver.Driver, error) {
+			d.DriverID = t.DriverID
+			d.NetIncome += t.Price.DriverEarnings
+			d.Rating.Average++
+			return d, nil
+		},
+	}
+
+	dr := NewDryRun(repo)
+	ctx := context.Background()
+	for _, e := range []trip.Event{
+		{DriverID: "driver-1", Price: trip.PriceInfo{DriverEarnings: 100}},
+		{DriverID: "driver-2", Price: trip.PriceInfo{DriverEarnings: 50}},
+		{DriverID: "driver-1", Price: trip.PriceInfo{DriverEarnings: 20}},
+	} {
+		if err := dr.UpdateLeaderboard(ctx, e); err != nil {
+			t.Fatalf("UpdateLeaderboard() error = %v", err)
+		}
+	}
+
+	want := []ScoreDelta{
+		{DriverID: "driver-1", ServiceZone: "MNL", Trips: 2, Earnings: 120, Before: 1, After: 3},
+		{DriverID: "driver-2", Trips: 1, Earnings: 50, Before: 0, After: 1},
+	}
+	if got := dr.Deltas(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Deltas() = %+v, want %+v", got, want)
+	}
+	if reads != 2 {
+		t.Errorf("GetDriver() called %d times, want once per driver", reads)
+	}
+}
+
+func TestDryRun_ReverseTrip(t *testing.T) {
+	repo := &mockPreviewRepository{
+		GetDriverFn: func(ctx context.Context, id string) (driver.Driver, error) {
+			return driver.Driver{DriverID: id, NetIncome: 100, NumberOfCompletedTrips: 1, Rating: driver.Rating{Average: 1}}, nil
+		},
+		PreviewUserRatingFn: func(ctx context.Context, d driver.Driver, t trip.Event) (driver.Driver, error) {
+			d.NetIncome += t.Price.DriverEarnings
+			d.NumberOfCompletedTrips++
+			d.Rating.Average++
+			return d, nil
+		},
+		PreviewReverseRatingFn: func(ctx context.Context, d driver.Driver, c driver.Contribution) (driver.Driver, error) {
+			d.NetIncome -= c.Earnings
+			d.NumberOfCompletedTrips -= c.Trips
+			d.Rating.Average--
+			return d, nil
+		},
+		// trip-1 is scored before the replay.
+		GetTripContributionFn: func(ctx context.Context, id string) (driver.Contribution, bool, error) {
+			if id == "trip-1" {
+				return driver.Contribution{TripRequestID: id, DriverID: "driver-1", Earnings: 100, Trips: 1}, true, nil
+			}
+			return driver.Contribution{}, false, nil
+		},
+	}
+
+	dr := NewDryRun(repo)
+	ctx := context.Background()
+	for _, e := range []trip.Event{
+		{TripRequestID: "trip-1", DriverID: "driver-1", Status: trip.StatusComplete, Price: trip.PriceInfo{DriverEarnings: 100}},
+		{TripRequestID: "trip-2", DriverID: "driver-1", Status: trip.StatusComplete, Price: trip.PriceInfo{DriverEarnings: 40}},
+		{TripRequestID: "trip-1", DriverID: "driver-1", Status: trip.StatusRefunded},
+		{TripRequestID: "trip-1", DriverID: "driver-1", Status: trip.StatusDisputed},
+		{TripRequestID: "trip-3", DriverID: "driver-1", Status: trip.StatusCancelled},
+	} {
+		var err error
+		if trip.IsReversal(e.Status) {
+			err = dr.ReverseTrip(ctx, e)
+		} else {
+			err = dr.UpdateLeaderboard(ctx, e)
+		}
+		if err != nil {
+			t.Fatalf("%s %s error = %v", e.TripRequestID, e.Status, err)
+		}
+	}
+
+	want := []ScoreDelta{{DriverID: "driver-1", Trips: 0, Earnings: -60, Before: 1, After: 1}}
+	if got := dr.Deltas(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Deltas() = %+v, want %+v", got, want)
+	}
+}
+
+type mockPreviewRepository struct {
+	GetDriverFn            func(ctx context.Context, id string) (driver.Driver, error)
+	PreviewUserRatingFn    func(ctx context.Context, d driver.Driver, t trip.Event) (driver.Driver, error)
+	PreviewReverseRatingFn func(ctx context.Context, d driver.Driver, c driver.Contribution) (driver.Driver, error)
+	GetTripContributionFn  func(ctx context.Context, id string) (driver.Contribution, bool, error)
+}
+
+func (m *mockPreviewRepository) PreviewReverseRating(ctx context.Context, d driver.Driver, c driver.Contribution) (driver.Driver, error) {
+	return m.PreviewReverseRatingFn(ctx, d, c)
+}
+
+func (m *mockPreviewRepository) GetTripContribution(ctx context.Context, id string) (driver.Contribution, bool, error) {
+	return m.GetTripContributionFn(ctx, id)
+}
+
+func (m *mockPreviewRepository) GetDriver(ctx context.Context, id string) (driver.Driver, error) {
+	return m.GetDriverFn(ctx, id)
+}
+
+func (m *mockPreviewRepository) PreviewUserRating(ctx context.Context, d driver.Driver, t trip.Event) (driver.Driver, error) {
+	return m.PreviewUserRatingFn(ctx, d, t)
+}
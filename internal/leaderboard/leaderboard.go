@@ -3,5 +3,18 @@ package leaderboard
 import "gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
 
 type Leaderboard struct {
-	Drivers []driver.Driver `json:"drivers"`
+	Drivers []Entry `json:"drivers"`
+}
+
+// Entry represents a ranked driver with public profile details.
+type Entry struct {
+	driver.Driver
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// Score represents the leaderboard score of a driver.
+type Score struct {
+	DriverID string  `json:"driver_id"`
+	Score    float64 `json:"score"`
 }
@@ -2,27 +2,62 @@ package leaderboard
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
+	"time"
 
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
 )
 
 // Service represents Tier service.
+// scorePage is the number of leaderboard scores read at once.
+const scorePage = 1000
+
 type Service struct {
-	cache  CacheRepository
-	user   UserRepository
-	logger *slog.Logger
+	cache    CacheRepository
+	user     UserRepository
+	capper   TripCapper
+	observer TripObserver
+	logger   *slog.Logger
 }
 
 // cacheRepository manages redis or any nosql storage operations
 type CacheRepository interface {
 	GetActiveLeaderboard(ctx context.Context, scope string) (*[]driver.Driver, error)
+	// GetLeaderboardScores returns up to count scores of the leaderboard
+	// from offset, highest score first.
+	GetLeaderboardScores(ctx context.Context, scope string, offset, count int64) ([]Score, error)
 	RefreshLeaderboard(ctx context.Context, user driver.Driver) error
+	// SaveTripScore saves the driver rating and the trip contribution at once,
+	// returns false without replace when the trip was already scored.
+	SaveTripScore(ctx context.Context, user driver.Driver, c driver.Contribution, replace bool) (bool, error)
+	GetDriverProfiles(ctx context.Context, driverIDs []string) (map[string]driver.Profile, error)
 }
 
 type UserRepository interface {
 	UpdateUserRating(ctx context.Context, trip trip.Event) (driver.Driver, error)
+	ReverseUserRating(ctx context.Context, c driver.Contribution) (driver.Driver, error)
+	RescoreUserRating(ctx context.Context, c driver.Contribution, trip trip.Event) (driver.Driver, error)
+	GetTripContribution(ctx context.Context, tripRequestID string) (driver.Contribution, bool, error)
+	SaveTripContribution(ctx context.Context, c driver.Contribution) error
+}
+
+// TripObserver is notified of trips scored in the leaderboard and of their
+// reversals.
+type TripObserver interface {
+	TripScored(ctx context.Context, d driver.Driver, t trip.Event) error
+	// TripReversed is notified with the reversed contribution of the trip.
+	TripReversed(ctx context.Context, d driver.Driver, c driver.Contribution) error
+}
+
+// TripCapper caps the trips and earnings scored per driver.
+type TripCapper interface {
+	// Cap returns the trip with the earnings counted within the caps, false
+	// when the trip is over the trip cap.
+	Cap(ctx context.Context, t trip.Event) (trip.Event, bool, error)
+	// Release gives back the caps counted for the reversed trip.
+	Release(ctx context.Context, c driver.Contribution) error
 }
 
 // NewService returns new tier service.
@@ -34,6 +69,16 @@ func NewLeaderboardService(c CacheRepository, u UserRepository, l *slog.Logger)
 	}
 }
 
+// CapTrips sets the capper of trips not scored yet.
+func (s *Service) CapTrips(c TripCapper) {
+	s.capper = c
+}
+
+// OnTripScored sets the observer of scored and reversed trips.
+func (s *Service) OnTripScored(o TripObserver) {
+	s.observer = o
+}
+
 func (s Service) GetLeaderboard(ctx context.Context, scope string) (Leaderboard, error) {
 	leaders := Leaderboard{}
 
@@ -43,24 +88,182 @@ func (s Service) GetLeaderboard(ctx context.Context, scope string) (Leaderboard,
 		return leaders, err
 	}
 
+	// Excludes drivers serving a penalty ineligibility window.
+	now := time.Now()
+	entries := make([]Entry, 0, len(*list))
+	ids := make([]string, 0, len(*list))
+	for _, d := range *list {
+		if d.Eligible(now) {
+			entries = append(entries, Entry{Driver: d})
+			ids = append(ids, d.DriverID)
+		}
+	}
+
+	// Leaderboard is still served without names when profiles are unavailable.
+	profiles, err := s.cache.GetDriverProfiles(ctx, ids)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "could not get driver profiles", "err", err)
+	}
+	for i, e := range entries {
+		if p, ok := profiles[e.DriverID]; ok {
+			entries[i].DisplayName = p.DisplayName()
+			entries[i].AvatarURL = p.AvatarURL
+		}
+	}
+
 	return Leaderboard{
-		Drivers: *list,
+		Drivers: entries,
 	}, nil
 }
 
+// GetScores returns the scores of every driver of the leaderboard, highest
+// score first, including drivers not eligible to be listed.
+func (s Service) GetScores(ctx context.Context, scope string) ([]Score, error) {
+	var scores []Score
+	for offset := int64(0); ; offset += scorePage {
+		page, err := s.cache.GetLeaderboardScores(ctx, scope, offset, scorePage)
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, page...)
+		if len(page) < scorePage {
+			return scores, nil
+		}
+	}
+}
+
 func (s Service) UpdateLeaderboard(ctx context.Context, trip trip.Event) error {
 	s.logger.Info("updating leaderboard...")
 
+	// Skip trips already scored, contribution is only tracked for trips with id.
+	if trip.TripRequestID != "" {
+		_, ok, err := s.user.GetTripContribution(ctx, trip.TripRequestID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.logger.InfoContext(ctx, "trip already scored", "trip_request_id", trip.TripRequestID)
+			return nil
+		}
+	}
+
+	if s.capper != nil {
+		capped, ok, err := s.capper.Cap(ctx, trip)
+		if err != nil {
+			return fmt.Errorf("could not cap trip: %w", err)
+		}
+		if !ok {
+			// Trip over the cap is kept with no contribution so it is not
+			// counted against the cap again.
+			if trip.TripRequestID == "" {
+				return nil
+			}
+			c := driver.NewContribution(trip, time.Now())
+			c.Earnings, c.Trips = 0, 0
+			return s.user.SaveTripContribution(ctx, c)
+		}
+		trip = capped
+	}
+
 	// get the driver from the cache
 	user, err := s.user.UpdateUserRating(ctx, trip)
 	if err != nil {
 		return err
 	}
-	// update cache
-	err = s.cache.RefreshLeaderboard(ctx, user)
+	// update cache, the contribution is saved with the score so a failure
+	// never scores the trip twice.
+	if trip.TripRequestID == "" {
+		err = s.cache.RefreshLeaderboard(ctx, user)
+	} else {
+		var saved bool
+		saved, err = s.cache.SaveTripScore(ctx, user, driver.NewContribution(trip, time.Now()), false)
+		if err == nil && !saved {
+			s.logger.InfoContext(ctx, "trip already scored", "trip_request_id", trip.TripRequestID)
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// The trip is scored, observer failures are not retried.
+	if s.observer != nil {
+		if err = s.observer.TripScored(ctx, user, trip); err != nil {
+			s.logger.ErrorContext(ctx, "trip scored observer failed", "driver_id", user.DriverID, "err", err)
+		}
+	}
+	return nil
+}
+
+// ReverseTrip subtracts the score contribution of a completed trip that is
+// cancelled, refunded or disputed.
+func (s Service) ReverseTrip(ctx context.Context, t trip.Event) error {
+	c, ok, err := s.user.GetTripContribution(ctx, t.TripRequestID)
+	if err != nil {
+		return err
+	}
+	if !ok || c.Reversed() {
+		s.logger.InfoContext(ctx, "no trip contribution to reverse",
+			"trip_request_id", t.TripRequestID, "status", t.Status, "reversed", c.Reversed())
+		return nil
+	}
+
+	// Released before the reversal is saved, releasing again is a no-op.
+	if s.capper != nil {
+		if err = s.capper.Release(ctx, c); err != nil {
+			return fmt.Errorf("could not release trip cap: %w", err)
+		}
+	}
+	user, err := s.user.ReverseUserRating(ctx, c)
+	if err != nil {
+		return err
+	}
+	if _, err = s.cache.SaveTripScore(ctx, user, c.Reverse(t.Status, time.Now()), true); err != nil {
+		return err
+	}
+	if s.observer != nil {
+		if err = s.observer.TripReversed(ctx, user, c); err != nil {
+			s.logger.ErrorContext(ctx, "trip reversed observer failed", "driver_id", user.DriverID, "err", err)
+		}
+	}
+
+	s.logger.InfoContext(ctx, "trip contribution reversed",
+		"trip_request_id", t.TripRequestID, "driver_id", c.DriverID, "status", t.Status,
+		"earnings", c.Earnings, "trips", c.Trips)
+	return nil
+}
+
+// RescoreTrip scores the trip again replacing its contribution, trips not
+// scored yet are scored and reversed trips are skipped. Caps were counted
+// when the trip was first scored, the trip keeps its capped earnings.
+func (s Service) RescoreTrip(ctx context.Context, t trip.Event) error {
+	if t.TripRequestID == "" {
+		return s.UpdateLeaderboard(ctx, t)
+	}
+	c, ok, err := s.user.GetTripContribution(ctx, t.TripRequestID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.UpdateLeaderboard(ctx, t)
+	}
+	if c.Reversed() || c.Trips == 0 {
+		s.logger.InfoContext(ctx, "trip not rescored",
+			"trip_request_id", t.TripRequestID, "reversed", c.Reversed(), "trips", c.Trips)
+		return nil
+	}
+
+	t.Price.DriverEarnings = min(t.Price.DriverEarnings, c.Earnings)
+	user, err := s.user.RescoreUserRating(ctx, c, t)
 	if err != nil {
 		return err
 	}
+	if _, err = s.cache.SaveTripScore(ctx, user, driver.NewContribution(t, time.Now()), true); err != nil {
+		return err
+	}
 
+	s.logger.InfoContext(ctx, "trip rescored",
+		"trip_request_id", t.TripRequestID, "driver_id", t.DriverID,
+		"earnings", c.Earnings, "rescored_earnings", t.Price.DriverEarnings)
 	return nil
 }
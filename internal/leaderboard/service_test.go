@@ -0,0 +1,332 @@
+package leaderboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+)
+
+func TestService_GetLeaderboard(t *testing.T) {
+	until := time.Now().Add(time.Hour)
+	drivers := []driver.Driver{
+		{DriverID: "driver-1"},
+		{DriverID: "driver-2", IneligibleUntil: &until},
+		{DriverID: "driver-3"},
+		{DriverID: "driver-4"},
+	}
+	profiles := map[string]driver.Profile{
+		"driver-1": {DriverID: "driver-1", Name: "Juan Dela Cruz", AvatarURL: "https://cdn/1.png", Plate: "ABC 123"},
+		"driver-3": {DriverID: "driver-3", Name: "Maria"},
+	}
+
+	tests := []struct {
+		name        string
+		profilesErr error
+		want        []Entry
+	}{
+		{
+			"with profiles",
+			nil,
+			[]Entry{
+				{Driver: drivers[0], DisplayName: "Juan C.", AvatarURL: "https://cdn/1.png"},
+				{Driver: drivers[2], DisplayName: "Maria"},
+				{Driver: drivers[3]},
+			},
+		},
+		{
+			"profiles unavailable",
+			errors.New("redis failure"),
+			[]Entry{{Driver: drivers[0]}, {Driver: drivers[2]}, {Driver: drivers[3]}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &mockCacheRepository{
+				GetActiveLeaderboardFn: func(ctx context.Context, scope string) (*[]driver.Driver, error) {
+					return &drivers, nil
+				},
+				GetDriverProfilesFn: func(ctx context.Context, ids []string) (map[string]driver.Profile, error) {
+					if want := []string{"driver-1", "driver-3", "driver-4"}; !reflect.DeepEqual(ids, want) {
+						t.Errorf("GetDriverProfiles() ids = %v, want %v", ids, want)
+					}
+					if tt.profilesErr != nil {
+						return nil, tt.profilesErr
+					}
+					return profiles, nil
+				},
+			}
+			s := NewLeaderboardService(cache, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			got, err := s.GetLeaderboard(context.Background(), "MNL")
+			if err != nil {
+				t.Fatalf("GetLeaderboard() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Drivers, tt.want) {
+				t.Errorf("GetLeaderboard() = %+v, want %+v", got.Drivers, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_GetScores(t *testing.T) {
+	for _, n := range []int{0, 3, scorePage, scorePage + 1} {
+		var board []Score
+		for i := 0; i < n; i++ {
+			board = append(board, Score{DriverID: fmt.Sprintf("driver-%d", i), Score: float64(n - i)})
+		}
+		cache := &mockCacheRepository{
+			GetLeaderboardScoresFn: func(ctx context.Context, scope string, offset, count int64) ([]Score, error) {
+				end := min(offset+count, int64(len(board)))
+				return board[min(offset, end):end], nil
+			},
+		}
+		s := NewLeaderboardService(cache, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+		got, err := s.GetScores(context.Background(), "MNL")
+		if err != nil {
+			t.Fatalf("GetScores() error = %v", err)
+		}
+		if len(got) != n || n > 0 && got[n-1] != board[n-1] {
+			t.Errorf("GetScores() = %d scores, want %d", len(got), n)
+		}
+	}
+}
+
+type mockCacheRepository struct {
+	GetActiveLeaderboardFn func(ctx context.Context, scope string) (*[]driver.Driver, error)
+	GetLeaderboardScoresFn func(ctx context.Context, scope string, offset, count int64) ([]Score, error)
+	GetDriverProfilesFn    func(ctx context.Context, ids []string) (map[string]driver.Profile, error)
+	// contributions and drivers saved with trip scores.
+	contributions map[string]driver.Contribution
+	drivers       []driver.Driver
+}
+
+func (m *mockCacheRepository) GetActiveLeaderboard(ctx context.Context, scope string) (*[]driver.Driver, error) {
+	return m.GetActiveLeaderboardFn(ctx, scope)
+}
+
+func (m *mockCacheRepository) GetLeaderboardScores(ctx context.Context, scope string, offset, count int64) ([]Score, error) {
+	return m.GetLeaderboardScoresFn(ctx, scope, offset, count)
+}
+
+func (m *mockCacheRepository) RefreshLeaderboard(ctx context.Context, d driver.Driver) error {
+	return nil
+}
+
+func (m *mockCacheRepository) SaveTripScore(ctx context.Context, d driver.Driver, c driver.Contribution, replace bool) (bool, error) {
+	if m.contributions == nil {
+		m.contributions = map[string]driver.Contribution{}
+	}
+	if _, ok := m.contributions[c.TripRequestID]; ok && !replace {
+		return false, nil
+	}
+	m.contributions[c.TripRequestID] = c
+	m.drivers = append(m.drivers, d)
+	return true, nil
+}
+
+func (m *mockCacheRepository) GetDriverProfiles(ctx context.Context, ids []string) (map[string]driver.Profile, error) {
+	return m.GetDriverProfilesFn(ctx, ids)
+}
+
+func TestService_UpdateLeaderboard(t *testing.T) {
+	completed := time.Date(2024, 5, 12, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		scored   bool
+		capped   float64
+		overCap  bool
+		wantCaps int
+		want     *driver.Contribution
+	}{
+		{
+			"capped earnings contributed",
+			false, 15, false, 1,
+			&driver.Contribution{TripRequestID: "trip-1", DriverID: "driver-1", Earnings: 15, Trips: 1, TripAt: completed},
+		},
+		{
+			"over trip cap contributes nothing",
+			false, 0, true, 1,
+			&driver.Contribution{TripRequestID: "trip-1", DriverID: "driver-1", TripAt: completed},
+		},
+		{
+			"already scored not capped",
+			true, 0, false, 0,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &mockUserRepository{contributions: map[string]driver.Contribution{}}
+			if tt.scored {
+				user.contributions["trip-1"] = driver.Contribution{TripRequestID: "trip-1", Earnings: 30, Trips: 1}
+			}
+			capper := &mockTripCapper{CapFn: func(ctx context.Context, e trip.Event) (trip.Event, bool, error) {
+				e.Price.DriverEarnings = tt.capped
+				return e, !tt.overCap, nil
+			}}
+			cache := &mockCacheRepository{contributions: user.contributions}
+			s := NewLeaderboardService(cache, user, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			s.CapTrips(capper)
+
+			e := trip.Event{TripRequestID: "trip-1", DriverID: "driver-1", UpdatedAt: completed}
+			e.Price.DriverEarnings = 30
+			if err := s.UpdateLeaderboard(context.Background(), e); err != nil {
+				t.Fatalf("UpdateLeaderboard() error = %v", err)
+			}
+			if capper.calls != tt.wantCaps {
+				t.Errorf("Cap() calls = %d, want %d", capper.calls, tt.wantCaps)
+			}
+			if tt.want == nil {
+				return
+			}
+			got := user.contributions["trip-1"]
+			got.AppliedAt = time.Time{}
+			if !reflect.DeepEqual(got, *tt.want) {
+				t.Errorf("contribution = %+v, want %+v", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestService_RescoreTrip(t *testing.T) {
+	completed := time.Date(2024, 5, 12, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		scored  *driver.Contribution
+		wantNil bool
+		want    driver.Contribution
+	}{
+		{
+			"not scored is scored",
+			nil, false,
+			driver.Contribution{TripRequestID: "trip-1", DriverID: "driver-1", Earnings: 30, Trips: 1, TripAt: completed},
+		},
+		{
+			"scored keeps capped earnings",
+			&driver.Contribution{TripRequestID: "trip-1", DriverID: "driver-1", Earnings: 15, Trips: 1}, false,
+			driver.Contribution{TripRequestID: "trip-1", DriverID: "driver-1", Earnings: 15, Trips: 1, TripAt: completed},
+		},
+		{
+			"over trip cap not rescored",
+			&driver.Contribution{TripRequestID: "trip-1", DriverID: "driver-1"}, true,
+			driver.Contribution{TripRequestID: "trip-1", DriverID: "driver-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := &mockUserRepository{contributions: map[string]driver.Contribution{}}
+			if tt.scored != nil {
+				user.contributions["trip-1"] = *tt.scored
+			}
+			cache := &mockCacheRepository{contributions: user.contributions}
+			s := NewLeaderboardService(cache, user, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			e := trip.Event{TripRequestID: "trip-1", DriverID: "driver-1", UpdatedAt: completed}
+			e.Price.DriverEarnings = 30
+			if err := s.RescoreTrip(context.Background(), e); err != nil {
+				t.Fatalf("RescoreTrip() error = %v", err)
+			}
+			if got := len(cache.drivers) == 0; got != tt.wantNil {
+				t.Errorf("driver saved = %v, want %v", !got, !tt.wantNil)
+			}
+			got := user.contributions["trip-1"]
+			got.AppliedAt = time.Time{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("contribution = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+type mockTripCapper struct {
+	CapFn func(ctx context.Context, e trip.Event) (trip.Event, bool, error)
+	calls int
+	// released are the trips released.
+	released []string
+}
+
+func (m *mockTripCapper) Release(ctx context.Context, c driver.Contribution) error {
+	m.released = append(m.released, c.TripRequestID)
+	return nil
+}
+
+func (m *mockTripCapper) Cap(ctx context.Context, e trip.Event) (trip.Event, bool, error) {
+	m.calls++
+	return m.CapFn(ctx, e)
+}
+
+type mockUserRepository struct {
+	contributions map[string]driver.Contribution
+}
+
+func (m *mockUserRepository) UpdateUserRating(ctx context.Context, e trip.Event) (driver.Driver, error) {
+	return driver.Driver{DriverID: e.DriverID, NetIncome: e.Price.DriverEarnings}, nil
+}
+
+func (m *mockUserRepository) ReverseUserRating(ctx context.Context, c driver.Contribution) (driver.Driver, error) {
+	return driver.Driver{DriverID: c.DriverID}, nil
+}
+
+func (m *mockUserRepository) RescoreUserRating(ctx context.Context, c driver.Contribution, e trip.Event) (driver.Driver, error) {
+	return driver.Driver{DriverID: e.DriverID, NetIncome: e.Price.DriverEarnings}, nil
+}
+
+func (m *mockUserRepository) GetTripContribution(ctx context.Context, id string) (driver.Contribution, bool, error) {
+	c, ok := m.contributions[id]
+	return c, ok, nil
+}
+
+func (m *mockUserRepository) SaveTripContribution(ctx context.Context, c driver.Contribution) error {
+	m.contributions[c.TripRequestID] = c
+	return nil
+}
+
+func TestService_ReverseTrip(t *testing.T) {
+	scored := driver.Contribution{TripRequestID: "trip-1", DriverID: "driver-1", Earnings: 15, Trips: 1}
+	user := &mockUserRepository{contributions: map[string]driver.Contribution{"trip-1": scored}}
+	cache := &mockCacheRepository{contributions: user.contributions}
+	observer := &mockTripObserver{}
+	capper := &mockTripCapper{}
+	s := NewLeaderboardService(cache, user, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.OnTripScored(observer)
+	s.CapTrips(capper)
+
+	e := trip.Event{TripRequestID: "trip-1", DriverID: "driver-1", Status: "cancelled"}
+	// Reversing the trip again notifies nothing.
+	for i := 0; i < 2; i++ {
+		if err := s.ReverseTrip(context.Background(), e); err != nil {
+			t.Fatalf("ReverseTrip() error = %v", err)
+		}
+	}
+	if want := []driver.Contribution{scored}; !reflect.DeepEqual(observer.reversed, want) {
+		t.Errorf("reversed = %+v, want %+v", observer.reversed, want)
+	}
+	if !user.contributions["trip-1"].Reversed() {
+		t.Error("contribution not reversed")
+	}
+	if want := []string{"trip-1"}; !reflect.DeepEqual(capper.released, want) {
+		t.Errorf("released = %v, want %v", capper.released, want)
+	}
+}
+
+type mockTripObserver struct {
+	reversed []driver.Contribution
+}
+
+func (m *mockTripObserver) TripScored(ctx context.Context, d driver.Driver, e trip.Event) error {
+	return nil
+}
+
+func (m *mockTripObserver) TripReversed(ctx context.Context, d driver.Driver, c driver.Contribution) error {
+	m.reversed = append(m.reversed, c)
+	return nil
+}
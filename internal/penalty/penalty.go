@@ -0,0 +1,65 @@
+package penalty
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kind represents the driver offense of a penalty.
+type Kind string
+
+const (
+	KindCancellation Kind = "driver_cancellation"
+	KindComplaint    Kind = "safety_complaint"
+)
+
+// Rule represents the penalty of an offense, zero values skips it.
+type Rule struct {
+	// Points deducted from the driver rating average.
+	Points float64
+	// Ineligible excludes the driver from the leaderboard for the duration.
+	Ineligible time.Duration
+}
+
+// Config represents penalty rules by offense.
+type Config struct {
+	Cancellation Rule
+	Complaint    Rule
+}
+
+func (c Config) rule(k Kind) (Rule, bool) {
+	switch k {
+	case KindCancellation:
+		return c.Cancellation, true
+	case KindComplaint:
+		return c.Complaint, true
+	}
+	return Rule{}, false
+}
+
+// Event represents a driver offense from driver cancellation or complaint
+// topics.
+type Event struct {
+	ID            string    `json:"id"`
+	Kind          Kind      `json:"kind"`
+	DriverID      string    `json:"driver_id"`
+	TripRequestID string    `json:"trip_request_id"`
+	Reason        string    `json:"reason"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// Validate checks penalty event required fields.
+func (e Event) Validate() error {
+	var missing []string
+	if strings.TrimSpace(e.ID) == "" {
+		missing = append(missing, "id")
+	}
+	if strings.TrimSpace(e.DriverID) == "" {
+		missing = append(missing, "driver_id")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("invalid penalty event: required %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package penalty
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+// appliedTTL keeps applied penalty ids to skip redelivered events.
+const appliedTTL = 30 * 24 * time.Hour
+
+// Service represents penalty service.
+type Service struct {
+	config Config
+	cache  CacheRepository
+	user   UserRepository
+	logger *slog.Logger
+}
+
+// CacheRepository manages redis or any nosql storage operations
+type CacheRepository interface {
+	SetDriverRating(ctx context.Context, driver driver.Driver) error
+	RefreshLeaderboard(ctx context.Context, user driver.Driver) error
+	IsPenaltyApplied(ctx context.Context, id string) (bool, error)
+	SetPenaltyApplied(ctx context.Context, id string, ttl time.Duration) error
+}
+
+type UserRepository interface {
+	GetDriver(ctx context.Context, id string) (driver.Driver, error)
+}
+
+// NewService returns new penalty service.
+func NewService(conf Config, c CacheRepository, u UserRepository, l *slog.Logger) *Service {
+	return &Service{
+		config: conf,
+		cache:  c,
+		user:   u,
+		logger: l,
+	}
+}
+
+// ApplyPenalty deducts penalty points from the driver rating and excludes
+// the driver from the leaderboard for the ineligibility window of the offense.
+func (s Service) ApplyPenalty(ctx context.Context, e Event) error {
+	rule, ok := s.config.rule(e.Kind)
+	if !ok {
+		return fmt.Errorf("unknown penalty kind: %s", e.Kind)
+	}
+	if rule.Points <= 0 && rule.Ineligible <= 0 {
+		return nil
+	}
+
+	// Penalty events are ordered by driver, checking before applying is
+	// enough to skip redelivered events.
+	applied, err := s.cache.IsPenaltyApplied(ctx, e.ID)
+	if err != nil {
+		return err
+	}
+	if applied {
+		s.logger.InfoContext(ctx, "penalty already applied", "penalty_id", e.ID)
+		return nil
+	}
+
+	d, err := s.user.GetDriver(ctx, e.DriverID)
+	if err != nil {
+		return err
+	}
+	d.DriverID = e.DriverID
+
+	// Ineligibility window starts from the offense.
+	var until time.Time
+	if rule.Ineligible > 0 {
+		start := e.OccurredAt
+		if start.IsZero() {
+			start = time.Now()
+		}
+		until = start.Add(rule.Ineligible)
+	}
+	d = d.ApplyPenalty(rule.Points, until)
+
+	// Drivers without trips are not ranked yet, the penalty is kept for
+	// their following trips.
+	if d.NumberOfCompletedTrips == 0 {
+		err = s.cache.SetDriverRating(ctx, d)
+	} else {
+		err = s.cache.RefreshLeaderboard(ctx, d)
+	}
+	if err != nil {
+		return err
+	}
+	if err = s.cache.SetPenaltyApplied(ctx, e.ID, appliedTTL); err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, "penalty applied",
+		"penalty_id", e.ID, "kind", e.Kind, "driver_id", e.DriverID,
+		"points", rule.Points, "ineligible_until", d.IneligibleUntil)
+	return nil
+}
@@ -0,0 +1,139 @@
+package penalty
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+func TestService_ApplyPenalty(t *testing.T) {
+	occurred := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	conf := Config{
+		Cancellation: Rule{Points: 0.5},
+		Complaint:    Rule{Points: 1, Ineligible: 72 * time.Hour},
+	}
+	ranked := driver.Driver{
+		DriverID:               "driver-1",
+		NumberOfCompletedTrips: 5,
+		Rating:                 driver.Rating{RFM: driver.RFM{Recency: 4, Frequency: 4, Monetary: 4}},
+	}
+	ranked.Rating.Average = ranked.CalculateAverage()
+
+	tests := []struct {
+		name        string
+		driver      driver.Driver
+		applied     bool
+		event       Event
+		wantRefresh bool
+		wantSet     bool
+		wantAverage float64
+		wantUntil   *time.Time
+	}{
+		{
+			"cancellation deducts points",
+			ranked,
+			false,
+			Event{ID: "p-1", Kind: KindCancellation, DriverID: "driver-1", OccurredAt: occurred},
+			true, false, 4.5, nil,
+		},
+		{
+			"complaint deducts points and excludes driver",
+			ranked,
+			false,
+			Event{ID: "p-1", Kind: KindComplaint, DriverID: "driver-1", OccurredAt: occurred},
+			true, false, 4, ptr(occurred.Add(72 * time.Hour)),
+		},
+		{
+			"already applied",
+			ranked,
+			true,
+			Event{ID: "p-1", Kind: KindComplaint, DriverID: "driver-1", OccurredAt: occurred},
+			false, false, 0, nil,
+		},
+		{
+			"unranked driver keeps penalty",
+			driver.Driver{},
+			false,
+			Event{ID: "p-1", Kind: KindCancellation, DriverID: "driver-2", OccurredAt: occurred},
+			false, true, 0, nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := &mockCacheRepository{applied: tt.applied}
+			user := &mockUserRepository{GetDriverFn: func(ctx context.Context, id string) (driver.Driver, error) {
+				return tt.driver, nil
+			}}
+			s := NewService(conf, cache, user, slog.New(slog.NewTextHandler(io.Discard, nil)))
+			if err := s.ApplyPenalty(context.Background(), tt.event); err != nil {
+				t.Fatalf("ApplyPenalty() error = %v", err)
+			}
+
+			if (cache.refreshed != nil) != tt.wantRefresh || (cache.set != nil) != tt.wantSet {
+				t.Fatalf("refreshed = %v, set = %v, want refresh %v, set %v", cache.refreshed, cache.set, tt.wantRefresh, tt.wantSet)
+			}
+			if tt.wantSet && cache.set.Penalty != conf.Cancellation.Points {
+				t.Errorf("penalty = %v, want %v", cache.set.Penalty, conf.Cancellation.Points)
+			}
+			if !tt.wantRefresh {
+				return
+			}
+			d := cache.refreshed
+			if d.Rating.Average != tt.wantAverage {
+				t.Errorf("average = %v, want %v", d.Rating.Average, tt.wantAverage)
+			}
+			if (d.IneligibleUntil == nil) != (tt.wantUntil == nil) ||
+				(tt.wantUntil != nil && !d.IneligibleUntil.Equal(*tt.wantUntil)) {
+				t.Errorf("ineligible until = %v, want %v", d.IneligibleUntil, tt.wantUntil)
+			}
+			if d.Eligible(occurred) == (tt.wantUntil != nil) {
+				t.Errorf("eligible = %v, want %v", d.Eligible(occurred), tt.wantUntil == nil)
+			}
+			if !cache.marked {
+				t.Error("penalty not marked applied")
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+type mockCacheRepository struct {
+	applied   bool
+	marked    bool
+	refreshed *driver.Driver
+	set       *driver.Driver
+}
+
+func (m *mockCacheRepository) SetDriverRating(ctx context.Context, d driver.Driver) error {
+	m.set = &d
+	return nil
+}
+
+func (m *mockCacheRepository) RefreshLeaderboard(ctx context.Context, d driver.Driver) error {
+	m.refreshed = &d
+	return nil
+}
+
+func (m *mockCacheRepository) IsPenaltyApplied(ctx context.Context, id string) (bool, error) {
+	return m.applied, nil
+}
+
+func (m *mockCacheRepository) SetPenaltyApplied(ctx context.Context, id string, ttl time.Duration) error {
+	m.marked = true
+	return nil
+}
+
+type mockUserRepository struct {
+	GetDriverFn func(ctx context.Context, id string) (driver.Driver, error)
+}
+
+func (m *mockUserRepository) GetDriver(ctx context.Context, id string) (driver.Driver, error) {
+	return m.GetDriverFn(ctx, id)
+}
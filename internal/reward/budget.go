@@ -0,0 +1,257 @@
+package reward
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+)
+
+// Budget alert metrics.
+const (
+	MetricCommitted = "committed"
+	MetricProjected = "projected"
+)
+
+// ZoneCost is the cost of the entitlements of a zone in a status.
+type ZoneCost struct {
+	Zone   string
+	Status string
+	Cost   float64
+}
+
+// Spend represents the reward costs against the budget of a campaign or a
+// campaign zone.
+type Spend struct {
+	Zone   string  `json:"zone,omitempty"`
+	Budget float64 `json:"budget"`
+	// Committed is the cost of approved and paid entitlements.
+	Committed float64 `json:"committed"`
+	Pending   float64 `json:"pending"`
+	// Current is the cost earned so far in the open period by the live
+	// leaderboard, not counting the standings frozen last.
+	Current float64 `json:"current"`
+	// Projected is committed, pending and current cost.
+	Projected float64 `json:"projected"`
+	// CommittedPercent and ProjectedPercent of the budget, zero without
+	// budget.
+	CommittedPercent float64 `json:"committed_percent"`
+	ProjectedPercent float64 `json:"projected_percent"`
+}
+
+func (s *Spend) add(o Spend) {
+	s.Committed += o.Committed
+	s.Pending += o.Pending
+	s.Current += o.Current
+}
+
+func (s *Spend) complete() {
+	s.Projected = s.Committed + s.Pending + s.Current
+	if s.Budget > 0 {
+		s.CommittedPercent = s.Committed / s.Budget * 100
+		s.ProjectedPercent = s.Projected / s.Budget * 100
+	}
+}
+
+func (s Spend) percent(metric string) float64 {
+	if metric == MetricCommitted {
+		return s.CommittedPercent
+	}
+	return s.ProjectedPercent
+}
+
+func (s Spend) spend(metric string) float64 {
+	if metric == MetricCommitted {
+		return s.Committed
+	}
+	return s.Projected
+}
+
+// BudgetReport represents the spend of a campaign in total and per zone.
+type BudgetReport struct {
+	CampaignID string    `json:"campaign_id"`
+	Name       string    `json:"name"`
+	Period     string    `json:"period"`
+	Total      Spend     `json:"total"`
+	Zones      []Spend   `json:"zones"`
+	CheckedAt  time.Time `json:"checked_at"`
+}
+
+// BudgetAlert is published once per campaign zone, metric, threshold and
+// budget when the spend crosses the threshold percent. Empty zone is the
+// campaign total.
+type BudgetAlert struct {
+	CampaignID string    `json:"campaign_id"`
+	Name       string    `json:"name"`
+	Zone       string    `json:"zone,omitempty"`
+	Metric     string    `json:"metric"`
+	Threshold  int       `json:"threshold"`
+	Spend      float64   `json:"spend"`
+	Budget     float64   `json:"budget"`
+	AlertedAt  time.Time `json:"alerted_at"`
+}
+
+// Budget returns the spend of the campaign against its budgets.
+func (s Service) Budget(ctx context.Context, campaignID string) (BudgetReport, error) {
+	c, err := s.repo.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return BudgetReport{}, err
+	}
+	return s.budget(ctx, c)
+}
+
+// Budgets returns the spend of the active campaigns.
+func (s Service) Budgets(ctx context.Context) ([]BudgetReport, error) {
+	campaigns, err := s.repo.ListCampaigns(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]BudgetReport, 0, len(campaigns))
+	for _, c := range campaigns {
+		r, err := s.budget(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+func (s Service) budget(ctx context.Context, c Campaign) (BudgetReport, error) {
+	costs, err := s.repo.SumEntitlementCosts(ctx, c.ID)
+	if err != nil {
+		return BudgetReport{}, err
+	}
+
+	now := time.Now()
+	loc := s.config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	r := BudgetReport{
+		CampaignID: c.ID,
+		Name:       c.Name,
+		Period:     award.Period(now.In(loc)),
+		Total:      Spend{Budget: c.Budget},
+		CheckedAt:  now,
+	}
+	for _, zone := range c.zones() {
+		spend := Spend{Zone: zone, Budget: c.ZoneBudgets[zone]}
+		for _, zc := range costs {
+			if zc.Zone != zone {
+				continue
+			}
+			switch zc.Status {
+			case StatusApproved, StatusPaid:
+				spend.Committed += zc.Cost
+			case StatusPending:
+				spend.Pending += zc.Cost
+			}
+		}
+
+		spend.Current, err = s.current(ctx, c, r.Period, zone)
+		if err != nil {
+			return BudgetReport{}, err
+		}
+
+		spend.complete()
+		r.Total.add(spend)
+		r.Zones = append(r.Zones, spend)
+	}
+	r.Total.complete()
+	return r, nil
+}
+
+// current returns the cost of the live leaderboard of the open period zone
+// beyond the cost of the standings frozen last. The leaderboard is not reset
+// when a period closes, the frozen standings are already counted by their
+// entitlements.
+func (s Service) current(ctx context.Context, c Campaign, period, zone string) (float64, error) {
+	frozen, ok, err := s.repo.LastStandings(ctx, zone)
+	if err != nil {
+		return 0, err
+	}
+	if ok && frozen.Period >= period {
+		return 0, nil
+	}
+	frozenCosts := map[string]float64{}
+	for _, e := range Evaluate(c, frozen) {
+		frozenCosts[e.DriverID] += e.Reward.Cost
+	}
+
+	standings, err := s.standings(ctx, period, zone)
+	if err != nil {
+		return 0, err
+	}
+	var current float64
+	for _, e := range Evaluate(c, standings) {
+		if cost := e.Reward.Cost - frozenCosts[e.DriverID]; cost > 0 {
+			current += cost
+		}
+	}
+	return current, nil
+}
+
+// MonitorBudgets alerts campaigns and zones whose committed or projected
+// spend crossed a threshold of the budget.
+func (s Service) MonitorBudgets(ctx context.Context) error {
+	reports, err := s.Budgets(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, r := range reports {
+		for _, spend := range append([]Spend{r.Total}, r.Zones...) {
+			if spend.Budget <= 0 {
+				continue
+			}
+			for _, metric := range []string{MetricCommitted, MetricProjected} {
+				for _, threshold := range s.config.BudgetThresholds {
+					if spend.percent(metric) < float64(threshold) {
+						continue
+					}
+					err := s.alert(ctx, BudgetAlert{
+						CampaignID: r.CampaignID,
+						Name:       r.Name,
+						Zone:       spend.Zone,
+						Metric:     metric,
+						Threshold:  threshold,
+						Spend:      spend.spend(metric),
+						Budget:     spend.Budget,
+						AlertedAt:  r.CheckedAt,
+					})
+					if err != nil {
+						errs = append(errs, err)
+					}
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// alert saves the alert as unpublished before publishing it, alerts failing
+// to publish are published on the next check.
+func (s Service) alert(ctx context.Context, a BudgetAlert) error {
+	ok, err := s.repo.SaveBudgetAlert(ctx, a)
+	if err != nil || !ok {
+		return err
+	}
+
+	s.logger.WarnContext(ctx, "campaign budget threshold crossed",
+		"campaign_id", a.CampaignID, "zone", a.Zone, "metric", a.Metric,
+		"threshold", a.Threshold, "spend", a.Spend, "budget", a.Budget)
+	b, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	if err = s.events.ProduceSync(ctx, []byte(a.CampaignID), b, stream.BudgetAlertTopic); err != nil {
+		return fmt.Errorf("failed to publish budget alert: %w", err)
+	}
+	return s.repo.MarkBudgetAlertPublished(ctx, a, time.Now())
+}
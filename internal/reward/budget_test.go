@@ -0,0 +1,212 @@
+package reward
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+)
+
+func TestService_MonitorBudgets(t *testing.T) {
+	entry := func(id string, score float64) leaderboard.Score {
+		return leaderboard.Score{DriverID: id, Score: score}
+	}
+	campaign := Campaign{ID: "campaign-1", Name: "Top drivers", Zones: []string{"MNL", "CEB"}, Active: true,
+		Budget: 10000, ZoneBudgets: map[string]float64{"MNL": 5000},
+		Bands: []Band{{MaxRank: 1, Reward: Reward{Type: TypeCash, Amount: 1000}}},
+	}
+
+	tests := []struct {
+		name  string
+		costs []ZoneCost
+		// alerted are the alerts already published by zone, metric and threshold.
+		alerted []string
+		want    []string
+	}{
+		{
+			"below thresholds",
+			[]ZoneCost{{Zone: "MNL", Status: StatusPaid, Cost: 2000}},
+			nil,
+			nil,
+		},
+		{
+			"zone projected crosses thresholds",
+			[]ZoneCost{{Zone: "MNL", Status: StatusApproved, Cost: 3000}, {Zone: "MNL", Status: StatusPending, Cost: 1000}},
+			nil,
+			[]string{"MNL:projected:80", "MNL:projected:100"},
+		},
+		{
+			"rejected entitlements are not spent",
+			[]ZoneCost{{Zone: "MNL", Status: StatusRejected, Cost: 9000}},
+			nil,
+			nil,
+		},
+		{
+			"alerted once",
+			[]ZoneCost{{Zone: "MNL", Status: StatusPaid, Cost: 4000}, {Zone: "CEB", Status: StatusPaid, Cost: 4000}},
+			[]string{"MNL:committed:80", "MNL:projected:80", "MNL:projected:100"},
+			[]string{":committed:80", ":projected:80", ":projected:100"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := &mockLeaderboard{boards: map[string][]leaderboard.Score{
+				"MNL": {entry("driver-1", 4.8)},
+				"CEB": {entry("driver-2", 4.5)},
+			}}
+			alerted := map[string]bool{}
+			for _, key := range tt.alerted {
+				alerted[key] = true
+			}
+			repo := &mockRepository{
+				campaigns: []Campaign{campaign},
+				SumEntitlementCostsFn: func(ctx context.Context, campaignID string) ([]ZoneCost, error) {
+					return tt.costs, nil
+				},
+				SaveBudgetAlertFn: func(ctx context.Context, a BudgetAlert) (bool, error) {
+					return !alerted[budgetAlertKey(a)], nil
+				},
+				MarkBudgetAlertPublishedFn: func(ctx context.Context, a BudgetAlert, at time.Time) error {
+					alerted[budgetAlertKey(a)] = true
+					return nil
+				},
+			}
+			var got []string
+			events := &mockEventWriter{ProduceSyncFn: func(ctx context.Context, key, value []byte, topic ...string) error {
+				if topic[0] != stream.BudgetAlertTopic {
+					t.Errorf("topic = %s, want %s", topic[0], stream.BudgetAlertTopic)
+				}
+				var a BudgetAlert
+				if err := json.Unmarshal(value, &a); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, budgetAlertKey(a))
+				return nil
+			}}
+			s := NewService(Config{BudgetThresholds: []int{80, 100}}, repo, lb, events, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			if err := s.MonitorBudgets(context.Background()); err != nil {
+				t.Fatalf("MonitorBudgets() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("alerts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestService_Budget(t *testing.T) {
+	now := time.Now()
+	open, last := award.Period(now), award.Period(now.AddDate(0, 0, -7))
+	standings := func(period string, drivers ...string) Standings {
+		st := Standings{Period: period, Zone: "MNL"}
+		for i, id := range drivers {
+			st.Drivers = append(st.Drivers, Standing{DriverID: id, Rank: i + 1})
+		}
+		return st
+	}
+	campaign := Campaign{ID: "campaign-1", Zones: []string{"MNL"}, Active: true, Budget: 10000,
+		Bands: []Band{{MaxRank: 1, Reward: Reward{Type: TypeCash, Amount: 1000}}},
+	}
+
+	tests := []struct {
+		name        string
+		frozen      []Standings
+		wantCurrent float64
+	}{
+		{"no standings frozen", nil, 1000},
+		{"live leaderboard unchanged since last close", []Standings{standings(last, "driver-1", "driver-2")}, 0},
+		{"live leaderboard changed since last close", []Standings{standings(last, "driver-2", "driver-1")}, 1000},
+		{"open period frozen", []Standings{standings(last, "driver-2"), standings(open, "driver-2")}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb := &mockLeaderboard{boards: map[string][]leaderboard.Score{
+				"MNL": {{DriverID: "driver-1"}, {DriverID: "driver-2"}},
+			}}
+			repo := &mockRepository{
+				campaigns: []Campaign{campaign},
+				standings: map[string]Standings{},
+				SumEntitlementCostsFn: func(ctx context.Context, campaignID string) ([]ZoneCost, error) {
+					return []ZoneCost{{Zone: "MNL", Status: StatusPending, Cost: 1000}}, nil
+				},
+			}
+			for _, st := range tt.frozen {
+				repo.standings[st.Period+":"+st.Zone] = st
+			}
+			s := NewService(Config{}, repo, lb, &mockEventWriter{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			r, err := s.Budget(context.Background(), campaign.ID)
+			if err != nil {
+				t.Fatalf("Budget() error = %v", err)
+			}
+			if r.Total.Current != tt.wantCurrent {
+				t.Errorf("current = %v, want %v", r.Total.Current, tt.wantCurrent)
+			}
+			if want := 1000 + tt.wantCurrent; r.Total.Projected != want {
+				t.Errorf("projected = %v, want %v", r.Total.Projected, want)
+			}
+		})
+	}
+}
+
+func TestService_MonitorBudgets_RetriesFailedPublish(t *testing.T) {
+	campaign := Campaign{ID: "campaign-1", Name: "Top drivers", Zones: []string{"MNL"}, Active: true,
+		ZoneBudgets: map[string]float64{"MNL": 5000},
+	}
+	published := map[string]bool{}
+	repo := &mockRepository{
+		campaigns: []Campaign{campaign},
+		SumEntitlementCostsFn: func(ctx context.Context, campaignID string) ([]ZoneCost, error) {
+			return []ZoneCost{{Zone: "MNL", Status: StatusPaid, Cost: 5000}}, nil
+		},
+		SaveBudgetAlertFn: func(ctx context.Context, a BudgetAlert) (bool, error) {
+			return !published[budgetAlertKey(a)], nil
+		},
+		MarkBudgetAlertPublishedFn: func(ctx context.Context, a BudgetAlert, at time.Time) error {
+			published[budgetAlertKey(a)] = true
+			return nil
+		},
+	}
+	var produced int
+	produceErr := errors.New("message timed out")
+	events := &mockEventWriter{ProduceSyncFn: func(ctx context.Context, key, value []byte, topic ...string) error {
+		produced++
+		return produceErr
+	}}
+	s := NewService(Config{BudgetThresholds: []int{100}}, repo, &mockLeaderboard{}, events, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := s.MonitorBudgets(context.Background()); !errors.Is(err, produceErr) {
+		t.Fatalf("MonitorBudgets() error = %v, want %v", err, produceErr)
+	}
+	if len(published) > 0 {
+		t.Fatalf("published = %v, want none after failed publish", published)
+	}
+
+	produceErr = nil
+	for i := 0; i < 2; i++ {
+		if err := s.MonitorBudgets(context.Background()); err != nil {
+			t.Fatalf("MonitorBudgets() error = %v", err)
+		}
+	}
+	// committed and projected alerts are published once after the failed run.
+	if produced != 4 {
+		t.Errorf("produced = %d, want 4", produced)
+	}
+	if !published["MNL:committed:100"] || !published["MNL:projected:100"] {
+		t.Errorf("published = %v, want committed and projected alerts", published)
+	}
+}
+
+func budgetAlertKey(a BudgetAlert) string {
+	return a.Zone + ":" + a.Metric + ":" + strconv.Itoa(a.Threshold)
+}
@@ -0,0 +1,169 @@
+package reward
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+// Entitlement statuses of the payout ledger.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusPaid     = "paid"
+	StatusFailed   = "failed"
+)
+
+// maxPayouts is the number of unpublished payouts published per run.
+const maxPayouts = 500
+
+// ErrInvalidTransition is returned when an entitlement can not be moved to
+// the status, no entitlement of the request is updated.
+var ErrInvalidTransition = errors.New("invalid entitlement status transition")
+
+// ErrInvalidRequest is returned for status updates without ids or reason.
+var ErrInvalidRequest = errors.New("invalid entitlement status update")
+
+// transitions are the statuses each status can be moved from. Failed
+// payouts are approved again to retry as a new payout attempt.
+var transitions = map[string][]string{
+	StatusApproved: {StatusPending, StatusFailed},
+	StatusRejected: {StatusPending},
+	StatusPaid:     {StatusApproved},
+	StatusFailed:   {StatusApproved},
+}
+
+// Transition represents a bulk status change of entitlements.
+type Transition struct {
+	IDs    []string `json:"ids"`
+	Status string   `json:"-"`
+	Reason string   `json:"reason"`
+	// Actor is the user id changing the status.
+	Actor string `json:"-"`
+	// From are the statuses the entitlements can be moved from.
+	From []string `json:"-"`
+}
+
+// AuditEntry represents a status change of an entitlement.
+type AuditEntry struct {
+	ID            int64     `json:"id"`
+	EntitlementID string    `json:"entitlement_id"`
+	FromStatus    string    `json:"from_status"`
+	ToStatus      string    `json:"to_status"`
+	Reason        string    `json:"reason,omitempty"`
+	Actor         string    `json:"actor"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Payout is the event published for approved entitlements, consumers pay
+// once per payout id. Failed payouts approved again are published as a new
+// attempt with a new payout id.
+type Payout struct {
+	PayoutID      string    `json:"payout_id"`
+	Attempt       int       `json:"attempt"`
+	EntitlementID string    `json:"entitlement_id"`
+	CampaignID    string    `json:"campaign_id"`
+	Period        string    `json:"period"`
+	Zone          string    `json:"zone"`
+	DriverID      string    `json:"driver_id"`
+	Reward        Reward    `json:"reward"`
+	ApprovedAt    time.Time `json:"approved_at"`
+}
+
+// EventWriter publishes events to the stream.
+type EventWriter interface {
+	// ProduceSync returns after the event is delivered.
+	ProduceSync(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error
+}
+
+// UpdateStatus moves the entitlements to the status with an audit entry of
+// the actor. Rejections require a reason, approved entitlements are
+// published as payouts.
+func (s Service) UpdateStatus(ctx context.Context, t Transition) ([]Entitlement, error) {
+	from, ok := transitions[t.Status]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidTransition, t.Status)
+	}
+	if len(t.IDs) == 0 {
+		return nil, fmt.Errorf("%w: ids required", ErrInvalidRequest)
+	}
+	if t.Status == StatusRejected && t.Reason == "" {
+		return nil, fmt.Errorf("%w: reason required to reject", ErrInvalidRequest)
+	}
+	t.From = from
+	slices.Sort(t.IDs)
+	t.IDs = slices.Compact(t.IDs)
+
+	list, err := s.repo.UpdateEntitlementStatus(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "entitlements updated",
+		"status", t.Status, "entitlements", len(list), "actor", t.Actor)
+
+	if t.Status == StatusApproved {
+		// Payouts not published are retried by PublishPayouts.
+		if err = s.publish(ctx, list); err != nil {
+			s.logger.ErrorContext(ctx, "failed to publish payouts", "error", err)
+		}
+	}
+	return list, nil
+}
+
+func (s Service) ListAudit(ctx context.Context, entitlementID string) ([]AuditEntry, error) {
+	return s.repo.ListAudit(ctx, entitlementID)
+}
+
+// PublishPayouts publishes approved entitlements not published yet.
+func (s Service) PublishPayouts(ctx context.Context) error {
+	list, err := s.repo.ListUnpublishedPayouts(ctx, maxPayouts)
+	if err != nil {
+		return err
+	}
+	return s.publish(ctx, list)
+}
+
+func (s Service) publish(ctx context.Context, list []Entitlement) error {
+	var errs []error
+	for _, e := range list {
+		b, err := json.Marshal(Payout{
+			PayoutID:      payoutID(e),
+			Attempt:       e.PayoutAttempt,
+			EntitlementID: e.ID,
+			CampaignID:    e.CampaignID,
+			Period:        e.Period,
+			Zone:          e.Zone,
+			DriverID:      e.DriverID,
+			Reward:        e.Reward,
+			ApprovedAt:    e.UpdatedAt,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = s.events.ProduceSync(ctx, []byte(e.ID), b, stream.RewardPayoutTopic); err != nil {
+			errs = append(errs, fmt.Errorf("failed to publish payout %s: %w", e.ID, err))
+			continue
+		}
+		if err = s.repo.MarkPublished(ctx, e.ID, time.Now()); err != nil {
+			// A later run publishes the rest.
+			if errors.Is(err, worker.ErrStaleLease) {
+				return errors.Join(append(errs, err)...)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// payoutID is the id of the payout attempt of the entitlement.
+func payoutID(e Entitlement) string {
+	return fmt.Sprintf("%s-%d", e.ID, e.PayoutAttempt)
+}
@@ -0,0 +1,218 @@
+package reward
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Reward types.
+const (
+	TypeCash               = "cash"
+	TypeCommissionDiscount = "commission_discount"
+	TypeVoucher            = "voucher"
+)
+
+// ErrCampaignNotFound is returned when no campaign has the id.
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// Config holds the reward period close schedule.
+type Config struct {
+	Schedule string
+	Location *time.Location
+	// PayoutSchedule is the cron expression of publishing approved payouts
+	// not published yet.
+	PayoutSchedule string
+	// BudgetInterval is the interval of monitoring campaign budgets.
+	BudgetInterval time.Duration
+	// BudgetThresholds are the percents of the budget alerted once crossed.
+	BudgetThresholds []int
+}
+
+// Reward represents the prize of a band.
+type Reward struct {
+	Type string `json:"type"`
+	// Amount is the cash amount, commission discount percent or number of
+	// rides of the voucher.
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency,omitempty"`
+	Description string  `json:"description,omitempty"`
+	// Cost is the cost of the reward counted against the campaign budget,
+	// cash rewards without cost cost the amount.
+	Cost float64 `json:"cost,omitempty"`
+}
+
+func (r Reward) cost() float64 {
+	if r.Cost == 0 && r.Type == TypeCash {
+		return r.Amount
+	}
+	return r.Cost
+}
+
+// Band represents a reward earned by rank range or score threshold.
+type Band struct {
+	// Zone limits the band to a zone, empty applies to all campaign zones.
+	Zone string `json:"zone,omitempty"`
+	// MinRank and MaxRank is the inclusive rank range, zero MaxRank matches
+	// any rank.
+	MinRank int `json:"min_rank,omitempty"`
+	MaxRank int `json:"max_rank,omitempty"`
+	// MinScore is the minimum leaderboard score, zero matches any score.
+	MinScore float64 `json:"min_score,omitempty"`
+	Reward   Reward  `json:"reward"`
+}
+
+func (b Band) matches(zone string, rank int, score float64) bool {
+	if b.Zone != "" && b.Zone != zone {
+		return false
+	}
+	if b.MaxRank > 0 && (rank < max(b.MinRank, 1) || rank > b.MaxRank) {
+		return false
+	}
+	return score >= b.MinScore
+}
+
+// Campaign represents the reward bands evaluated at each period close.
+type Campaign struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Zones are the leaderboard scopes evaluated, empty evaluates the
+	// leaderboard of drivers without service zone.
+	Zones []string `json:"zones"`
+	// Bands are matched in order, a driver earns the first matching band.
+	Bands []Band `json:"bands"`
+	// Budget is the total cost budget of the campaign, zero is unlimited.
+	Budget float64 `json:"budget,omitempty"`
+	// ZoneBudgets are the cost budgets of campaign zones.
+	ZoneBudgets map[string]float64 `json:"zone_budgets,omitempty"`
+	Active      bool               `json:"active"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// Validate checks the campaign has bands with a rank range or score
+// threshold and a known reward.
+func (c Campaign) Validate() error {
+	if c.Name == "" {
+		return errors.New("campaign name required")
+	}
+	if len(c.Bands) == 0 {
+		return errors.New("campaign bands required")
+	}
+	for i, b := range c.Bands {
+		if b.MaxRank <= 0 && b.MinScore <= 0 {
+			return fmt.Errorf("band %d: rank range or score threshold required", i)
+		}
+		if b.MaxRank > 0 && b.MinRank > b.MaxRank {
+			return fmt.Errorf("band %d: min rank above max rank", i)
+		}
+		switch b.Reward.Type {
+		case TypeCash, TypeCommissionDiscount, TypeVoucher:
+		default:
+			return fmt.Errorf("band %d: unknown reward type %q", i, b.Reward.Type)
+		}
+		if b.Reward.Amount <= 0 {
+			return fmt.Errorf("band %d: reward amount must be positive", i)
+		}
+		if b.Reward.Cost < 0 || (c.budgeted() && b.Reward.cost() == 0) {
+			return fmt.Errorf("band %d: reward cost required for campaign budget", i)
+		}
+	}
+	if c.Budget < 0 {
+		return errors.New("campaign budget must not be negative")
+	}
+	for zone, budget := range c.ZoneBudgets {
+		if budget < 0 {
+			return fmt.Errorf("zone %q: budget must not be negative", zone)
+		}
+		if !slices.Contains(c.zones(), zone) {
+			return fmt.Errorf("zone %q: budget of zone not in campaign", zone)
+		}
+	}
+	return nil
+}
+
+func (c Campaign) budgeted() bool {
+	return c.Budget > 0 || len(c.ZoneBudgets) > 0
+}
+
+func (c Campaign) zones() []string {
+	if len(c.Zones) == 0 {
+		return []string{""}
+	}
+	return c.Zones
+}
+
+// Standing represents the rank and score of a driver at period close.
+type Standing struct {
+	DriverID string  `json:"driver_id"`
+	Rank     int     `json:"rank"`
+	Score    float64 `json:"score"`
+}
+
+// Standings represents the leaderboard of a zone frozen at period close.
+type Standings struct {
+	Period   string     `json:"period"`
+	Zone     string     `json:"zone"`
+	Drivers  []Standing `json:"drivers"`
+	FrozenAt time.Time  `json:"frozen_at"`
+}
+
+// Entitlement represents a reward earned by a driver in a period.
+type Entitlement struct {
+	ID         string  `json:"id"`
+	CampaignID string  `json:"campaign_id"`
+	Period     string  `json:"period"`
+	Zone       string  `json:"zone"`
+	DriverID   string  `json:"driver_id"`
+	Rank       int     `json:"rank"`
+	Score      float64 `json:"score"`
+	Reward     Reward  `json:"reward"`
+	Status     string  `json:"status"`
+	// Reason is the reason of the last status change.
+	Reason string `json:"reason,omitempty"`
+	// PayoutAttempt counts the approvals, failed payouts approved again are
+	// paid as a new attempt.
+	PayoutAttempt int `json:"payout_attempt"`
+	// PublishedAt is set when the payout of the approved entitlement is
+	// published.
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// EntitlementFilter filters listed entitlements, empty fields match all.
+type EntitlementFilter struct {
+	CampaignID string
+	Period     string
+	Zone       string
+	DriverID   string
+	Status     string
+	Limit      int
+}
+
+// Evaluate returns the entitlements earned in the campaign by the standings.
+func Evaluate(c Campaign, s Standings) []Entitlement {
+	var list []Entitlement
+	for _, d := range s.Drivers {
+		for _, b := range c.Bands {
+			if !b.matches(s.Zone, d.Rank, d.Score) {
+				continue
+			}
+			r := b.Reward
+			r.Cost = r.cost()
+			list = append(list, Entitlement{
+				CampaignID: c.ID,
+				Period:     s.Period,
+				Zone:       s.Zone,
+				DriverID:   d.DriverID,
+				Rank:       d.Rank,
+				Score:      d.Score,
+				Reward:     r,
+				Status:     StatusPending,
+			})
+			break
+		}
+	}
+	return list
+}
@@ -0,0 +1,97 @@
+package reward
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	cash := Reward{Type: TypeCash, Amount: 1000, Currency: "PHP"}
+	discount := Reward{Type: TypeCommissionDiscount, Amount: 15}
+	voucher := Reward{Type: TypeVoucher, Amount: 3}
+	standings := func(zone string) Standings {
+		return Standings{Period: "2024-W19", Zone: zone, Drivers: []Standing{
+			{DriverID: "driver-1", Rank: 1, Score: 4.8},
+			{DriverID: "driver-2", Rank: 2, Score: 4.1},
+			{DriverID: "driver-3", Rank: 3, Score: 3.9},
+			{DriverID: "driver-4", Rank: 11, Score: 4.5},
+		}}
+	}
+	entitlement := func(zone string, s Standing, r Reward) Entitlement {
+		r.Cost = r.cost()
+		return Entitlement{CampaignID: "campaign-1", Period: "2024-W19", Zone: zone, DriverID: s.DriverID, Rank: s.Rank, Score: s.Score, Reward: r, Status: StatusPending}
+	}
+
+	tests := []struct {
+		name  string
+		bands []Band
+		zone  string
+		want  []int
+		// rewards are the rewards of the wanted standings.
+		rewards []Reward
+	}{
+		{
+			"first matching band by rank",
+			[]Band{{MinRank: 1, MaxRank: 1, Reward: cash}, {MinRank: 2, MaxRank: 10, Reward: voucher}},
+			"MNL",
+			[]int{0, 1, 2},
+			[]Reward{cash, voucher, voucher},
+		},
+		{
+			"score threshold",
+			[]Band{{MaxRank: 1, Reward: cash}, {MinScore: 4, Reward: discount}},
+			"MNL",
+			[]int{0, 1, 3},
+			[]Reward{cash, discount, discount},
+		},
+		{
+			"band of other zone",
+			[]Band{{Zone: "CEB", MaxRank: 10, Reward: cash}, {Zone: "MNL", MaxRank: 1, Reward: voucher}},
+			"MNL",
+			[]int{0},
+			[]Reward{voucher},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := standings(tt.zone)
+			var want []Entitlement
+			for i, n := range tt.want {
+				want = append(want, entitlement(tt.zone, s.Drivers[n], tt.rewards[i]))
+			}
+
+			got := Evaluate(Campaign{ID: "campaign-1", Bands: tt.bands}, s)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Evaluate() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestCampaign_Validate(t *testing.T) {
+	cash := Reward{Type: TypeCash, Amount: 1000}
+	voucher := Reward{Type: TypeVoucher, Amount: 3}
+	tests := []struct {
+		name    string
+		bands   []Band
+		budget  float64
+		wantErr bool
+	}{
+		{"valid", []Band{{MaxRank: 10, Reward: cash}, {MinScore: 4, Reward: cash}}, 0, false},
+		{"no bands", nil, 0, true},
+		{"band without condition", []Band{{Reward: cash}}, 0, true},
+		{"inverted rank range", []Band{{MinRank: 5, MaxRank: 1, Reward: cash}}, 0, true},
+		{"unknown reward", []Band{{MaxRank: 1, Reward: Reward{Type: "points", Amount: 1}}}, 0, true},
+		{"budget with cash cost", []Band{{MaxRank: 1, Reward: cash}}, 50000, false},
+		{"budget without voucher cost", []Band{{MaxRank: 1, Reward: voucher}}, 50000, true},
+		{"negative budget", []Band{{MaxRank: 1, Reward: cash}}, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Campaign{Name: "Top drivers", Bands: tt.bands, Budget: tt.budget}.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
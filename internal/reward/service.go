@@ -0,0 +1,162 @@
+package reward
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+)
+
+// Service represents reward rules service.
+type Service struct {
+	config      Config
+	repo        Repository
+	leaderboard LeaderboardService
+	events      EventWriter
+	logger      *slog.Logger
+}
+
+type LeaderboardService interface {
+	// GetScores returns the scores of every driver of the leaderboard,
+	// highest score first.
+	GetScores(ctx context.Context, scope string) ([]leaderboard.Score, error)
+}
+
+// Repository manages postgres storage operations of campaigns and
+// entitlements.
+type Repository interface {
+	CreateCampaign(ctx context.Context, c Campaign) (Campaign, error)
+	GetCampaign(ctx context.Context, id string) (Campaign, error)
+	ListCampaigns(ctx context.Context, activeOnly bool) ([]Campaign, error)
+	// FreezeStandings saves the standings when the period zone has none and
+	// returns the saved standings.
+	FreezeStandings(ctx context.Context, s Standings) (Standings, error)
+	// LastStandings returns the standings of the zone frozen last, false
+	// when the zone has none.
+	LastStandings(ctx context.Context, zone string) (Standings, bool, error)
+	// SaveEntitlements skips entitlements already saved for the campaign,
+	// period, zone and driver, returns the number saved.
+	SaveEntitlements(ctx context.Context, list []Entitlement) (int, error)
+	ListEntitlements(ctx context.Context, f EntitlementFilter) ([]Entitlement, error)
+	// UpdateEntitlementStatus moves all the entitlements from the statuses
+	// of the transition in a transaction with audit entries, returns
+	// ErrInvalidTransition when any is not found or in another status.
+	UpdateEntitlementStatus(ctx context.Context, t Transition) ([]Entitlement, error)
+	ListAudit(ctx context.Context, entitlementID string) ([]AuditEntry, error)
+	ListUnpublishedPayouts(ctx context.Context, limit int) ([]Entitlement, error)
+	MarkPublished(ctx context.Context, id string, at time.Time) error
+	// SumEntitlementCosts returns the reward costs of the campaign
+	// entitlements by zone and status.
+	SumEntitlementCosts(ctx context.Context, campaignID string) ([]ZoneCost, error)
+	// SaveBudgetAlert returns false when the alert was already published for
+	// the campaign zone, metric, threshold and budget.
+	SaveBudgetAlert(ctx context.Context, a BudgetAlert) (bool, error)
+	MarkBudgetAlertPublished(ctx context.Context, a BudgetAlert, at time.Time) error
+}
+
+// NewService returns new reward service.
+func NewService(conf Config, r Repository, lb LeaderboardService, w EventWriter, l *slog.Logger) *Service {
+	return &Service{
+		config:      conf,
+		repo:        r,
+		leaderboard: lb,
+		events:      w,
+		logger:      l,
+	}
+}
+
+// CloseLastPeriod closes the period before today.
+func (s Service) CloseLastPeriod(ctx context.Context) error {
+	loc := s.config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	return s.ClosePeriod(ctx, award.Period(time.Now().In(loc).AddDate(0, 0, -1)))
+}
+
+// ClosePeriod freezes the leaderboard of each zone of the active campaigns
+// and saves the entitlements earned. Closing the period again evaluates the
+// frozen standings and saves only entitlements not saved yet.
+func (s Service) ClosePeriod(ctx context.Context, period string) error {
+	campaigns, err := s.repo.ListCampaigns(ctx, true)
+	if err != nil {
+		return err
+	}
+
+	var zones []string
+	for _, c := range campaigns {
+		for _, z := range c.zones() {
+			if !slices.Contains(zones, z) {
+				zones = append(zones, z)
+			}
+		}
+	}
+
+	var errs []error
+	for _, zone := range zones {
+		standings, err := s.freeze(ctx, period, zone)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, c := range campaigns {
+			if !slices.Contains(c.zones(), zone) {
+				continue
+			}
+			n, err := s.repo.SaveEntitlements(ctx, Evaluate(c, standings))
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			s.logger.InfoContext(ctx, "campaign evaluated",
+				"campaign_id", c.ID, "period", period, "zone", zone, "entitlements", n)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s Service) freeze(ctx context.Context, period, zone string) (Standings, error) {
+	st, err := s.standings(ctx, period, zone)
+	if err != nil {
+		return Standings{}, err
+	}
+	return s.repo.FreezeStandings(ctx, st)
+}
+
+// standings returns the standings of every driver of the live leaderboard
+// of the zone, score threshold bands are not limited to the listed drivers.
+func (s Service) standings(ctx context.Context, period, zone string) (Standings, error) {
+	scores, err := s.leaderboard.GetScores(ctx, zone)
+	if err != nil {
+		return Standings{}, err
+	}
+	st := Standings{Period: period, Zone: zone, FrozenAt: time.Now()}
+	for i, e := range scores {
+		st.Drivers = append(st.Drivers, Standing{DriverID: e.DriverID, Rank: i + 1, Score: e.Score})
+	}
+	return st, nil
+}
+
+// CreateCampaign validates and saves the campaign.
+func (s Service) CreateCampaign(ctx context.Context, c Campaign) (Campaign, error) {
+	if err := c.Validate(); err != nil {
+		return Campaign{}, err
+	}
+	return s.repo.CreateCampaign(ctx, c)
+}
+
+func (s Service) GetCampaign(ctx context.Context, id string) (Campaign, error) {
+	return s.repo.GetCampaign(ctx, id)
+}
+
+func (s Service) ListCampaigns(ctx context.Context) ([]Campaign, error) {
+	return s.repo.ListCampaigns(ctx, false)
+}
+
+func (s Service) ListEntitlements(ctx context.Context, f EntitlementFilter) ([]Entitlement, error) {
+	return s.repo.ListEntitlements(ctx, f)
+}
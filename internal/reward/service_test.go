@@ -0,0 +1,248 @@
+package reward
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+)
+
+func TestService_ClosePeriod(t *testing.T) {
+	entry := func(id string, score float64) leaderboard.Score {
+		return leaderboard.Score{DriverID: id, Score: score}
+	}
+	lb := &mockLeaderboard{boards: map[string][]leaderboard.Score{
+		"MNL": {entry("driver-1", 4.8), entry("driver-2", 4.1)},
+	}}
+	repo := &mockRepository{
+		campaigns: []Campaign{{ID: "campaign-1", Zones: []string{"MNL"}, Active: true, Bands: []Band{
+			{MaxRank: 1, Reward: Reward{Type: TypeCash, Amount: 1000}},
+		}}},
+		standings:    map[string]Standings{},
+		entitlements: map[string]Entitlement{},
+	}
+	s := NewService(Config{}, repo, lb, &mockEventWriter{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := s.ClosePeriod(context.Background(), "2024-W19"); err != nil {
+		t.Fatalf("ClosePeriod() error = %v", err)
+	}
+	// The live leaderboard changes after close, the frozen standings are
+	// evaluated again.
+	lb.boards["MNL"] = []leaderboard.Score{entry("driver-2", 4.9), entry("driver-1", 4.8)}
+	if err := s.ClosePeriod(context.Background(), "2024-W19"); err != nil {
+		t.Fatalf("ClosePeriod() again error = %v", err)
+	}
+
+	if len(repo.entitlements) != 1 {
+		t.Fatalf("entitlements = %+v, want 1", repo.entitlements)
+	}
+	if e, ok := repo.entitlements["campaign-1:2024-W19:MNL:driver-1"]; !ok || e.Rank != 1 || e.Score != 4.8 {
+		t.Errorf("entitlements = %+v, want driver-1 rank 1", repo.entitlements)
+	}
+}
+
+type mockLeaderboard struct {
+	boards map[string][]leaderboard.Score
+}
+
+func (m *mockLeaderboard) GetScores(ctx context.Context, scope string) ([]leaderboard.Score, error) {
+	return m.boards[scope], nil
+}
+
+type mockRepository struct {
+	campaigns    []Campaign
+	standings    map[string]Standings
+	entitlements map[string]Entitlement
+	unpublished  []Entitlement
+	published    []string
+
+	UpdateEntitlementStatusFn  func(ctx context.Context, t Transition) ([]Entitlement, error)
+	SumEntitlementCostsFn      func(ctx context.Context, campaignID string) ([]ZoneCost, error)
+	SaveBudgetAlertFn          func(ctx context.Context, a BudgetAlert) (bool, error)
+	MarkBudgetAlertPublishedFn func(ctx context.Context, a BudgetAlert, at time.Time) error
+}
+
+func (m *mockRepository) CreateCampaign(ctx context.Context, c Campaign) (Campaign, error) {
+	m.campaigns = append(m.campaigns, c)
+	return c, nil
+}
+
+func (m *mockRepository) GetCampaign(ctx context.Context, id string) (Campaign, error) {
+	for _, c := range m.campaigns {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return Campaign{}, ErrCampaignNotFound
+}
+
+func (m *mockRepository) ListCampaigns(ctx context.Context, activeOnly bool) ([]Campaign, error) {
+	return m.campaigns, nil
+}
+
+func (m *mockRepository) FreezeStandings(ctx context.Context, s Standings) (Standings, error) {
+	key := s.Period + ":" + s.Zone
+	if frozen, ok := m.standings[key]; ok {
+		return frozen, nil
+	}
+	m.standings[key] = s
+	return s, nil
+}
+
+func (m *mockRepository) LastStandings(ctx context.Context, zone string) (Standings, bool, error) {
+	var (
+		last Standings
+		ok   bool
+	)
+	for _, st := range m.standings {
+		if st.Zone == zone && (!ok || st.Period > last.Period) {
+			last, ok = st, true
+		}
+	}
+	return last, ok, nil
+}
+
+func (m *mockRepository) SaveEntitlements(ctx context.Context, list []Entitlement) (int, error) {
+	saved := 0
+	for _, e := range list {
+		key := e.CampaignID + ":" + e.Period + ":" + e.Zone + ":" + e.DriverID
+		if _, ok := m.entitlements[key]; !ok {
+			m.entitlements[key] = e
+			saved++
+		}
+	}
+	return saved, nil
+}
+
+func (m *mockRepository) ListEntitlements(ctx context.Context, f EntitlementFilter) ([]Entitlement, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) UpdateEntitlementStatus(ctx context.Context, t Transition) ([]Entitlement, error) {
+	return m.UpdateEntitlementStatusFn(ctx, t)
+}
+
+func (m *mockRepository) ListAudit(ctx context.Context, entitlementID string) ([]AuditEntry, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ListUnpublishedPayouts(ctx context.Context, limit int) ([]Entitlement, error) {
+	return m.unpublished, nil
+}
+
+func (m *mockRepository) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	m.published = append(m.published, id)
+	return nil
+}
+
+func (m *mockRepository) SumEntitlementCosts(ctx context.Context, campaignID string) ([]ZoneCost, error) {
+	return m.SumEntitlementCostsFn(ctx, campaignID)
+}
+
+func (m *mockRepository) SaveBudgetAlert(ctx context.Context, a BudgetAlert) (bool, error) {
+	return m.SaveBudgetAlertFn(ctx, a)
+}
+
+func (m *mockRepository) MarkBudgetAlertPublished(ctx context.Context, a BudgetAlert, at time.Time) error {
+	if m.MarkBudgetAlertPublishedFn == nil {
+		return nil
+	}
+	return m.MarkBudgetAlertPublishedFn(ctx, a, at)
+}
+
+type mockEventWriter struct {
+	ProduceSyncFn func(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error
+}
+
+func (m *mockEventWriter) ProduceSync(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error {
+	if m.ProduceSyncFn == nil {
+		return nil
+	}
+	return m.ProduceSyncFn(ctx, key, value, optionalTopic...)
+}
+
+func TestService_UpdateStatus(t *testing.T) {
+	approved := []Entitlement{
+		{ID: "entitlement-1", DriverID: "driver-1", Status: StatusApproved, PayoutAttempt: 1},
+		// entitlement-2 failed and is approved again.
+		{ID: "entitlement-2", DriverID: "driver-2", Status: StatusApproved, PayoutAttempt: 2},
+	}
+
+	tests := []struct {
+		name          string
+		transition    Transition
+		produceErr    error
+		wantErr       error
+		wantFrom      []string
+		wantPublished []string
+		wantPayouts   []string
+	}{
+		{
+			"approve publishes payouts",
+			Transition{IDs: []string{"entitlement-2", "entitlement-1", "entitlement-1"}, Status: StatusApproved, Actor: "finance-1"},
+			nil, nil,
+			[]string{StatusPending, StatusFailed},
+			[]string{"entitlement-1", "entitlement-2"},
+			[]string{"entitlement-1-1", "entitlement-2-2"},
+		},
+		{
+			"payouts not delivered are kept for retry",
+			Transition{IDs: []string{"entitlement-1", "entitlement-2"}, Status: StatusApproved},
+			errors.New("message timed out"), nil,
+			[]string{StatusPending, StatusFailed},
+			nil,
+			nil,
+		},
+		{"reject requires reason", Transition{IDs: []string{"entitlement-1"}, Status: StatusRejected}, nil, ErrInvalidRequest, nil, nil, nil},
+		{"unknown status", Transition{IDs: []string{"entitlement-1"}, Status: StatusPending}, nil, ErrInvalidTransition, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var from []string
+			repo := &mockRepository{UpdateEntitlementStatusFn: func(ctx context.Context, tr Transition) ([]Entitlement, error) {
+				if !reflect.DeepEqual(tr.IDs, []string{"entitlement-1", "entitlement-2"}) {
+					t.Errorf("ids = %v, want sorted unique ids", tr.IDs)
+				}
+				from = tr.From
+				return approved, nil
+			}}
+			var payouts []string
+			events := &mockEventWriter{ProduceSyncFn: func(ctx context.Context, key, value []byte, topic ...string) error {
+				if topic[0] != stream.RewardPayoutTopic {
+					t.Errorf("topic = %s, want %s", topic[0], stream.RewardPayoutTopic)
+				}
+				if tt.produceErr != nil {
+					return tt.produceErr
+				}
+				var p Payout
+				if err := json.Unmarshal(value, &p); err != nil {
+					t.Fatal(err)
+				}
+				payouts = append(payouts, p.PayoutID)
+				return nil
+			}}
+			s := NewService(Config{}, repo, nil, events, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			_, err := s.UpdateStatus(context.Background(), tt.transition)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateStatus() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(from, tt.wantFrom) {
+				t.Errorf("from = %v, want %v", from, tt.wantFrom)
+			}
+			if !reflect.DeepEqual(repo.published, tt.wantPublished) {
+				t.Errorf("published = %v, want %v", repo.published, tt.wantPublished)
+			}
+			if !reflect.DeepEqual(payouts, tt.wantPayouts) {
+				t.Errorf("payouts = %v, want %v", payouts, tt.wantPayouts)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package tier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+)
+
+// maxChanges is the number of unpublished tier changes listed at once.
+const maxChanges = 5000
+
+// Service represents driver tier service.
+type Service struct {
+	config  Config
+	repo    Repository
+	drivers DriverRepository
+	events  EventWriter
+	logger  *slog.Logger
+}
+
+// Repository manages postgres storage operations of tiers.
+type Repository interface {
+	GetTier(ctx context.Context, driverID string) (a Assignment, ok bool, err error)
+	ListTiers(ctx context.Context) (map[string]Assignment, error)
+	// SaveTiers saves the assignments and the changes as tier history in a
+	// transaction, changes already saved for the driver and recalculation,
+	// by ChangedAt, are skipped. Returns the changes saved.
+	SaveTiers(ctx context.Context, list []Assignment, changes []Change) ([]Change, error)
+	ListTierHistory(ctx context.Context, driverID string) ([]Change, error)
+	// ListUnpublishedChanges returns the changes not published yet, oldest
+	// first.
+	ListUnpublishedChanges(ctx context.Context, limit int) ([]Change, error)
+	MarkChangePublished(ctx context.Context, id int64, at time.Time) error
+}
+
+// DriverRepository lists the drivers with ratings.
+type DriverRepository interface {
+	ListDrivers(ctx context.Context) ([]driver.Driver, error)
+}
+
+// EventWriter publishes events to the stream.
+type EventWriter interface {
+	// ProduceSync returns after the event is delivered.
+	ProduceSync(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error
+}
+
+// NewService returns new tier service.
+func NewService(conf Config, r Repository, d DriverRepository, w EventWriter, l *slog.Logger) *Service {
+	return &Service{
+		config:  conf,
+		repo:    r,
+		drivers: d,
+		events:  w,
+		logger:  l,
+	}
+}
+
+// RefreshTiers recalculates the tier of every driver from the RFM score with
+// recency as of now, saves the tier changes and publishes them with the
+// changes not published by previous runs.
+func (s Service) RefreshTiers(ctx context.Context) error {
+	drivers, err := s.drivers.ListDrivers(ctx)
+	if err != nil {
+		return err
+	}
+	current, err := s.repo.ListTiers(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	loc := s.config.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	period := award.Period(now.In(loc))
+
+	var (
+		list    []Assignment
+		changes []Change
+	)
+	for _, d := range drivers {
+		rfm := d.Rating.RFM
+		// Recency decays without trips, it is recalculated as of now.
+		rfm.Recency = d.CalculateRecency()
+		score := Score(rfm)
+		a := Assignment{DriverID: d.DriverID, Tier: s.config.Assign(score), Score: score, RFM: rfm, UpdatedAt: now}
+		list = append(list, a)
+
+		if prev, ok := current[d.DriverID]; !ok || prev.Tier != a.Tier {
+			changes = append(changes, Change{
+				DriverID:  d.DriverID,
+				From:      prev.Tier,
+				To:        a.Tier,
+				Score:     score,
+				RFM:       rfm,
+				Period:    period,
+				ChangedAt: now,
+			})
+		}
+	}
+	if changes, err = s.repo.SaveTiers(ctx, list, changes); err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, "tiers refreshed", "period", period, "drivers", len(list), "changes", len(changes))
+	return s.publish(ctx)
+}
+
+// publish publishes the changes not published yet and marks them published
+// once delivered. Changes of a driver after a change failing to publish are
+// left for the next run to keep the order.
+func (s Service) publish(ctx context.Context) error {
+	var (
+		errs   []error
+		failed = map[string]bool{}
+	)
+	for {
+		changes, err := s.repo.ListUnpublishedChanges(ctx, maxChanges)
+		if err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		published := 0
+		for _, c := range changes {
+			if failed[c.DriverID] {
+				continue
+			}
+			b, err := json.Marshal(c)
+			if err != nil {
+				failed[c.DriverID] = true
+				errs = append(errs, err)
+				continue
+			}
+			if err = s.events.ProduceSync(ctx, []byte(c.DriverID), b, stream.TierChangeTopic); err != nil {
+				failed[c.DriverID] = true
+				errs = append(errs, fmt.Errorf("failed to publish tier change of %s: %w", c.DriverID, err))
+				continue
+			}
+			if err = s.repo.MarkChangePublished(ctx, c.ID, time.Now()); err != nil {
+				failed[c.DriverID] = true
+				errs = append(errs, err)
+				continue
+			}
+			published++
+		}
+		// Changes left unpublished are listed again, a page publishing none
+		// is the last.
+		if len(changes) < maxChanges || published == 0 {
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// GetTier returns the current tier of the driver, drivers not assigned yet
+// are Bronze.
+func (s Service) GetTier(ctx context.Context, driverID string) (Assignment, error) {
+	a, ok, err := s.repo.GetTier(ctx, driverID)
+	if err != nil {
+		return Assignment{}, err
+	}
+	if !ok {
+		return Assignment{DriverID: driverID, Tier: Bronze}, nil
+	}
+	return a, nil
+}
+
+// ListTierHistory returns the tier changes of the driver, latest first.
+func (s Service) ListTierHistory(ctx context.Context, driverID string) ([]Change, error) {
+	return s.repo.ListTierHistory(ctx, driverID)
+}
@@ -0,0 +1,185 @@
+package tier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/stream"
+)
+
+func TestConfig_Assign(t *testing.T) {
+	c := Config{Silver: 1.5, Gold: 2.5, Platinum: 3.5}
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{0, Bronze},
+		{1.4, Bronze},
+		{1.5, Silver},
+		{2.5, Gold},
+		{3.4, Gold},
+		{4, Platinum},
+	}
+	for _, tt := range tests {
+		if got := c.Assign(tt.score); got != tt.want {
+			t.Errorf("Assign(%v) = %s, want %s", tt.score, got, tt.want)
+		}
+	}
+}
+
+func TestService_RefreshTiers(t *testing.T) {
+	active := func(id string, frequency, monetary float64) driver.Driver {
+		return driver.Driver{
+			DriverID:              id,
+			LastCompletedTripDate: time.Now(),
+			Rating:                driver.Rating{RFM: driver.RFM{Recency: 4, Frequency: frequency, Monetary: monetary}},
+		}
+	}
+	drivers := []driver.Driver{
+		active("driver-1", 4, 4),
+		active("driver-2", 1, 1),
+		// Stored recency of the last trip decays without trips.
+		{
+			DriverID:              "driver-3",
+			LastCompletedTripDate: time.Now().AddDate(0, 0, -60),
+			Rating:                driver.Rating{RFM: driver.RFM{Recency: 4, Frequency: 4, Monetary: 4}},
+		},
+		active("driver-4", 1, 1),
+	}
+	current := map[string]Assignment{
+		"driver-2": {DriverID: "driver-2", Tier: Silver},
+		"driver-3": {DriverID: "driver-3", Tier: Platinum},
+		"driver-4": {DriverID: "driver-4", Tier: Silver},
+	}
+
+	var saved []Assignment
+	repo := &mockRepository{
+		ListTiersFn: func(ctx context.Context) (map[string]Assignment, error) {
+			return current, nil
+		},
+	}
+	repo.SaveTiersFn = func(ctx context.Context, list []Assignment, changes []Change) ([]Change, error) {
+		saved = list
+		current = map[string]Assignment{}
+		for _, a := range list {
+			current[a.DriverID] = a
+		}
+		for i := range changes {
+			changes[i].ID = int64(len(repo.history) + 1)
+			repo.history = append(repo.history, changes[i])
+		}
+		return changes, nil
+	}
+	var (
+		published  []string
+		produceErr = errors.New("message timed out")
+	)
+	events := &mockEventWriter{ProduceSyncFn: func(ctx context.Context, key, value []byte, topic ...string) error {
+		if topic[0] != stream.TierChangeTopic {
+			t.Errorf("topic = %s, want %s", topic[0], stream.TierChangeTopic)
+		}
+		var c Change
+		if err := json.Unmarshal(value, &c); err != nil {
+			t.Fatal(err)
+		}
+		if c.DriverID == "driver-1" && produceErr != nil {
+			return produceErr
+		}
+		published = append(published, c.DriverID+":"+c.From+":"+c.To)
+		return nil
+	}}
+	s := NewService(Config{Silver: 1.5, Gold: 2.5, Platinum: 3.5}, repo, &mockDriverRepository{drivers}, events,
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if err := s.RefreshTiers(context.Background()); !errors.Is(err, produceErr) {
+		t.Fatalf("RefreshTiers() error = %v, want %v", err, produceErr)
+	}
+	if len(saved) != len(drivers) {
+		t.Errorf("saved = %+v, want all drivers", saved)
+	}
+	want := []string{"driver-3:platinum:gold"}
+	if !reflect.DeepEqual(published, want) {
+		t.Errorf("published = %v, want %v", published, want)
+	}
+
+	// The next run has no tier changes and publishes the change not
+	// delivered.
+	produceErr = nil
+	if err := s.RefreshTiers(context.Background()); err != nil {
+		t.Fatalf("RefreshTiers() again error = %v", err)
+	}
+	want = append(want, "driver-1::platinum")
+	if !reflect.DeepEqual(published, want) {
+		t.Errorf("published = %v, want %v", published, want)
+	}
+	if len(repo.history) != 2 {
+		t.Errorf("history = %+v, want 2 changes", repo.history)
+	}
+}
+
+type mockRepository struct {
+	GetTierFn         func(ctx context.Context, driverID string) (Assignment, bool, error)
+	ListTiersFn       func(ctx context.Context) (map[string]Assignment, error)
+	SaveTiersFn       func(ctx context.Context, list []Assignment, changes []Change) ([]Change, error)
+	ListTierHistoryFn func(ctx context.Context, driverID string) ([]Change, error)
+	// history are the saved changes, published by id.
+	history   []Change
+	published map[int64]bool
+}
+
+func (m *mockRepository) GetTier(ctx context.Context, driverID string) (Assignment, bool, error) {
+	return m.GetTierFn(ctx, driverID)
+}
+
+func (m *mockRepository) ListTiers(ctx context.Context) (map[string]Assignment, error) {
+	return m.ListTiersFn(ctx)
+}
+
+func (m *mockRepository) SaveTiers(ctx context.Context, list []Assignment, changes []Change) ([]Change, error) {
+	return m.SaveTiersFn(ctx, list, changes)
+}
+
+func (m *mockRepository) ListTierHistory(ctx context.Context, driverID string) ([]Change, error) {
+	return m.ListTierHistoryFn(ctx, driverID)
+}
+
+func (m *mockRepository) ListUnpublishedChanges(ctx context.Context, limit int) ([]Change, error) {
+	var list []Change
+	for _, c := range m.history {
+		if !m.published[c.ID] && len(list) < limit {
+			list = append(list, c)
+		}
+	}
+	return list, nil
+}
+
+func (m *mockRepository) MarkChangePublished(ctx context.Context, id int64, at time.Time) error {
+	if m.published == nil {
+		m.published = map[int64]bool{}
+	}
+	m.published[id] = true
+	return nil
+}
+
+type mockDriverRepository struct {
+	drivers []driver.Driver
+}
+
+func (m *mockDriverRepository) ListDrivers(ctx context.Context) ([]driver.Driver, error) {
+	return m.drivers, nil
+}
+
+type mockEventWriter struct {
+	ProduceSyncFn func(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error
+}
+
+func (m *mockEventWriter) ProduceSync(ctx context.Context, key []byte, value []byte, optionalTopic ...string) error {
+	return m.ProduceSyncFn(ctx, key, value, optionalTopic...)
+}
@@ -0,0 +1,83 @@
+package tier
+
+import (
+	"errors"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+// Driver tiers, lowest first.
+const (
+	Bronze   = "bronze"
+	Silver   = "silver"
+	Gold     = "gold"
+	Platinum = "platinum"
+)
+
+// Config represents the tier score thresholds and weekly recalculation.
+type Config struct {
+	// Silver, Gold and Platinum are the minimum RFM scores of the tiers,
+	// drivers below Silver are Bronze.
+	Silver   float64
+	Gold     float64
+	Platinum float64
+	// Schedule is the cron expression recalculating the tiers.
+	Schedule string
+	Location *time.Location
+}
+
+// Validate checks the thresholds are positive and ascending.
+func (c Config) Validate() error {
+	if c.Silver <= 0 || c.Gold <= c.Silver || c.Platinum <= c.Gold {
+		return errors.New("tier thresholds must be positive and ascending")
+	}
+	return nil
+}
+
+// Assign returns the tier of the RFM score.
+func (c Config) Assign(score float64) string {
+	switch {
+	case score >= c.Platinum:
+		return Platinum
+	case score >= c.Gold:
+		return Gold
+	case score >= c.Silver:
+		return Silver
+	default:
+		return Bronze
+	}
+}
+
+// Score returns the average of the recency, frequency and monetary scores,
+// from 0 to 4.
+func Score(rfm driver.RFM) float64 {
+	return (rfm.Recency + rfm.Frequency + rfm.Monetary) / 3
+}
+
+// Assignment represents the current tier of a driver.
+type Assignment struct {
+	DriverID  string     `json:"driver_id"`
+	Tier      string     `json:"tier"`
+	Score     float64    `json:"score"`
+	RFM       driver.RFM `json:"rfm"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Change represents a tier change of a driver, published to the stream and
+// kept as tier history.
+type Change struct {
+	// ID is the tier history id.
+	ID       int64  `json:"-"`
+	DriverID string `json:"driver_id"`
+	// From is empty when the driver had no tier.
+	From  string     `json:"from,omitempty"`
+	To    string     `json:"to"`
+	Score float64    `json:"score"`
+	RFM   driver.RFM `json:"rfm"`
+	// Period is the week of the recalculation, a driver can change more
+	// than once in a period.
+	Period string `json:"period"`
+	// ChangedAt is the time of the recalculation.
+	ChangedAt time.Time `json:"changed_at"`
+}
@@ -0,0 +1,138 @@
+package trip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func decodeProtobuf(b []byte) (Envelope, error) {
+	var env Envelope
+
+	// Skip message indexes, a single zero means the first message of schema.
+	n, c := protowire.ConsumeVarint(b)
+	if c < 0 {
+		return env, protowire.ParseError(c)
+	}
+	b = b[c:]
+	if n != 0 {
+		for i := 0; i < int(protowire.DecodeZigZag(n)); i++ {
+			_, c = protowire.ConsumeVarint(b)
+			if c < 0 {
+				return env, protowire.ParseError(c)
+			}
+			b = b[c:]
+		}
+	}
+
+	for len(b) > 0 {
+		num, typ, c := protowire.ConsumeTag(b)
+		if c < 0 {
+			return env, protowire.ParseError(c)
+		}
+		b = b[c:]
+
+		switch {
+		case num == 2 && typ == protowire.VarintType:
+			v, c := protowire.ConsumeVarint(b)
+			if c < 0 {
+				return env, protowire.ParseError(c)
+			}
+			env.OccurredAt = time.UnixMilli(int64(v)).UTC()
+			b = b[c:]
+		case num == 7 && typ == protowire.Fixed64Type:
+			v, c := protowire.ConsumeFixed64(b)
+			if c < 0 {
+				return env, protowire.ParseError(c)
+			}
+			env.Data.Price.DriverEarnings = math.Float64frombits(v)
+			b = b[c:]
+		case typ == protowire.BytesType && recordStrings[int(num)] != nil:
+			v, c := protowire.ConsumeString(b)
+			if c < 0 {
+				return env, protowire.ParseError(c)
+			}
+			recordStrings[int(num)](&env, v)
+			b = b[c:]
+		default:
+			// Unknown fields are skipped for forward compatibility.
+			c = protowire.ConsumeFieldValue(num, typ, b)
+			if c < 0 {
+				return env, protowire.ParseError(c)
+			}
+			b = b[c:]
+		}
+	}
+	return env, nil
+}
+
+func decodeAvro(b []byte) (Envelope, error) {
+	var env Envelope
+	r := &avroReader{b: b}
+	for field := 1; field <= 12; field++ {
+		switch field {
+		case 2:
+			env.OccurredAt = time.UnixMilli(r.long()).UTC()
+		case 7:
+			env.Data.Price.DriverEarnings = r.double()
+		default:
+			recordStrings[field](&env, r.string())
+		}
+		if r.err != nil {
+			return env, fmt.Errorf("field %d: %s", field, r.err)
+		}
+	}
+	return env, nil
+}
+
+var errAvroShortBuffer = errors.New("unexpected end of record")
+
+// avroReader reads avro binary encoded primitives.
+type avroReader struct {
+	b   []byte
+	err error
+}
+
+func (r *avroReader) long() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = errAvroShortBuffer
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *avroReader) double() float64 {
+	if r.err != nil {
+		return 0
+	}
+	if len(r.b) < 8 {
+		r.err = errAvroShortBuffer
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.b))
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *avroReader) string() string {
+	n := r.long()
+	if r.err != nil {
+		return ""
+	}
+	if n < 0 || int64(len(r.b)) < n {
+		r.err = errAvroShortBuffer
+		return ""
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
@@ -0,0 +1,97 @@
+package trip
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EventType is the envelope type of trip events.
+const EventType = "trip.status_changed"
+
+// Envelope versions, version 0 is a bare trip event without envelope.
+const (
+	VersionLegacy = 0
+	VersionV1     = 1
+)
+
+// Envelope represents a versioned trip event with its metadata.
+type Envelope struct {
+	Type       string    `json:"type"`
+	Version    int       `json:"version"`
+	ID         string    `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       Event     `json:"data"`
+}
+
+// Validate checks envelope metadata and its trip event.
+func (e Envelope) Validate() error {
+	var v ValidationError
+	if e.Type != EventType {
+		v.add("type", fmt.Sprintf("unknown event type %q", e.Type))
+	}
+	switch e.Version {
+	case VersionLegacy:
+	case VersionV1:
+		if e.ID == "" {
+			v.add("id", "required")
+		}
+		if e.OccurredAt.IsZero() {
+			v.add("occurred_at", "required")
+		}
+	default:
+		v.add("version", fmt.Sprintf("unsupported version %d", e.Version))
+	}
+
+	var ve *ValidationError
+	if err := e.Data.Validate(); errors.As(err, &ve) {
+		for _, r := range ve.Reasons {
+			v.add("data."+r.Field, r.Reason)
+		}
+	}
+	return v.err()
+}
+
+// Decoder decodes trip events from json envelopes, legacy json events and
+// schema registry framed payloads.
+type Decoder struct {
+	registry *Registry
+}
+
+// NewDecoder returns new trip event decoder that resolves framed payloads
+// schema from registry.
+func NewDecoder(r *Registry) *Decoder {
+	return &Decoder{registry: r}
+}
+
+// Decode decodes payload into envelope. Decoded envelope is not validated.
+func (d *Decoder) Decode(payload []byte) (Envelope, error) {
+	if isFramed(payload) {
+		return d.decodeFramed(payload)
+	}
+	return decodeJSON(payload)
+}
+
+func decodeJSON(payload []byte) (Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(payload, &env); err != nil {
+		return env, fmt.Errorf("json unmarshall: %s", err)
+	}
+	if env.Type != "" {
+		return env, nil
+	}
+
+	// Payloads without envelope type are legacy bare trip events.
+	var e Event
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return env, fmt.Errorf("json unmarshall: %s", err)
+	}
+	return Envelope{
+		Type:       EventType,
+		Version:    VersionLegacy,
+		ID:         e.IdempotencyKey,
+		OccurredAt: e.UpdatedAt,
+		Data:       e,
+	}, nil
+}
@@ -0,0 +1,215 @@
+package trip
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestDecoder_Decode(t *testing.T) {
+	occurred := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	want := Envelope{
+		Type:       EventType,
+		Version:    VersionV1,
+		ID:         "evt-1",
+		OccurredAt: occurred,
+		Data: Event{
+			TripRequestID:  "trip-1",
+			DriverID:       "driver-1",
+			Status:         StatusComplete,
+			ServiceZone:    "MNL",
+			Price:          PriceInfo{DriverEarnings: 120.5},
+			Pickup:         TripLocation{Latitude: "14.5995", Longitude: "120.9842"},
+			Dropoff:        TripLocation{Latitude: "14.6760", Longitude: "121.0437"},
+			IdempotencyKey: "idem-1",
+		},
+	}
+
+	tests := []struct {
+		name    string
+		payload []byte
+		want    Envelope
+		wantErr bool
+	}{
+		{
+			"json envelope",
+			[]byte(`{"type":"trip.status_changed","version":1,"id":"evt-1","occurred_at":"2024-05-01T08:00:00Z",
+				"data":{"trip_request_id":"trip-1","driver_id":"driver-1","status":"complete","service_zone":"MNL",
+				"price":{"driver_earnings":120.5},"idempotency_key":"idem-1",
+				"pickup":{"latitude":"14.5995","longitude":"120.9842"},"dropoff":{"latitude":"14.6760","longitude":"121.0437"}}}`),
+			want,
+			false,
+		},
+		{
+			"legacy json event",
+			[]byte(`{"trip_request_id":"trip-1","driver_id":"driver-1","status":"complete","service_zone":"MNL",
+				"price":{"driver_earnings":120.5},"idempotency_key":"idem-1","updated_at":"2024-05-01T08:00:00Z",
+				"pickup":{"latitude":"14.5995","longitude":"120.9842"},"dropoff":{"latitude":"14.6760","longitude":"121.0437"}}`),
+			func() Envelope {
+				e := want
+				e.Version = VersionLegacy
+				e.ID = "idem-1"
+				e.Data.UpdatedAt = occurred
+				return e
+			}(),
+			false,
+		},
+		{"protobuf record", frame(2, protobufRecord(want)), want, false},
+		{"avro record", frame(3, avroRecord(want)), want, false},
+		{"unregistered schema", frame(99, avroRecord(want)), Envelope{}, true},
+		{"truncated avro record", frame(3, avroRecord(want)[:10]), Envelope{}, true},
+		{"malformed json", []byte(`{"type":`), Envelope{}, true},
+	}
+
+	r, err := NewRegistry(DefaultSchemas...)
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	d := NewDecoder(r)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := d.Decode(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnvelope_Validate(t *testing.T) {
+	valid := Envelope{
+		Type:       EventType,
+		Version:    VersionV1,
+		ID:         "evt-1",
+		OccurredAt: time.Now(),
+		Data: Event{
+			DriverID: "driver-1",
+			Status:   StatusComplete,
+			Pickup:   TripLocation{Latitude: "14.5995", Longitude: "120.9842"},
+			Dropoff:  TripLocation{Latitude: "14.6760", Longitude: "121.0437"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		modify      func(e *Envelope)
+		wantReasons []string
+	}{
+		{"valid", func(e *Envelope) {}, nil},
+		{"missing driver", func(e *Envelope) { e.Data.DriverID = " " }, []string{"data.driver_id"}},
+		{"negative earnings", func(e *Envelope) { e.Data.Price.DriverEarnings = -1 }, []string{"data.price.driver_earnings"}},
+		{"reversal without trip id", func(e *Envelope) { e.Data.Status, e.Data.TripRequestID = StatusRefunded, "" }, []string{"data.trip_request_id"}},
+		{"unknown status", func(e *Envelope) { e.Data.Status = "done" }, []string{"data.status"}},
+		{"unparsable coordinates", func(e *Envelope) {
+			e.Data.Pickup.Latitude = "north"
+			e.Data.Dropoff.Longitude = "200"
+		}, []string{"data.pickup.latitude", "data.dropoff.longitude"}},
+		{"completed without coordinates", func(e *Envelope) {
+			e.Data.Pickup, e.Data.Dropoff = TripLocation{}, TripLocation{}
+		}, []string{"data.pickup.latitude", "data.pickup.longitude", "data.dropoff.latitude", "data.dropoff.longitude"}},
+		{"requested without coordinates", func(e *Envelope) {
+			e.Data.Status, e.Data.Pickup, e.Data.Dropoff = StatusRequested, TripLocation{}, TripLocation{}
+		}, nil},
+		{"cancelled with unparsable coordinates", func(e *Envelope) {
+			e.Data.Status, e.Data.TripRequestID = StatusCancelled, "trip-1"
+			e.Data.Dropoff = TripLocation{Latitude: "north"}
+		}, []string{"data.dropoff.latitude"}},
+		{"unknown type and version", func(e *Envelope) {
+			e.Type = "trip.other"
+			e.Version = 9
+		}, []string{"type", "version"}},
+		{"missing v1 metadata", func(e *Envelope) {
+			e.ID = ""
+			e.OccurredAt = time.Time{}
+		}, []string{"id", "occurred_at"}},
+		{"legacy without metadata", func(e *Envelope) {
+			e.Version = VersionLegacy
+			e.ID = ""
+			e.OccurredAt = time.Time{}
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := valid
+			tt.modify(&e)
+			err := e.Validate()
+
+			var gotFields []string
+			var ve *ValidationError
+			if errors.As(err, &ve) {
+				for _, r := range ve.Reasons {
+					gotFields = append(gotFields, r.Field)
+				}
+			} else if err != nil {
+				t.Fatalf("Validate() error = %v, want ValidationError", err)
+			}
+			if !reflect.DeepEqual(gotFields, tt.wantReasons) {
+				t.Errorf("Validate() reasons = %v, want %v", gotFields, tt.wantReasons)
+			}
+		})
+	}
+}
+
+func frame(schemaID uint32, record []byte) []byte {
+	b := []byte{magicByte, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], schemaID)
+	return append(b, record...)
+}
+
+func protobufRecord(e Envelope) []byte {
+	b := []byte{0} // message indexes
+	for num, s := range recordValues(e) {
+		b = protowire.AppendTag(b, protowire.Number(num), protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	b = protowire.AppendTag(b, 2, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(e.OccurredAt.UnixMilli()))
+	b = protowire.AppendTag(b, 7, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(e.Data.Price.DriverEarnings))
+	// unknown field should be skipped
+	b = protowire.AppendTag(b, 99, protowire.VarintType)
+	return protowire.AppendVarint(b, 1)
+}
+
+func avroRecord(e Envelope) []byte {
+	values := recordValues(e)
+	var b []byte
+	for field := 1; field <= 12; field++ {
+		switch field {
+		case 2:
+			b = binary.AppendVarint(b, e.OccurredAt.UnixMilli())
+		case 7:
+			b = binary.LittleEndian.AppendUint64(b, math.Float64bits(e.Data.Price.DriverEarnings))
+		default:
+			b = binary.AppendVarint(b, int64(len(values[field])))
+			b = append(b, values[field]...)
+		}
+	}
+	return b
+}
+
+func recordValues(e Envelope) map[int]string {
+	return map[int]string{
+		1:  e.ID,
+		3:  e.Data.TripRequestID,
+		4:  e.Data.DriverID,
+		5:  e.Data.Status,
+		6:  e.Data.ServiceZone,
+		8:  e.Data.Pickup.Latitude,
+		9:  e.Data.Pickup.Longitude,
+		10: e.Data.Dropoff.Latitude,
+		11: e.Data.Dropoff.Longitude,
+		12: e.Data.IdempotencyKey,
+	}
+}
@@ -0,0 +1,147 @@
+package trip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Format represents schema serialization format.
+type Format string
+
+const (
+	FormatJSON     Format = "JSON"
+	FormatProtobuf Format = "PROTOBUF"
+	FormatAvro     Format = "AVRO"
+)
+
+// Schema represents a registered trip event schema.
+type Schema struct {
+	ID      int
+	Subject string
+	Version int
+	Format  Format
+}
+
+// DefaultSchemas are the trip event schemas registered on the local registry.
+var DefaultSchemas = []Schema{
+	{ID: 1, Subject: "trips-value", Version: VersionV1, Format: FormatJSON},
+	{ID: 2, Subject: "trips-value", Version: VersionV1, Format: FormatProtobuf},
+	{ID: 3, Subject: "trips-value", Version: VersionV1, Format: FormatAvro},
+}
+
+// Registry is a local stand-in of a schema registry that resolves schema id
+// of framed payloads.
+type Registry struct {
+	mu      sync.RWMutex
+	schemas map[int]Schema
+}
+
+// NewRegistry returns new registry with registered schemas.
+func NewRegistry(schemas ...Schema) (*Registry, error) {
+	r := &Registry{schemas: map[int]Schema{}}
+	for _, s := range schemas {
+		if err := r.Register(s); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Register registers schema by id.
+func (r *Registry) Register(s Schema) error {
+	switch s.Format {
+	case FormatJSON, FormatProtobuf, FormatAvro:
+	default:
+		return fmt.Errorf("schema %d: unsupported format %q", s.ID, s.Format)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.schemas[s.ID]; ok {
+		return fmt.Errorf("schema %d already registered", s.ID)
+	}
+	r.schemas[s.ID] = s
+	return nil
+}
+
+// Lookup returns schema by id.
+func (r *Registry) Lookup(id int) (Schema, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.schemas[id]
+	if !ok {
+		return s, fmt.Errorf("schema %d not registered", id)
+	}
+	return s, nil
+}
+
+// Framed payloads follows schema registry wire format, a zero magic byte
+// followed by 4 bytes big-endian schema id then the encoded record.
+const (
+	magicByte  = 0x0
+	headerSize = 5
+)
+
+func isFramed(payload []byte) bool {
+	return len(payload) > headerSize && payload[0] == magicByte
+}
+
+func (d *Decoder) decodeFramed(payload []byte) (Envelope, error) {
+	if d.registry == nil {
+		return Envelope{}, errors.New("framed payload without schema registry")
+	}
+	id := int(binary.BigEndian.Uint32(payload[1:headerSize]))
+	s, err := d.registry.Lookup(id)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	record := payload[headerSize:]
+	var env Envelope
+	switch s.Format {
+	case FormatJSON:
+		return decodeJSON(record)
+	case FormatProtobuf:
+		env, err = decodeProtobuf(record)
+	case FormatAvro:
+		env, err = decodeAvro(record)
+	}
+	if err != nil {
+		return env, fmt.Errorf("schema %d %s: %s", s.ID, s.Format, err)
+	}
+	env.Type = EventType
+	env.Version = s.Version
+	return env, nil
+}
+
+// Binary formats carry flattened trip event record with the fields below,
+// protobuf uses the field number and avro follows the same field order.
+//
+//	1  id               string
+//	2  occurred_at      int64 unix milliseconds
+//	3  trip_request_id  string
+//	4  driver_id        string
+//	5  status           string
+//	6  service_zone     string
+//	7  driver_earnings  double
+//	8  pickup_latitude  string
+//	9  pickup_longitude string
+//	10 dropoff_latitude  string
+//	11 dropoff_longitude string
+//	12 idempotency_key  string
+type recordSetter func(env *Envelope, s string)
+
+var recordStrings = map[int]recordSetter{
+	1:  func(env *Envelope, s string) { env.ID = s },
+	3:  func(env *Envelope, s string) { env.Data.TripRequestID = s },
+	4:  func(env *Envelope, s string) { env.Data.DriverID = s },
+	5:  func(env *Envelope, s string) { env.Data.Status = s },
+	6:  func(env *Envelope, s string) { env.Data.ServiceZone = s },
+	8:  func(env *Envelope, s string) { env.Data.Pickup.Latitude = s },
+	9:  func(env *Envelope, s string) { env.Data.Pickup.Longitude = s },
+	10: func(env *Envelope, s string) { env.Data.Dropoff.Latitude = s },
+	11: func(env *Envelope, s string) { env.Data.Dropoff.Longitude = s },
+	12: func(env *Envelope, s string) { env.Data.IdempotencyKey = s },
+}
@@ -96,6 +96,21 @@ type Event struct {
 	IdempotencyKey string           `json:"idempotency_key"`
 }
 
+// CompletedAt returns when the trip completed, the completion time of the
+// trip metadata or the last update, trips without time completed now.
+func (e Event) CompletedAt() time.Time {
+	switch {
+	case !e.Metadata.CompletedAt.IsZero():
+		return e.Metadata.CompletedAt
+	case !e.UpdatedAt.IsZero():
+		return e.UpdatedAt
+	case !e.CreatedAt.IsZero():
+		return e.CreatedAt
+	default:
+		return time.Now()
+	}
+}
+
 func randomString(n int) string {
 	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	bytes := make([]byte, n)
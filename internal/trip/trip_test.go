@@ -1,5 +1,10 @@
 package trip
 
+import (
+	"testing"
+	"time"
+)
+
 // func TestGenerateFakeEvents(t *testing.T) {
 // 	rand.Seed(time.Now().UnixNano())
 // 	now := time.Now()
@@ -45,3 +50,22 @@ package trip
 
 // 	// fmt.Println(string(eventsJSON))
 // }
+
+func TestEvent_CompletedAt(t *testing.T) {
+	completed := time.Date(2024, 5, 12, 23, 50, 0, 0, time.UTC)
+	updated := completed.Add(2 * time.Hour)
+	tests := []struct {
+		name  string
+		event Event
+		want  time.Time
+	}{
+		{"metadata completion", Event{Metadata: MetadataInfo{CompletedAt: completed}, UpdatedAt: updated}, completed},
+		{"last update", Event{UpdatedAt: updated, CreatedAt: completed}, updated},
+		{"created", Event{CreatedAt: completed}, completed},
+	}
+	for _, tt := range tests {
+		if got := tt.event.CompletedAt(); !got.Equal(tt.want) {
+			t.Errorf("%s: CompletedAt() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
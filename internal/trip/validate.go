@@ -0,0 +1,128 @@
+package trip
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Trip statuses.
+const (
+	StatusRequested = "requested"
+	StatusAccepted  = "accepted"
+	StatusPickedUp  = "picked_up"
+	StatusComplete  = "complete"
+	StatusCancelled = "cancelled"
+	StatusRefunded  = "refunded"
+	StatusDisputed  = "disputed"
+)
+
+var knownStatuses = map[string]bool{
+	StatusRequested: true,
+	StatusAccepted:  true,
+	StatusPickedUp:  true,
+	StatusComplete:  true,
+	StatusCancelled: true,
+	StatusRefunded:  true,
+	StatusDisputed:  true,
+}
+
+// IsReversal reports whether the status takes back the score contribution
+// of a completed trip.
+func IsReversal(status string) bool {
+	switch status {
+	case StatusCancelled, StatusRefunded, StatusDisputed:
+		return true
+	}
+	return false
+}
+
+// Reason represents a field that failed validation.
+type Reason struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+// ValidationError represents all validation failures of an event.
+type ValidationError struct {
+	Reasons []Reason `json:"reasons"`
+}
+
+func (e *ValidationError) Error() string {
+	rr := make([]string, len(e.Reasons))
+	for i, r := range e.Reasons {
+		rr[i] = fmt.Sprintf("%s: %s", r.Field, r.Reason)
+	}
+	return fmt.Sprintf("invalid trip event: %s", strings.Join(rr, "; "))
+}
+
+// LogValue logs reasons as structured attributes.
+func (e *ValidationError) LogValue() slog.Value {
+	attrs := make([]slog.Attr, len(e.Reasons))
+	for i, r := range e.Reasons {
+		attrs[i] = slog.String(r.Field, r.Reason)
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func (e *ValidationError) add(field, reason string) {
+	e.Reasons = append(e.Reasons, Reason{field, reason})
+}
+
+func (e *ValidationError) err() error {
+	if len(e.Reasons) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks trip event required fields and values.
+func (e Event) Validate() error {
+	var v ValidationError
+	if strings.TrimSpace(e.DriverID) == "" {
+		v.add("driver_id", "required")
+	}
+	if e.Price.DriverEarnings < 0 {
+		v.add("price.driver_earnings", "must not be negative")
+	}
+	if !knownStatuses[e.Status] {
+		v.add("status", fmt.Sprintf("unknown status %q", e.Status))
+	}
+	// Reversals are matched to the completed trip by trip request id.
+	if IsReversal(e.Status) && strings.TrimSpace(e.TripRequestID) == "" {
+		v.add("trip_request_id", "required")
+	}
+	// Coordinates are only required for completed trips, others may be sent
+	// before pickup or drop-off is known.
+	required := e.Status == StatusComplete
+	validateLocation(&v, "pickup", e.Pickup, required)
+	validateLocation(&v, "dropoff", e.Dropoff, required)
+	return v.err()
+}
+
+// validateLocation checks the coordinates of the location, coordinates not
+// required are only checked when set.
+func validateLocation(v *ValidationError, field string, l TripLocation, required bool) {
+	if required || strings.TrimSpace(l.Latitude) != "" {
+		if err := validateCoordinate(l.Latitude, 90); err != nil {
+			v.add(field+".latitude", err.Error())
+		}
+	}
+	if required || strings.TrimSpace(l.Longitude) != "" {
+		if err := validateCoordinate(l.Longitude, 180); err != nil {
+			v.add(field+".longitude", err.Error())
+		}
+	}
+}
+
+func validateCoordinate(s string, max float64) error {
+	c, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return fmt.Errorf("unparsable coordinate %q", s)
+	}
+	if c < -max || c > max {
+		return fmt.Errorf("coordinate %s out of range", s)
+	}
+	return nil
+}
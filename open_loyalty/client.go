@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"net/url"
 	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/httpretry"
 )
 
 const (
@@ -20,19 +25,37 @@ const (
 
 	// Import Endpoints
 	importMembersEndpoint = "/api/%s/import/member"
+	importEndpoint        = "/api/%s/import/%s"
+
+	// Member Endpoints
+	membersEndpoint   = "/api/%s/member"
+	addPointsEndpoint = "/api/%s/points/add"
+	walletsEndpoint   = "/api/%s/member/%s/wallet"
+	rewardsEndpoint   = "/api/%s/member/%s/reward"
 )
 
+// Client is implemented by OpenLoyaltyClient.
 type Client interface {
 	LoginCheck(ctx context.Context) (*loginCheckResponse, error)
-	TokenRefresh(ctx context.Context) (*refreshTokenResponse, error)
-	ImportMembers(ctx context.Context) (*importMembersResponse, error)
+	RefreshToken(ctx context.Context, token string) (*refreshTokenResponse, error)
+	ImportMembers(ctx context.Context, importRequest importMembersRequest) (*importMembersResponse, error)
+	GetImport(ctx context.Context, importID string) (*importResponse, error)
+	FindMember(ctx context.Context, loyaltyCardNumber string) (*memberResponse, error)
+	AddPoints(ctx context.Context, transfer addPointsRequest) (*addPointsResponse, error)
+	GetWallets(ctx context.Context, customerID string) (*walletsResponse, error)
+	GetRewards(ctx context.Context, customerID string) (*rewardsResponse, error)
 }
 
+var _ Client = (*OpenLoyaltyClient)(nil)
+
 type CacheRepository interface {
 	GetJWTToken(ctx context.Context) (string, error)
-	GetJWTExpiry(ctx context.Context) (time.Duration, error)
-	SetAuthenticationTokens(ctx context.Context, JWTToken string, refreshToken string) error
+	SetAuthenticationTokens(ctx context.Context, JWTToken string, refreshToken string, expiresAt time.Time) error
 	GetRefreshToken(ctx context.Context) (string, error)
+	// AcquireTokenLock and ReleaseTokenLock lock refreshing tokens across
+	// replicas.
+	AcquireTokenLock(ctx context.Context, holder string, ttl time.Duration) (bool, error)
+	ReleaseTokenLock(ctx context.Context, holder string) error
 }
 
 // Creates a new instance of the Open Loyalty API client with the provided configuration.
@@ -40,7 +63,15 @@ func NewOpenLoyaltyClient(config *Config, c CacheRepository, l *slog.Logger) *Op
 	client := &OpenLoyaltyClient{
 		Client: &http.Client{
 			Timeout: 30 * time.Second, // default
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return fmt.Sprintf("open_loyalty %s %s", r.Method, r.URL.Path)
+				}),
+			),
 		},
+		MaxRetries:      defaultMaxRetries,
+		RetryBackoff:    defaultRetryBackoff,
+		RetryMaxBackoff: defaultRetryMaxBackoff,
 
 		BaseURL:  config.URL,
 		Username: config.Username,
@@ -53,6 +84,29 @@ func NewOpenLoyaltyClient(config *Config, c CacheRepository, l *slog.Logger) *Op
 	if config.ClientTimeout != nil {
 		client.Client.Timeout = *config.ClientTimeout
 	}
+	if config.MaxRetries != nil {
+		client.MaxRetries = *config.MaxRetries
+	}
+	if config.RetryBackoff > 0 {
+		client.RetryBackoff = config.RetryBackoff
+	}
+	if config.RetryMaxBackoff > 0 {
+		client.RetryMaxBackoff = config.RetryMaxBackoff
+	}
+	threshold, cooldown := defaultBreakerThreshold, defaultBreakerCooldown
+	if config.BreakerThreshold > 0 {
+		threshold = config.BreakerThreshold
+	}
+	if config.BreakerCooldown > 0 {
+		cooldown = config.BreakerCooldown
+	}
+	client.breaker = httpretry.NewBreaker(threshold, cooldown)
+
+	refreshBefore := defaultTokenRefreshBefore
+	if config.TokenRefreshBefore > 0 {
+		refreshBefore = config.TokenRefreshBefore
+	}
+	client.tokens = newTokenManager(client, c, refreshBefore)
 
 	return client
 }
@@ -64,17 +118,17 @@ func (c *OpenLoyaltyClient) preparePublicAPIRequest(req http.Request) *http.Requ
 	return &req
 }
 
-// func (c *OpenLoyaltyClient) preparePrivateAPIRequest(ctx context.Context, req http.Request) (*http.Request, error) {
-// 	token, err := c.getJWTToken(ctx)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	req.Header.Set("Authorization", fmt.Sprintf("%s %s", "Bearer", token))
-// 	req.Header.Set("Content-Type", "application/json")
-// 	req.Header.Set("Accept", "application/json")
+func (c *OpenLoyaltyClient) preparePrivateAPIRequest(ctx context.Context, req http.Request) (*http.Request, error) {
+	token, err := c.getJWTToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s %s", "Bearer", token))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
 
-// 	return &req, nil
-// }
+	return &req, nil
+}
 
 func (c *OpenLoyaltyClient) prepareImportMembersAPIRequest(ctx context.Context, req http.Request) (*http.Request, error) {
 	token, err := c.getJWTToken(ctx)
@@ -107,72 +161,89 @@ type (
 		RefreshToken string `json:"refresh_token"`
 	}
 
+	// importMembersRequest writes the import file with Write, it is called
+	// on each attempt.
 	importMembersRequest struct {
-		File string `json:"file"`
+		Write    func(w io.Writer) error
+		Filename string
 	}
 
 	importMembersResponse struct {
 		ImportID string `json:"importId"`
 	}
-)
 
-// Stores the JWT token in the cache and refreshes it if the TTL is less than 1 hour.
-// TODO: Update implementation of REDIS Service
-func (c *OpenLoyaltyClient) getJWTToken(ctx context.Context) (token string, err error) {
-	// Get the JWT token from the cache
-	key, err := c.Cache.GetJWTToken(ctx)
-	if err != nil {
-		return "", err
+	importResponse struct {
+		ImportID string               `json:"importId"`
+		Status   string               `json:"status"`
+		Message  string               `json:"message"`
+		Items    []importItemResponse `json:"items"`
 	}
 
-	if key == "" {
-		resp, err := c.LoginCheck(ctx)
-		if err != nil {
-			return "", err
-		}
+	membersResponse struct {
+		Items []memberResponse `json:"items"`
+	}
 
-		return resp.JWTToken, nil
+	memberResponse struct {
+		CustomerID        string      `json:"customerId"`
+		LoyaltyCardNumber string      `json:"loyaltyCardNumber"`
+		Level             memberLevel `json:"level"`
 	}
 
-	// Check the TTL of the token
-	ttl, err := c.Cache.GetJWTExpiry(ctx)
-	if err != nil {
-		return "", err
+	memberLevel struct {
+		Name string `json:"name"`
 	}
 
-	if ttl == 0 {
-		resp, err := c.LoginCheck(ctx)
-		if err != nil {
-			return "", err
-		}
+	walletsResponse struct {
+		Items []walletResponse `json:"items"`
+	}
 
-		return resp.JWTToken, nil
+	// walletResponse represents a points wallet of the member, units are
+	// points.
+	walletResponse struct {
+		Account struct {
+			ActiveUnits float64 `json:"activeUnits"`
+			LockedUnits float64 `json:"lockedUnits"`
+		} `json:"account"`
 	}
 
-	// If the TTL is less than 1 hour, refresh the token
-	if time.Hour > ttl {
-		// Get the Refresh token from the cache
-		refreshKey, err := c.Cache.GetRefreshToken(ctx)
-		if err != nil {
-			return "", err
-		}
+	rewardsResponse struct {
+		Items []rewardResponse `json:"items"`
+	}
 
-		newToken, err := c.RefreshToken(ctx, refreshKey)
-		if err != nil {
-			return "", err
-		}
+	rewardResponse struct {
+		RewardID     string  `json:"rewardId"`
+		Name         string  `json:"name"`
+		CostInPoints float64 `json:"costInPoints"`
+	}
 
-		err = c.Cache.SetAuthenticationTokens(ctx, newToken.JWTToken, newToken.RefreshToken)
-		if err != nil {
-			return "", err
-		}
+	addPointsRequest struct {
+		Transfer pointsTransfer `json:"transfer"`
+	}
+
+	pointsTransfer struct {
+		Customer string  `json:"customer"`
+		Points   float64 `json:"points"`
+		Comment  string  `json:"comment,omitempty"`
+	}
 
+	addPointsResponse struct {
+		TransferID string `json:"transferId"`
 	}
 
-	return key, nil
+	// importItemResponse represents the result of a member in the import,
+	// identifier is the loyalty card number.
+	importItemResponse struct {
+		Identifier string `json:"identifier"`
+		Status     string `json:"status"`
+		Message    string `json:"message"`
+	}
+)
+
+func (c *OpenLoyaltyClient) getJWTToken(ctx context.Context) (token string, err error) {
+	return c.tokens.Token(ctx)
 }
 
-// Performs a login check.
+// Performs a login check, the tokens are stored by the token manager.
 func (c *OpenLoyaltyClient) LoginCheck(ctx context.Context) (*loginCheckResponse, error) {
 	url := fmt.Sprintf("%s%s", c.BaseURL, loginCheckEndpoint)
 
@@ -186,38 +257,19 @@ func (c *OpenLoyaltyClient) LoginCheck(ctx context.Context) (*loginCheckResponse
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-	req = c.preparePublicAPIRequest(*req)
-
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	// if resp.StatusCode != http.StatusOK {
-	// 	return some error
-	// }
-	rawBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.Logger.ErrorContext(ctx, "error reading response body", "error", err)
-		return nil, err
-	}
 	var respData loginCheckResponse
-	err = json.Unmarshal(rawBody, &respData)
+	err = c.doOnce(ctx, loginCheckEndpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		return c.preparePublicAPIRequest(*req), nil
+	}, &respData)
 	if err != nil {
-		c.Logger.ErrorContext(ctx, "error unmarshalling response body", "error", err)
 		return nil, err
 	}
-
-	// Store the JWT token in the cache
-	err = c.Cache.SetAuthenticationTokens(ctx, respData.JWTToken, respData.RefreshToken)
-	if err != nil {
-		return nil, err
+	if respData.JWTToken == "" || respData.RefreshToken == "" {
+		return nil, fmt.Errorf("open loyalty %s: empty token", loginCheckEndpoint)
 	}
 
 	return &respData, nil
@@ -236,98 +288,177 @@ func (c *OpenLoyaltyClient) RefreshToken(ctx context.Context, token string) (*re
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
-	}
-	req = c.preparePublicAPIRequest(*req)
-
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	// if resp.StatusCode != http.StatusOK {
-	// 	return some error
-	// }
-	rawBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		c.Logger.ErrorContext(ctx, "error reading response body", "error", err)
-		return nil, err
-	}
 	var respData refreshTokenResponse
-	err = json.Unmarshal(rawBody, &respData)
+	err = c.doOnce(ctx, tokenRefreshEndpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		return c.preparePublicAPIRequest(*req), nil
+	}, &respData)
 	if err != nil {
-		c.Logger.ErrorContext(ctx, "error unmarshalling response body", "error", err)
 		return nil, err
 	}
+	if respData.JWTToken == "" {
+		return nil, fmt.Errorf("open loyalty %s: empty token", tokenRefreshEndpoint)
+	}
 
 	return &respData, nil
 }
 
-// Imports members.
-
+// Imports members, the file is streamed to the request body as it is
+// written. Each request creates an import so it is only retried when rate
+// limited, members of failed imports are retried by the reconcile.
 func (c *OpenLoyaltyClient) ImportMembers(ctx context.Context, importRequest importMembersRequest) (*importMembersResponse, error) {
 	url := fmt.Sprintf("%s%s", c.BaseURL, fmt.Sprintf(importMembersEndpoint, c.StoreID))
-	filePath := importRequest.File
 
-	// Open the file
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
+	var respData importMembersResponse
+	err := c.doOnce(ctx, importMembersEndpoint, func() (*http.Request, error) {
+		pr, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("import[file]", filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create form file: %w", err)
-	}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, pr)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req, err = c.prepareImportMembersAPIRequest(ctx, *req)
+		if err != nil {
+			return nil, err
+		}
 
-	// Copy file content to form part
-	_, err = io.Copy(part, file)
+		// The transport closes the request body on error which also stops
+		// the writer.
+		go func() {
+			part, err := writer.CreateFormFile("import[file]", importRequest.Filename)
+			if err != nil {
+				pw.CloseWithError(fmt.Errorf("failed to create form file: %w", err))
+				return
+			}
+			if err = importRequest.Write(part); err != nil {
+				pw.CloseWithError(fmt.Errorf("failed to write file content: %w", err))
+				return
+			}
+			pw.CloseWithError(writer.Close())
+		}()
+		return req, nil
+	}, &respData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to copy file content: %w", err)
+		return nil, err
+	}
+	if respData.ImportID == "" {
+		return nil, fmt.Errorf("open loyalty %s: missing import id", importMembersEndpoint)
 	}
+	return &respData, nil
+}
+
+// Remote import statuses, other statuses are in progress.
+const (
+	importStatusSucceed = "succeed"
+	importStatusFailed  = "failed"
+)
 
-	// Close writer
-	writer.Close()
+// Gets the import status and result of each imported member.
+func (c *OpenLoyaltyClient) GetImport(ctx context.Context, importID string) (*importResponse, error) {
+	url := fmt.Sprintf("%s%s", c.BaseURL, fmt.Sprintf(importEndpoint, c.StoreID, importID))
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
+	var respData importResponse
+	err := c.do(ctx, importEndpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return c.preparePrivateAPIRequest(ctx, *req)
+	}, &respData)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req, err = c.prepareImportMembersAPIRequest(ctx, *req)
+	return &respData, nil
+}
+
+// ErrMemberNotFound is returned when no member has the loyalty card number.
+var ErrMemberNotFound = errors.New("open loyalty: member not found")
+
+// Finds the member by loyalty card number.
+func (c *OpenLoyaltyClient) FindMember(ctx context.Context, loyaltyCardNumber string) (*memberResponse, error) {
+	q := url.Values{"loyaltyCardNumber": {loyaltyCardNumber}}
+	endpoint := fmt.Sprintf("%s%s?%s", c.BaseURL, fmt.Sprintf(membersEndpoint, c.StoreID), q.Encode())
+
+	var respData membersResponse
+	err := c.do(ctx, membersEndpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		return c.preparePrivateAPIRequest(ctx, *req)
+	}, &respData)
 	if err != nil {
 		return nil, err
 	}
+	for _, m := range respData.Items {
+		if m.LoyaltyCardNumber == loyaltyCardNumber {
+			return &m, nil
+		}
+	}
+	return nil, ErrMemberNotFound
+}
+
+// Adds points to the member, the request is not retried once sent since the
+// transfer may have been created.
+func (c *OpenLoyaltyClient) AddPoints(ctx context.Context, transfer addPointsRequest) (*addPointsResponse, error) {
+	url := fmt.Sprintf("%s%s", c.BaseURL, fmt.Sprintf(addPointsEndpoint, c.StoreID))
 
-	resp, err := c.Client.Do(req)
+	jsonData, err := json.Marshal(transfer)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	rawBody, err := io.ReadAll(resp.Body)
+	var respData addPointsResponse
+	err = c.doOnce(ctx, addPointsEndpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, err
+		}
+		return c.preparePrivateAPIRequest(ctx, *req)
+	}, &respData)
 	if err != nil {
-		c.Logger.ErrorContext(ctx, "error reading response body", "error", err)
 		return nil, err
 	}
+	return &respData, nil
+}
 
-	var respData importMembersResponse
-	err = json.Unmarshal(rawBody, &respData)
+// Gets the points wallets of the member.
+func (c *OpenLoyaltyClient) GetWallets(ctx context.Context, customerID string) (*walletsResponse, error) {
+	url := fmt.Sprintf("%s%s", c.BaseURL, fmt.Sprintf(walletsEndpoint, c.StoreID, customerID))
+
+	var respData walletsResponse
+	err := c.do(ctx, walletsEndpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return c.preparePrivateAPIRequest(ctx, *req)
+	}, &respData)
 	if err != nil {
-		c.Logger.ErrorContext(ctx, "error unmarshalling response body", "error", err)
 		return nil, err
 	}
+	return &respData, nil
+}
 
-	// Read response body
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error importing members: %s", resp.Status)
+// Gets the rewards available to the member.
+func (c *OpenLoyaltyClient) GetRewards(ctx context.Context, customerID string) (*rewardsResponse, error) {
+	url := fmt.Sprintf("%s%s", c.BaseURL, fmt.Sprintf(rewardsEndpoint, c.StoreID, customerID))
+
+	var respData rewardsResponse
+	err := c.do(ctx, rewardsEndpoint, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		return c.preparePrivateAPIRequest(ctx, *req)
+	}, &respData)
+	if err != nil {
+		return nil, err
 	}
 	return &respData, nil
 }
@@ -0,0 +1,264 @@
+package open_loyalty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/httpretry"
+)
+
+type fakeResponse struct {
+	code       int
+	body       string
+	retryAfter string
+}
+
+// fakeOpenLoyalty responds each path with its responses in order, the last
+// response repeats.
+type fakeOpenLoyalty struct {
+	mu        sync.Mutex
+	responses map[string][]fakeResponse
+	calls     map[string]int
+	bodies    map[string][]string
+}
+
+func newFakeOpenLoyalty(responses map[string][]fakeResponse) *fakeOpenLoyalty {
+	return &fakeOpenLoyalty{responses: responses, calls: map[string]int{}, bodies: map[string][]string{}}
+}
+
+func (f *fakeOpenLoyalty) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	rs, ok := f.responses[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	n := f.calls[r.URL.Path]
+	f.calls[r.URL.Path]++
+	f.bodies[r.URL.Path] = append(f.bodies[r.URL.Path], string(body))
+
+	resp := rs[min(n, len(rs)-1)]
+	if resp.retryAfter != "" {
+		w.Header().Set("Retry-After", resp.retryAfter)
+	}
+	w.WriteHeader(resp.code)
+	io.WriteString(w, resp.body)
+}
+
+func newTestClient(url string, cache CacheRepository) *OpenLoyaltyClient {
+	maxRetries := 2
+	return NewOpenLoyaltyClient(&Config{
+		URL:             url,
+		StoreID:         "store-1",
+		MaxRetries:      &maxRetries,
+		RetryBackoff:    time.Millisecond,
+		RetryMaxBackoff: 5 * time.Millisecond,
+	}, cache, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestOpenLoyaltyClient_errors(t *testing.T) {
+	const (
+		importPath = "/api/store-1/import/member"
+		statusPath = "/api/store-1/import/import-1"
+	)
+	token := fmt.Sprintf(`{"token":%q,"refresh_token":"refresh"}`, testJWT(time.Now().Add(time.Hour)))
+	login := func(c *OpenLoyaltyClient) error {
+		_, err := c.LoginCheck(context.Background())
+		return err
+	}
+	refresh := func(c *OpenLoyaltyClient) error {
+		_, err := c.RefreshToken(context.Background(), "refresh")
+		return err
+	}
+	importMembers := func(c *OpenLoyaltyClient) error {
+		_, err := c.ImportMembers(context.Background(), importMembersRequest{
+			Write:    func(w io.Writer) error { _, err := io.WriteString(w, "<customers/>"); return err },
+			Filename: "members.xml",
+		})
+		return err
+	}
+	getImport := func(c *OpenLoyaltyClient) error {
+		_, err := c.GetImport(context.Background(), "import-1")
+		return err
+	}
+
+	tests := []struct {
+		name      string
+		call      func(c *OpenLoyaltyClient) error
+		responses map[string][]fakeResponse
+		path      string
+		wantCalls int
+		// wantStatus is the APIError status code, zero for no APIError.
+		wantStatus int
+		wantErr    string
+	}{
+		{
+			"login invalid credentials",
+			login,
+			map[string][]fakeResponse{loginCheckEndpoint: {{401, `{"code":401,"message":"Invalid credentials."}`, ""}}},
+			loginCheckEndpoint, 1, 401, "Invalid credentials.",
+		},
+		{
+			"login empty token",
+			login,
+			map[string][]fakeResponse{loginCheckEndpoint: {{200, `{"token":""}`, ""}}},
+			loginCheckEndpoint, 1, 0, "empty token",
+		},
+		{
+			"login unavailable is not retried",
+			login,
+			map[string][]fakeResponse{loginCheckEndpoint: {{503, "unavailable", ""}, {200, token, ""}}},
+			loginCheckEndpoint, 1, 503, "unavailable",
+		},
+		{
+			"login retries rate limited after retry-after",
+			login,
+			map[string][]fakeResponse{loginCheckEndpoint: {{429, "", "1"}, {200, token, ""}}},
+			loginCheckEndpoint, 2, 0, "",
+		},
+		{
+			"login invalid body is not retried",
+			login,
+			map[string][]fakeResponse{loginCheckEndpoint: {{200, `<html>`, ""}}},
+			loginCheckEndpoint, 1, 0, "unmarshalling",
+		},
+		{
+			"refresh token rejected",
+			refresh,
+			map[string][]fakeResponse{tokenRefreshEndpoint: {{401, `{"code":401,"message":"Invalid JWT Refresh Token"}`, ""}}},
+			tokenRefreshEndpoint, 1, 401, "Invalid JWT Refresh Token",
+		},
+		{
+			"import validation error",
+			importMembers,
+			map[string][]fakeResponse{
+				loginCheckEndpoint: {{200, token, ""}},
+				importPath:         {{400, `{"code":400,"message":"Validation failed","errors":[{"path":"file","message":"invalid xml"}]}`, ""}},
+			},
+			importPath, 1, 400, "Validation failed",
+		},
+		{
+			"import bad gateway is not retried",
+			importMembers,
+			map[string][]fakeResponse{
+				loginCheckEndpoint: {{200, token, ""}},
+				importPath:         {{502, "bad gateway", ""}, {200, `{"importId":"import-1"}`, ""}},
+			},
+			importPath, 1, 502, "bad gateway",
+		},
+		{
+			"import retries rate limited with the whole file",
+			importMembers,
+			map[string][]fakeResponse{
+				loginCheckEndpoint: {{200, token, ""}},
+				importPath:         {{429, "", "0"}, {200, `{"importId":"import-1"}`, ""}},
+			},
+			importPath, 2, 0, "",
+		},
+		{
+			"import status not found",
+			getImport,
+			map[string][]fakeResponse{
+				loginCheckEndpoint: {{200, token, ""}},
+				statusPath:         {{404, `{"code":404,"message":"Not Found"}`, ""}},
+			},
+			statusPath, 1, 404, "Not Found",
+		},
+		{
+			"import status retries unavailable",
+			getImport,
+			map[string][]fakeResponse{
+				loginCheckEndpoint: {{200, token, ""}},
+				statusPath:         {{503, "unavailable", ""}, {200, `{"importId":"import-1"}`, ""}},
+			},
+			statusPath, 2, 0, "",
+		},
+		{
+			"import status gives up after max retries",
+			getImport,
+			map[string][]fakeResponse{
+				loginCheckEndpoint: {{200, token, ""}},
+				statusPath:         {{500, `{"code":500,"message":"Internal error"}`, ""}},
+			},
+			statusPath, 3, 500, "Internal error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOpenLoyalty(tt.responses)
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			err := tt.call(newTestClient(srv.URL, &mockCacheRepository{}))
+
+			if got := fake.calls[tt.path]; got != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", got, tt.wantCalls)
+			}
+			var apiErr *APIError
+			if errors.As(err, &apiErr) != (tt.wantStatus != 0) {
+				t.Fatalf("error = %v, want api error %d", err, tt.wantStatus)
+			}
+			if apiErr != nil && apiErr.StatusCode != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", apiErr.StatusCode, tt.wantStatus)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("error = %v, want nil", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want contains %q", err, tt.wantErr)
+			}
+			for _, b := range fake.bodies[importPath] {
+				if !strings.Contains(b, "<customers/>") {
+					t.Errorf("import body = %q, want the whole file", b)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenLoyaltyClient_breaker(t *testing.T) {
+	fake := newFakeOpenLoyalty(map[string][]fakeResponse{
+		loginCheckEndpoint: {{500, "", ""}, {500, "", ""}, {200, fmt.Sprintf(`{"token":%q,"refresh_token":"refresh"}`, testJWT(time.Now().Add(time.Hour))), ""}},
+	})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	now := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	c := newTestClient(srv.URL, &mockCacheRepository{})
+	c.MaxRetries = 0
+	c.breaker = httpretry.NewBreaker(2, time.Minute)
+	c.breaker.Now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.LoginCheck(context.Background()); err == nil {
+			t.Fatalf("LoginCheck() #%d error = nil", i)
+		}
+	}
+	if _, err := c.LoginCheck(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("LoginCheck() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if fake.calls[loginCheckEndpoint] != 2 {
+		t.Fatalf("calls = %d, want 2 while open", fake.calls[loginCheckEndpoint])
+	}
+
+	now = now.Add(time.Minute)
+	if _, err := c.LoginCheck(context.Background()); err != nil {
+		t.Fatalf("LoginCheck() after cooldown error = %v", err)
+	}
+	if _, err := c.LoginCheck(context.Background()); err != nil {
+		t.Fatalf("LoginCheck() after close error = %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package open_loyalty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/httpretry"
+)
+
+// Client defaults, zero config values uses these.
+const (
+	defaultMaxRetries       = httpretry.DefaultMaxRetries
+	defaultRetryBackoff     = httpretry.DefaultBackoff
+	defaultRetryMaxBackoff  = httpretry.DefaultMaxBackoff
+	defaultBreakerThreshold = httpretry.DefaultBreakerThreshold
+	defaultBreakerCooldown  = httpretry.DefaultBreakerCooldown
+)
+
+// ErrCircuitOpen is returned without calling Open Loyalty while the circuit
+// breaker is open after consecutive failures.
+var ErrCircuitOpen = httpretry.ErrCircuitOpen
+
+// errNotSent wraps errors returned before sending the request.
+var errNotSent = httpretry.ErrNotSent
+
+// APIError represents a non 2xx response from Open Loyalty.
+type APIError struct {
+	Endpoint   string
+	StatusCode int
+	// Code and Message are from the Open Loyalty error payload.
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+	// Body is the raw response body.
+	Body       string
+	RetryAfter time.Duration
+}
+
+// FieldError represents a validation error of a request field.
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Body
+	}
+	return fmt.Sprintf("open loyalty %s: %d %s: %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode), msg)
+}
+
+// Temporary reports whether the request can be retried.
+func (e *APIError) Temporary() bool {
+	return httpretry.Temporary(e.StatusCode)
+}
+
+// Response returns the status code and Retry-After of the response.
+func (e *APIError) Response() (int, time.Duration) {
+	return e.StatusCode, e.RetryAfter
+}
+
+func newAPIError(endpoint string, resp *http.Response, body []byte) *APIError {
+	e := &APIError{}
+	// Payload is optional, the raw body is kept for other formats.
+	_ = json.Unmarshal(body, e)
+	e.Endpoint = endpoint
+	e.StatusCode = resp.StatusCode
+	e.Body = string(body)
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		e.RetryAfter = time.Duration(s) * time.Second
+	}
+	return e
+}
+
+// do sends the request created by newReq and decodes the JSON response to
+// out. Transport errors, 429 and 5xx responses are retried with backoff,
+// newReq is called on each attempt.
+func (c *OpenLoyaltyClient) do(ctx context.Context, endpoint string, newReq func() (*http.Request, error), out any) error {
+	return c.request(ctx, endpoint, true, newReq, out)
+}
+
+// doOnce is do for requests that are not safe to repeat, only 429 responses
+// are retried.
+func (c *OpenLoyaltyClient) doOnce(ctx context.Context, endpoint string, newReq func() (*http.Request, error), out any) error {
+	return c.request(ctx, endpoint, false, newReq, out)
+}
+
+func (c *OpenLoyaltyClient) request(ctx context.Context, endpoint string, idempotent bool, newReq func() (*http.Request, error), out any) error {
+	p := httpretry.Policy{
+		MaxRetries: c.MaxRetries,
+		Backoff:    c.RetryBackoff,
+		MaxBackoff: c.RetryMaxBackoff,
+		Idempotent: idempotent,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			c.Logger.WarnContext(ctx, "retrying open loyalty request",
+				"endpoint", endpoint, "attempt", attempt, "wait", wait, "error", err)
+		},
+	}
+	return httpretry.Do(ctx, p, c.breaker, func() error {
+		req, err := newReq()
+		if err != nil {
+			return fmt.Errorf("%w: %w", errNotSent, err)
+		}
+		return c.send(req, endpoint, out)
+	})
+}
+
+func (c *OpenLoyaltyClient) send(req *http.Request, endpoint string, out any) error {
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	rawBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(endpoint, resp, rawBody)
+	}
+	if out == nil {
+		return nil
+	}
+	if err = json.Unmarshal(rawBody, out); err != nil {
+		return fmt.Errorf("error unmarshalling response body: %w", err)
+	}
+	return nil
+}
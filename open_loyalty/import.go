@@ -0,0 +1,133 @@
+package open_loyalty
+
+import (
+	"errors"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+const (
+	// maxImportAttempts limits imports of the same members including retries.
+	maxImportAttempts = 3
+	// importTimeout fails imports still pending after the duration.
+	importTimeout = 24 * time.Hour
+)
+
+var ErrImportNotFound = errors.New("import not found")
+
+// Member import statuses.
+const (
+	ImportPending   = "pending"
+	ImportSucceeded = "succeeded"
+	ImportPartial   = "partially_failed"
+	ImportFailed    = "failed"
+)
+
+// Member statuses in an import.
+const (
+	MemberPending  = "pending"
+	MemberImported = "imported"
+	MemberFailed   = "failed"
+)
+
+// Import represents a member import uploaded to Open Loyalty.
+type Import struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Attempt starts at 1, retries of failed members are new imports with
+	// RetryOf set to the previous import.
+	Attempt     int            `json:"attempt"`
+	RetryOf     string         `json:"retry_of,omitempty"`
+	RetryID     string         `json:"retry_id,omitempty"`
+	Members     []ImportMember `json:"members"`
+	Err         string         `json:"error,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	CheckedAt   *time.Time     `json:"checked_at,omitempty"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// ImportMember represents a driver in a member import.
+type ImportMember struct {
+	DriverID string  `json:"driver_id"`
+	Points   float64 `json:"points"`
+	Status   string  `json:"status"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// Completed reports whether the import status is final.
+func (i Import) Completed() bool {
+	return i.Status != ImportPending
+}
+
+// Failed returns the members that failed to import.
+func (i Import) Failed() []ImportMember {
+	var failed []ImportMember
+	for _, m := range i.Members {
+		if m.Status == MemberFailed {
+			failed = append(failed, m)
+		}
+	}
+	return failed
+}
+
+func (i Import) retryable() bool {
+	return i.Completed() && i.RetryID == "" && i.Attempt < maxImportAttempts && len(i.Failed()) > 0
+}
+
+// complete sets the member statuses from the import result, members without
+// result takes the import status.
+func (i *Import) complete(resp *importResponse, now time.Time) {
+	items := make(map[string]importItemResponse, len(resp.Items))
+	for _, it := range resp.Items {
+		items[it.Identifier] = it
+	}
+
+	failed := 0
+	for n, m := range i.Members {
+		it, ok := items[m.DriverID]
+		if !ok {
+			it = importItemResponse{Status: resp.Status, Message: resp.Message}
+		}
+		if it.Status == importStatusFailed {
+			m.Status, m.Message = MemberFailed, it.Message
+			failed++
+		} else {
+			m.Status, m.Message = MemberImported, ""
+		}
+		i.Members[n] = m
+	}
+
+	switch {
+	case failed == 0:
+		i.Status = ImportSucceeded
+	case failed == len(i.Members):
+		i.Status = ImportFailed
+	default:
+		i.Status = ImportPartial
+	}
+	if resp.Status == importStatusFailed {
+		i.Err = resp.Message
+	}
+	i.CompletedAt = &now
+}
+
+func newImportMembers(list []driver.Driver) []ImportMember {
+	members := make([]ImportMember, 0, len(list))
+	for _, d := range list {
+		members = append(members, ImportMember{
+			DriverID: d.DriverID,
+			Points:   d.Rating.Average,
+			Status:   MemberPending,
+		})
+	}
+	return members
+}
+
+func importDrivers(members []ImportMember) []driver.Driver {
+	list := make([]driver.Driver, 0, len(members))
+	for _, m := range members {
+		list = append(list, driver.Driver{DriverID: m.DriverID, Rating: driver.Rating{Average: m.Points}})
+	}
+	return list
+}
@@ -4,6 +4,8 @@ import (
 	"log/slog"
 	"net/http"
 	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/httpretry"
 )
 
 type (
@@ -17,6 +19,13 @@ type (
 		Password      string
 		ClientTimeout *time.Duration
 		StoreID       string
+		// MaxRetries of 429, 5xx and transport errors after the first attempt.
+		MaxRetries      int
+		RetryBackoff    time.Duration
+		RetryMaxBackoff time.Duration
+
+		breaker *httpretry.Breaker
+		tokens  *tokenManager
 	}
 	// Config holds the configuration values for the Talon.One API client.
 	Config struct {
@@ -25,6 +34,18 @@ type (
 		Password      string
 		ClientTimeout *time.Duration
 		StoreID       string
+		// MaxRetries defaults to 3 when nil, zero disables retries.
+		MaxRetries       *int
+		RetryBackoff     time.Duration
+		RetryMaxBackoff  time.Duration
+		BreakerThreshold int
+		BreakerCooldown  time.Duration
+		// TokenRefreshBefore refreshes the JWT token when it expires within
+		// the duration.
+		TokenRefreshBefore time.Duration
+		// ImportReconcileSchedule is the cron expression of checking member
+		// imports status.
+		ImportReconcileSchedule string
 	}
 
 	Members struct {
@@ -2,14 +2,27 @@ package open_loyalty
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"net/http"
+	"time"
 
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
 )
 
 type OpenLoyaltyService struct {
-	Client *OpenLoyaltyClient
-	logger *slog.Logger
+	Client  *OpenLoyaltyClient
+	imports ImportRepository
+	logger  *slog.Logger
+}
+
+// ImportRepository stores member imports.
+type ImportRepository interface {
+	SaveImport(ctx context.Context, imp Import) error
+	GetImport(ctx context.Context, id string) (imp Import, ok bool, err error)
+	ListImports(ctx context.Context, limit int) ([]Import, error)
 }
 
 type Label struct {
@@ -52,63 +65,208 @@ type Customers struct {
 }
 
 // Creates a new instance of the Open Loyalty Service client with the provided configuration.
-func NewProviderSerivce(client OpenLoyaltyClient, logger *slog.Logger) *OpenLoyaltyService {
+func NewProviderSerivce(client OpenLoyaltyClient, imports ImportRepository, logger *slog.Logger) *OpenLoyaltyService {
 	svc := &OpenLoyaltyService{
-		Client: &client,
-		logger: logger,
+		Client:  &client,
+		imports: imports,
+		logger:  logger,
 	}
 
 	return svc
 }
 
+// ImportDriverRating imports the drivers as members with their rating as
+// points label. The XML is streamed to the upload without temp files and
+// the returned import is saved to check its status later.
 func (s *OpenLoyaltyService) ImportDriverRating(ctx context.Context, list []driver.Driver) (err error) {
-	// importableList := Customers{}
-
-	// for _, driver := range list {
-
-	// 	customer := Customer{
-	// 		LoyaltyCardNumber: driver.ID,
-	// 		Labels: []Label{
-	// 			{
-	// 				Key:   "points",
-	// 				Value: strconv.FormatFloat(driver.Rating.Average, 'f', -1, 64),
-	// 			},
-	// 		},
-	// 	}
-
-	// 	importableList.Customers = append(importableList.Customers, customer)
-	// }
-
-	// xmlFilePath := time.Now().Format("2006-01-02") + "_temp.xml"
-
-	// // Create XML file
-	// err = CreateImportableXMLFile(xmlFilePath, &importableList)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to create importable XML file: %w", err)
-	// }
-
-	// //Call ImportMembers function with XML file path
-	// req := importMembersRequest{
-	// 	File: xmlFilePath,
-	// }
-
-	// resp, err := s.Client.ImportMembers(ctx, req)
-	// if err != nil {
-	// 	writerErr := DeleteImportableXMLFile(xmlFilePath)
-	// 	if writerErr != nil {
-	// 		return fmt.Errorf("failed to delete importable XML file: %w", err)
-	// 	}
-
-	// 	return err
-	// }
-
-	// s.logger.Info(fmt.Sprintf("Success on importing, import ReferenceID: %s", resp.ImportID))
-
-	// // Delete XML file
-	// err = DeleteImportableXMLFile(xmlFilePath)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to delete importable XML file: %w", err)
-	// }
+	if len(list) == 0 {
+		return nil
+	}
+	_, err = s.upload(ctx, newImportMembers(list), 1, "")
+	return err
+}
+
+// CheckImport updates the import status from Open Loyalty and retries failed
+// members in a new import until maxImportAttempts.
+func (s *OpenLoyaltyService) CheckImport(ctx context.Context, id string) (Import, error) {
+	imp, ok, err := s.imports.GetImport(ctx, id)
+	if err != nil {
+		return Import{}, err
+	}
+	if !ok {
+		return Import{}, ErrImportNotFound
+	}
+
+	if !imp.Completed() {
+		resp, err := s.Client.GetImport(ctx, id)
+		if err != nil {
+			return imp, fmt.Errorf("failed to get import %s: %w", id, err)
+		}
+
+		now := time.Now()
+		imp.CheckedAt = &now
+		switch {
+		case resp.Status == importStatusSucceed || resp.Status == importStatusFailed:
+			imp.complete(resp, now)
+		case now.Sub(imp.CreatedAt) > importTimeout:
+			imp.complete(&importResponse{Status: importStatusFailed, Message: "import not completed in time"}, now)
+		}
+		if err = s.imports.SaveImport(ctx, imp); err != nil {
+			return imp, fmt.Errorf("failed to save import %s: %w", id, err)
+		}
+		if imp.Completed() {
+			s.logger.InfoContext(ctx, "member import completed",
+				"import_id", id, "status", imp.Status, "failed", len(imp.Failed()))
+		}
+	}
+
+	if !imp.retryable() {
+		return imp, nil
+	}
+	failed := imp.Failed()
+	for i := range failed {
+		failed[i].Status, failed[i].Message = MemberPending, ""
+	}
+	retry, err := s.upload(ctx, failed, imp.Attempt+1, imp.ID)
+	if err != nil {
+		return imp, err
+	}
+	imp.RetryID = retry.ID
+	if err = s.imports.SaveImport(ctx, imp); err != nil {
+		return imp, fmt.Errorf("failed to save import %s: %w", id, err)
+	}
+	return imp, nil
+}
+
+// ReconcileImports checks the latest imports that are pending or have failed
+// members to retry.
+func (s *OpenLoyaltyService) ReconcileImports(ctx context.Context) error {
+	imports, err := s.imports.ListImports(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, imp := range imports {
+		if imp.Completed() && !imp.retryable() {
+			continue
+		}
+		if _, err = s.CheckImport(ctx, imp.ID); err != nil {
+			s.logger.ErrorContext(ctx, "failed to check import", "import_id", imp.ID, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListImports returns the latest imports, latest first.
+func (s *OpenLoyaltyService) ListImports(ctx context.Context, limit int) ([]Import, error) {
+	return s.imports.ListImports(ctx, limit)
+}
+
+// GetImport returns the saved import.
+func (s *OpenLoyaltyService) GetImport(ctx context.Context, id string) (Import, error) {
+	imp, ok, err := s.imports.GetImport(ctx, id)
+	if err != nil {
+		return Import{}, err
+	}
+	if !ok {
+		return Import{}, ErrImportNotFound
+	}
+	return imp, nil
+}
+
+// AwardPoints adds the award points to the driver member account, the
+// award key is kept in the transfer comment to trace repeated awards.
+func (s *OpenLoyaltyService) AwardPoints(ctx context.Context, a driver.Award) error {
+	// Points are not added yet when the member lookup fails.
+	member, err := s.Client.FindMember(ctx, a.DriverID)
+	if err != nil {
+		return fmt.Errorf("failed to award %s: %w: %w", a.Key, driver.ErrAwardRejected, err)
+	}
 
+	resp, err := s.Client.AddPoints(ctx, addPointsRequest{Transfer: pointsTransfer{
+		Customer: member.CustomerID,
+		Points:   a.Points,
+		Comment:  fmt.Sprintf("Leaderboard rank %d for %s [%s]", a.Rank, a.Period, a.Key),
+	}})
+	if err != nil {
+		return awardError(a, err)
+	}
+
+	s.logger.InfoContext(ctx, "points awarded",
+		"award_key", a.Key, "driver_id", a.DriverID, "points", a.Points, "transfer_id", resp.TransferID)
 	return nil
 }
+
+// GetLoyalty returns the driver member account with points of all wallets
+// and the rewards available.
+func (s *OpenLoyaltyService) GetLoyalty(ctx context.Context, driverID string) (driver.Loyalty, error) {
+	member, err := s.Client.FindMember(ctx, driverID)
+	if err != nil {
+		return driver.Loyalty{}, fmt.Errorf("failed to get loyalty of %s: %w", driverID, err)
+	}
+	wallets, err := s.Client.GetWallets(ctx, member.CustomerID)
+	if err != nil {
+		return driver.Loyalty{}, fmt.Errorf("failed to get wallets of %s: %w", driverID, err)
+	}
+	rewards, err := s.Client.GetRewards(ctx, member.CustomerID)
+	if err != nil {
+		return driver.Loyalty{}, fmt.Errorf("failed to get rewards of %s: %w", driverID, err)
+	}
+
+	l := driver.Loyalty{
+		MemberID: member.CustomerID,
+		Tier:     member.Level.Name,
+		Rewards:  make([]driver.Reward, 0, len(rewards.Items)),
+	}
+	for _, w := range wallets.Items {
+		l.Points += w.Account.ActiveUnits
+		l.LockedPoints += w.Account.LockedUnits
+	}
+	for _, r := range rewards.Items {
+		l.Rewards = append(l.Rewards, driver.Reward{ID: r.RewardID, Name: r.Name, CostInPoints: r.CostInPoints})
+	}
+	return l, nil
+}
+
+// awardError wraps errors of requests not sent or rejected by Open Loyalty
+// with driver.ErrAwardRejected.
+func awardError(a driver.Award, err error) error {
+	var apiErr *APIError
+	rejected := errors.Is(err, errNotSent) ||
+		(errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError)
+	if rejected {
+		return fmt.Errorf("failed to award %s: %w: %w", a.Key, driver.ErrAwardRejected, err)
+	}
+	return fmt.Errorf("failed to award %s: %w", a.Key, err)
+}
+
+func (s *OpenLoyaltyService) upload(ctx context.Context, members []ImportMember, attempt int, retryOf string) (Import, error) {
+	now := time.Now()
+	resp, err := s.Client.ImportMembers(ctx, importMembersRequest{
+		Write: func(w io.Writer) error {
+			return WriteImportableXML(w, importDrivers(members))
+		},
+		Filename: now.Format("2006-01-02") + "_members.xml",
+	})
+	if err != nil {
+		return Import{}, fmt.Errorf("failed to import members: %w", err)
+	}
+
+	imp := Import{
+		ID:        resp.ImportID,
+		Status:    ImportPending,
+		Attempt:   attempt,
+		RetryOf:   retryOf,
+		Members:   members,
+		CreatedAt: now,
+	}
+	if err = s.imports.SaveImport(ctx, imp); err != nil {
+		return Import{}, fmt.Errorf("failed to save import %s: %w", imp.ID, err)
+	}
+
+	s.logger.InfoContext(ctx, "members imported",
+		"import_id", imp.ID, "members", len(members), "attempt", attempt, "retry_of", retryOf)
+	return imp, nil
+}
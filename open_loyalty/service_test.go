@@ -0,0 +1,362 @@
+package open_loyalty
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+func TestOpenLoyaltyService_ImportDriverRating(t *testing.T) {
+	token := testJWT(time.Now().Add(time.Hour))
+	list := []driver.Driver{
+		{DriverID: "driver-1", Rating: driver.Rating{Average: 4.5}},
+		{DriverID: "driver-2", Rating: driver.Rating{Average: 3}},
+	}
+
+	tests := []struct {
+		name       string
+		importCode int
+		wantErr    bool
+		wantSaved  *Import
+	}{
+		{"imported", http.StatusOK, false, &Import{
+			ID:      "import-1",
+			Status:  ImportPending,
+			Attempt: 1,
+			Members: []ImportMember{
+				{DriverID: "driver-1", Points: 4.5, Status: MemberPending},
+				{DriverID: "driver-2", Points: 3, Status: MemberPending},
+			},
+		}},
+		{"import rejected", http.StatusBadRequest, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Customers
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST "+loginCheckEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(loginCheckResponse{JWTToken: token, RefreshToken: "refresh"})
+			})
+			mux.HandleFunc("POST /api/store-1/import/member", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer "+token {
+					t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+				}
+				f, _, err := r.FormFile("import[file]")
+				if err != nil {
+					t.Errorf("FormFile() error = %v", err)
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				defer f.Close()
+				if err = xml.NewDecoder(f).Decode(&got); err != nil {
+					t.Errorf("decode import file error = %v", err)
+				}
+
+				w.WriteHeader(tt.importCode)
+				json.NewEncoder(w).Encode(importMembersResponse{ImportID: "import-1"})
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			client := NewOpenLoyaltyClient(&Config{URL: srv.URL, StoreID: "store-1"}, &mockCacheRepository{}, logger)
+			imports := &mockImportRepository{}
+			s := NewProviderSerivce(*client, imports, logger)
+
+			err := s.ImportDriverRating(context.Background(), list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ImportDriverRating() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			want := []Customer{NewCustomer(list[0]), NewCustomer(list[1])}
+			if !reflect.DeepEqual(got.Customers, want) {
+				t.Errorf("imported customers = %+v, want %+v", got.Customers, want)
+			}
+			if tt.wantSaved == nil {
+				if imports.saved != nil {
+					t.Errorf("saved import = %+v, want none", imports.saved)
+				}
+				return
+			}
+			if imports.saved == nil || imports.saved.CreatedAt.IsZero() {
+				t.Fatalf("saved import = %+v", imports.saved)
+			}
+			imports.saved.CreatedAt = time.Time{}
+			if !reflect.DeepEqual(*imports.saved, *tt.wantSaved) {
+				t.Errorf("saved import = %+v, want %+v", *imports.saved, *tt.wantSaved)
+			}
+		})
+	}
+}
+
+func TestOpenLoyaltyService_CheckImport(t *testing.T) {
+	token := testJWT(time.Now().Add(time.Hour))
+	members := []ImportMember{
+		{DriverID: "driver-1", Points: 4.5, Status: MemberPending},
+		{DriverID: "driver-2", Points: 3, Status: MemberPending},
+	}
+	failedItems := []importItemResponse{
+		{Identifier: "driver-1", Status: importStatusSucceed},
+		{Identifier: "driver-2", Status: importStatusFailed, Message: "invalid label"},
+	}
+
+	tests := []struct {
+		name        string
+		attempt     int
+		resp        importResponse
+		wantStatus  string
+		wantMembers []string
+		wantRetry   []string
+	}{
+		{
+			"in progress",
+			1,
+			importResponse{ImportID: "import-1", Status: "processing"},
+			ImportPending,
+			[]string{MemberPending, MemberPending},
+			nil,
+		},
+		{
+			"succeeded",
+			1,
+			importResponse{ImportID: "import-1", Status: importStatusSucceed},
+			ImportSucceeded,
+			[]string{MemberImported, MemberImported},
+			nil,
+		},
+		{
+			"failed members are retried",
+			1,
+			importResponse{ImportID: "import-1", Status: importStatusSucceed, Items: failedItems},
+			ImportPartial,
+			[]string{MemberImported, MemberFailed},
+			[]string{"driver-2"},
+		},
+		{
+			"last attempt is not retried",
+			maxImportAttempts,
+			importResponse{ImportID: "import-1", Status: importStatusSucceed, Items: failedItems},
+			ImportPartial,
+			[]string{MemberImported, MemberFailed},
+			nil,
+		},
+		{
+			"import failed",
+			1,
+			importResponse{ImportID: "import-1", Status: importStatusFailed, Message: "invalid file"},
+			ImportFailed,
+			[]string{MemberFailed, MemberFailed},
+			[]string{"driver-1", "driver-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var retried Customers
+			mux := http.NewServeMux()
+			mux.HandleFunc("POST "+loginCheckEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(loginCheckResponse{JWTToken: token, RefreshToken: "refresh"})
+			})
+			mux.HandleFunc("GET /api/store-1/import/import-1", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(tt.resp)
+			})
+			mux.HandleFunc("POST /api/store-1/import/member", func(w http.ResponseWriter, r *http.Request) {
+				f, _, err := r.FormFile("import[file]")
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				defer f.Close()
+				xml.NewDecoder(f).Decode(&retried)
+				json.NewEncoder(w).Encode(importMembersResponse{ImportID: "import-2"})
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			client := NewOpenLoyaltyClient(&Config{URL: srv.URL, StoreID: "store-1"}, &mockCacheRepository{}, logger)
+			imports := &mockImportRepository{}
+			imports.SaveImport(context.Background(), Import{
+				ID:        "import-1",
+				Status:    ImportPending,
+				Attempt:   tt.attempt,
+				Members:   append([]ImportMember(nil), members...),
+				CreatedAt: time.Now(),
+			})
+			s := NewProviderSerivce(*client, imports, logger)
+
+			if err := s.ReconcileImports(context.Background()); err != nil {
+				t.Fatalf("ReconcileImports() error = %v", err)
+			}
+
+			imp := imports.imports["import-1"]
+			if imp.Status != tt.wantStatus || imp.CheckedAt == nil {
+				t.Errorf("status = %s, checked at %v, want %s", imp.Status, imp.CheckedAt, tt.wantStatus)
+			}
+			for i, m := range imp.Members {
+				if m.Status != tt.wantMembers[i] {
+					t.Errorf("member %s status = %s, want %s", m.DriverID, m.Status, tt.wantMembers[i])
+				}
+			}
+
+			var got []string
+			for _, c := range retried.Customers {
+				got = append(got, c.LoyaltyCardNumber)
+			}
+			if !reflect.DeepEqual(got, tt.wantRetry) {
+				t.Fatalf("retried members = %v, want %v", got, tt.wantRetry)
+			}
+			if tt.wantRetry == nil {
+				return
+			}
+			retry := imports.imports["import-2"]
+			if imp.RetryID != "import-2" || retry.RetryOf != "import-1" || retry.Attempt != 2 || len(retry.Members) != len(tt.wantRetry) {
+				t.Errorf("import = %+v, retry = %+v", imp, retry)
+			}
+		})
+	}
+}
+
+type mockCacheRepository struct {
+	mu        sync.Mutex
+	jwt       string
+	refresh   string
+	expiresAt time.Time
+	// lockedBy is the token lock holder, onLocked is called when the lock
+	// is held by another holder.
+	lockedBy string
+	onLocked func(m *mockCacheRepository)
+}
+
+func (m *mockCacheRepository) GetJWTToken(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.jwt, nil
+}
+
+func (m *mockCacheRepository) SetAuthenticationTokens(ctx context.Context, JWTToken string, refreshToken string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.jwt, m.refresh, m.expiresAt = JWTToken, refreshToken, expiresAt
+	return nil
+}
+
+func (m *mockCacheRepository) GetRefreshToken(ctx context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.refresh, nil
+}
+
+func (m *mockCacheRepository) AcquireTokenLock(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lockedBy != "" && m.lockedBy != holder {
+		if m.onLocked != nil {
+			m.onLocked(m)
+		}
+		return false, nil
+	}
+	m.lockedBy = holder
+	return true, nil
+}
+
+func (m *mockCacheRepository) ReleaseTokenLock(ctx context.Context, holder string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lockedBy == holder {
+		m.lockedBy = ""
+	}
+	return nil
+}
+
+type mockImportRepository struct {
+	saved   *Import
+	imports map[string]Import
+}
+
+func (m *mockImportRepository) SaveImport(ctx context.Context, imp Import) error {
+	m.saved = &imp
+	if m.imports == nil {
+		m.imports = make(map[string]Import)
+	}
+	m.imports[imp.ID] = imp
+	return nil
+}
+
+func (m *mockImportRepository) GetImport(ctx context.Context, id string) (Import, bool, error) {
+	imp, ok := m.imports[id]
+	return imp, ok, nil
+}
+
+func (m *mockImportRepository) ListImports(ctx context.Context, limit int) ([]Import, error) {
+	imports := make([]Import, 0, len(m.imports))
+	for _, imp := range m.imports {
+		imports = append(imports, imp)
+	}
+	return imports, nil
+}
+
+func TestOpenLoyaltyService_AwardPoints(t *testing.T) {
+	token := fmt.Sprintf(`{"token":%q,"refresh_token":"refresh"}`, testJWT(time.Now().Add(time.Hour)))
+	const (
+		membersPath = "/api/store-1/member"
+		pointsPath  = "/api/store-1/points/add"
+	)
+	member := fakeResponse{200, `{"items":[{"customerId":"customer-1","loyaltyCardNumber":"driver-1"}]}`, ""}
+
+	tests := []struct {
+		name         string
+		members      fakeResponse
+		points       fakeResponse
+		wantPoints   int
+		wantErr      bool
+		wantRejected bool
+	}{
+		{"awarded", member, fakeResponse{200, `{"transferId":"transfer-1"}`, ""}, 1, false, false},
+		{"member not found", fakeResponse{200, `{"items":[]}`, ""}, fakeResponse{}, 0, true, true},
+		{"transfer rejected", member, fakeResponse{400, `{"code":400,"message":"Validation failed"}`, ""}, 1, true, true},
+		{"transfer failed is not retried", member, fakeResponse{500, `{"code":500,"message":"Internal error"}`, ""}, 1, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOpenLoyalty(map[string][]fakeResponse{
+				loginCheckEndpoint: {{200, token, ""}},
+				membersPath:        {tt.members},
+				pointsPath:         {tt.points},
+			})
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			s := NewProviderSerivce(*newTestClient(srv.URL, &mockCacheRepository{}), &mockImportRepository{}, logger)
+
+			err := s.AwardPoints(context.Background(), driver.Award{
+				Key: "2024-W19:driver-1", DriverID: "driver-1", Period: "2024-W19", Rank: 1, Points: 100,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AwardPoints() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if errors.Is(err, driver.ErrAwardRejected) != tt.wantRejected {
+				t.Errorf("AwardPoints() error = %v, want rejected %v", err, tt.wantRejected)
+			}
+			if fake.calls[pointsPath] != tt.wantPoints {
+				t.Errorf("add points calls = %d, want %d", fake.calls[pointsPath], tt.wantPoints)
+			}
+			if tt.wantPoints > 0 && !strings.Contains(fake.bodies[pointsPath][0], `"customer":"customer-1","points":100`) {
+				t.Errorf("add points body = %s", fake.bodies[pointsPath][0])
+			}
+		})
+	}
+}
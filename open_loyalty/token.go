@@ -0,0 +1,221 @@
+package open_loyalty
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenRefreshBefore = 5 * time.Minute
+	// tokenLockTTL bounds a token refresh across replicas.
+	tokenLockTTL = 10 * time.Second
+	// tokenRenewTimeout bounds the refresh and login requests of a renewal,
+	// so the lock is not released by its ttl while the renewal is running.
+	tokenRenewTimeout = tokenLockTTL - 2*time.Second
+	// tokenLockPoll is the wait between checks of the token refreshed by
+	// another replica.
+	tokenLockPoll = 100 * time.Millisecond
+	// tokenSkew is the margin before expiry a token is no longer used.
+	tokenSkew = 10 * time.Second
+)
+
+var errTokenLockTimeout = errors.New("open loyalty: timed out waiting for token refresh")
+
+// tokenManager returns the cached JWT token and refreshes it before expiry.
+// A refresh is done once per process by the mutex and once across replicas
+// by the cache lock, others use the current token while valid or wait for
+// the refreshed token.
+type tokenManager struct {
+	client        *OpenLoyaltyClient
+	cache         CacheRepository
+	holder        string
+	refreshBefore time.Duration
+	now           func() time.Time
+	sleep         func(ctx context.Context, d time.Duration) error
+
+	mu sync.Mutex
+}
+
+func newTokenManager(client *OpenLoyaltyClient, cache CacheRepository, refreshBefore time.Duration) *tokenManager {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &tokenManager{
+		client:        client,
+		cache:         cache,
+		holder:        fmt.Sprintf("%s-%d", host, os.Getpid()),
+		refreshBefore: refreshBefore,
+		now:           time.Now,
+		sleep:         sleep,
+	}
+}
+
+// Token returns a valid JWT token.
+func (m *tokenManager) Token(ctx context.Context) (string, error) {
+	token, exp, err := m.cached(ctx)
+	if err != nil {
+		return "", err
+	}
+	if m.fresh(exp) {
+		return token, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := 0; ; i++ {
+		// Token may be refreshed while waiting for the lock.
+		token, exp, err = m.cached(ctx)
+		if err != nil {
+			return "", err
+		}
+		if m.fresh(exp) {
+			return token, nil
+		}
+
+		locked, err := m.cache.AcquireTokenLock(ctx, m.holder, tokenLockTTL)
+		if err != nil {
+			return "", err
+		}
+		if locked {
+			token, err = m.renew(ctx)
+			if rerr := m.cache.ReleaseTokenLock(context.WithoutCancel(ctx), m.holder); rerr != nil {
+				m.client.Logger.WarnContext(ctx, "failed to release token lock", "error", rerr)
+			}
+			return token, err
+		}
+
+		// Another replica is refreshing.
+		if m.valid(exp) {
+			return token, nil
+		}
+		if i >= int(tokenLockTTL/tokenLockPoll) {
+			return "", errTokenLockTimeout
+		}
+		if err = m.sleep(ctx, tokenLockPoll); err != nil {
+			return "", err
+		}
+	}
+}
+
+// renew refreshes the token, falls back to login when there is no refresh
+// token or it is rejected. Requests are not repeated past tokenRenewTimeout.
+func (m *tokenManager) renew(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, tokenRenewTimeout)
+	defer cancel()
+
+	refresh, err := m.cache.GetRefreshToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if refresh != "" {
+		resp, err := m.client.RefreshToken(ctx, refresh)
+		if err == nil {
+			if resp.RefreshToken != "" {
+				refresh = resp.RefreshToken
+			}
+			return m.store(ctx, resp.JWTToken, refresh)
+		}
+		if !rejected(err) {
+			return "", err
+		}
+		m.client.Logger.WarnContext(ctx, "refresh token rejected, logging in", "error", err)
+	}
+
+	resp, err := m.client.LoginCheck(ctx)
+	if err != nil {
+		return "", err
+	}
+	return m.store(ctx, resp.JWTToken, resp.RefreshToken)
+}
+
+func (m *tokenManager) store(ctx context.Context, token, refresh string) (string, error) {
+	exp, err := jwtExpiry(token)
+	if err != nil {
+		return "", err
+	}
+	if err = m.cache.SetAuthenticationTokens(ctx, token, refresh, exp); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// cached returns the cached token and its expiry, zero expiry when there is
+// no valid token.
+func (m *tokenManager) cached(ctx context.Context) (string, time.Time, error) {
+	token, err := m.cache.GetJWTToken(ctx)
+	if err != nil || token == "" {
+		return "", time.Time{}, err
+	}
+	exp, err := jwtExpiry(token)
+	if err != nil {
+		m.client.Logger.WarnContext(ctx, "invalid cached jwt token", "error", err)
+		return "", time.Time{}, nil
+	}
+	return token, exp, nil
+}
+
+func (m *tokenManager) fresh(exp time.Time) bool {
+	return exp.Sub(m.now()) > m.refreshBefore
+}
+
+func (m *tokenManager) valid(exp time.Time) bool {
+	return exp.Sub(m.now()) > tokenSkew
+}
+
+// rejected reports whether Open Loyalty rejected the refresh token.
+func rejected(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+// jwtExpiry returns the exp claim of the token, the signature is not
+// verified.
+func jwtExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid jwt token: malformed")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid jwt token: %s", err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("invalid jwt token: %s", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, fmt.Errorf("invalid jwt token: missing exp")
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
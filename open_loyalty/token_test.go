@@ -0,0 +1,125 @@
+package open_loyalty
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testJWT(exp time.Time) string {
+	enc := base64.RawURLEncoding
+	return fmt.Sprintf("%s.%s.%s",
+		enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)),
+		enc.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d,"username":"admin"}`, exp.Unix()))),
+		enc.EncodeToString([]byte("signature")),
+	)
+}
+
+func TestTokenManager_Token(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	fresh := testJWT(now.Add(time.Hour))
+	expiring := testJWT(now.Add(time.Minute))
+	expired := testJWT(now.Add(-time.Minute))
+	loggedIn := testJWT(now.Add(2 * time.Hour))
+	refreshed := testJWT(now.Add(3 * time.Hour))
+
+	tokenResp := func(token, refresh string) fakeResponse {
+		return fakeResponse{200, fmt.Sprintf(`{"token":%q,"refresh_token":%q}`, token, refresh), ""}
+	}
+
+	tests := []struct {
+		name          string
+		jwt           string
+		refresh       string
+		refreshResp   fakeResponse
+		otherReplica  bool
+		want          string
+		wantRefresh   string
+		wantLogins    int
+		wantRefreshes int
+	}{
+		{"no token logs in", "", "", fakeResponse{}, false, loggedIn, "refresh-1", 1, 0},
+		{"fresh token is cached", fresh, "refresh-0", fakeResponse{}, false, fresh, "refresh-0", 0, 0},
+		{"expiring token is refreshed", expiring, "refresh-0", tokenResp(refreshed, "refresh-2"), false, refreshed, "refresh-2", 0, 1},
+		{"refresh keeps refresh token", expiring, "refresh-0", tokenResp(refreshed, ""), false, refreshed, "refresh-0", 0, 1},
+		{"rejected refresh token logs in", expired, "refresh-0", fakeResponse{401, `{"code":401,"message":"Invalid JWT Refresh Token"}`, ""}, false, loggedIn, "refresh-1", 1, 1},
+		{"expiring token is used while another replica refreshes", expiring, "refresh-0", fakeResponse{}, true, expiring, "refresh-0", 0, 0},
+		{"waits for token refreshed by another replica", expired, "refresh-0", fakeResponse{}, true, refreshed, "refresh-2", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeOpenLoyalty(map[string][]fakeResponse{
+				loginCheckEndpoint:   {tokenResp(loggedIn, "refresh-1")},
+				tokenRefreshEndpoint: {tt.refreshResp},
+			})
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			cache := &mockCacheRepository{jwt: tt.jwt, refresh: tt.refresh}
+			if tt.otherReplica {
+				polls := 0
+				cache.lockedBy = "other-replica"
+				cache.onLocked = func(m *mockCacheRepository) {
+					// Other replica stores the token after a few polls.
+					if polls++; polls == 3 {
+						m.jwt, m.refresh, m.lockedBy = refreshed, "refresh-2", ""
+					}
+				}
+			}
+			c := newTestClient(srv.URL, cache)
+			c.tokens.now = func() time.Time { return now }
+			c.tokens.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+			got, err := c.tokens.Token(context.Background())
+			if err != nil {
+				t.Fatalf("Token() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Token() = %s, want %s", got, tt.want)
+			}
+			if cache.jwt != tt.want || cache.refresh != tt.wantRefresh {
+				t.Errorf("cached = %s, %s, want %s, %s", cache.jwt, cache.refresh, tt.want, tt.wantRefresh)
+			}
+			if fake.calls[loginCheckEndpoint] != tt.wantLogins || fake.calls[tokenRefreshEndpoint] != tt.wantRefreshes {
+				t.Errorf("logins = %d, refreshes = %d, want %d, %d",
+					fake.calls[loginCheckEndpoint], fake.calls[tokenRefreshEndpoint], tt.wantLogins, tt.wantRefreshes)
+			}
+			if cache.lockedBy == c.tokens.holder {
+				t.Error("token lock not released")
+			}
+		})
+	}
+}
+
+func TestTokenManager_Token_concurrent(t *testing.T) {
+	now := time.Now()
+	token := testJWT(now.Add(time.Hour))
+	fake := newFakeOpenLoyalty(map[string][]fakeResponse{
+		loginCheckEndpoint: {{200, fmt.Sprintf(`{"token":%q,"refresh_token":"refresh"}`, token), ""}},
+	})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	c := newTestClient(srv.URL, &mockCacheRepository{})
+	c.tokens.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := c.tokens.Token(context.Background()); err != nil || got != token {
+				t.Errorf("Token() = %s, %v, want %s", got, err, token)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if fake.calls[loginCheckEndpoint] != 1 {
+		t.Errorf("logins = %d, want 1", fake.calls[loginCheckEndpoint])
+	}
+}
@@ -2,42 +2,46 @@ package open_loyalty
 
 import (
 	"encoding/xml"
-	"fmt"
-	"os"
+	"io"
+	"strconv"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
 )
 
-func CreateImportableXMLFile(filename string, list *Customers) error {
-	xmlData, err := xml.MarshalIndent(list, "", "  ")
-	if err != nil {
+// WriteImportableXML streams the member import XML of the drivers to w, one
+// customer at a time so large lists are not held in memory.
+func WriteImportableXML(w io.Writer, list []driver.Driver) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
 		return err
 	}
 
-	file, err := os.Create(filename)
-	if err != nil {
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	root := xml.StartElement{Name: xml.Name{Local: "customers"}}
+	if err := enc.EncodeToken(root); err != nil {
 		return err
 	}
-
-	defer file.Close()
-
-	// Write the XML declaration
-	fmt.Fprintf(file, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
-
-	// Write the XML data
-	_, err = file.Write(xmlData)
-	if err != nil {
+	for _, d := range list {
+		if err := enc.EncodeElement(NewCustomer(d), xml.StartElement{Name: xml.Name{Local: "customer"}}); err != nil {
+			return err
+		}
+	}
+	if err := enc.EncodeToken(root.End()); err != nil {
 		return err
 	}
-
-	fmt.Printf("XML file '%s' created successfully.\n", filename)
-	return nil
+	return enc.Flush()
 }
 
-func DeleteImportableXMLFile(filename string) error {
-	err := os.Remove(filename)
-	if err != nil {
-		return err
+// NewCustomer returns the importable member of the driver, the rating
+// average is set as the points label.
+func NewCustomer(d driver.Driver) Customer {
+	return Customer{
+		LoyaltyCardNumber: d.DriverID,
+		Labels: []Label{
+			{
+				Key:   "points",
+				Value: strconv.FormatFloat(d.Rating.Average, 'f', -1, 64),
+			},
+		},
 	}
-
-	fmt.Printf("XML file '%s' deleted successfully.\n", filename)
-	return nil
 }
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const defaultAdminAddr = ":8001"
+
+// NewAdmin creates new instance of worker, loyalty import and reward admin Server. Admin endpoints uses
+// the same authentication as the server private endpoints.
+func NewAdmin(
+	config Config,
+	wa workerAdmin,
+	ia importAdmin,
+	ra rewardAdmin,
+	authenticator authenticator,
+	tracing tracing,
+	version Version,
+	logger *slog.Logger,
+) *Server {
+	if strings.TrimSpace(config.Addr) == "" {
+		config.Addr = defaultAdminAddr
+	}
+	c := config.setDefaults()
+
+	l := logger.With("pkg", "server", "server", "admin")
+	l.Info("config",
+		"addr", c.Addr,
+		"read-timeout", c.ReadTimeout.String(),
+		"write-timeout", c.WriteTimeout.String(),
+		"shutdown-timeout", c.ShutdownTimeout.String(),
+	)
+
+	s := &Server{
+		workerAdmin:   wa,
+		importAdmin:   ia,
+		rewardAdmin:   ra,
+		authenticator: authenticator,
+		tracing:       tracing,
+		Version:       version,
+		logger:        l,
+		config:        c,
+	}
+	s.Server = &http.Server{
+		Addr:         c.Addr,
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		Handler:      s.AdminRoutes(),
+	}
+	return s
+}
+
+// AdminRoutes setups middlewares and worker admin endpoints.
+func (s *Server) AdminRoutes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(
+		s.tracing.Middleware(),
+		requestIDMiddleware,
+		s.loggingMiddleware,
+		s.recoveryMiddleware,
+	)
+
+	// Public endpoints
+	r.Get("/version", GetVersion(s.Version))
+
+	// Private endpoints
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(authMiddleware(s.authenticator))
+		r.Get("/worker", GetWorkerStats(s.workerAdmin))
+		r.Post("/worker/topics/{topic}/pause", PauseWorkerTopic(s.workerAdmin, s.logger))
+		r.Post("/worker/topics/{topic}/resume", ResumeWorkerTopic(s.workerAdmin, s.logger))
+		r.Get("/schedules", ListSchedules(s.workerAdmin))
+		r.Post("/schedules/{name}/run", RunSchedule(s.workerAdmin, s.logger))
+		r.Get("/imports", ListImports(s.importAdmin))
+		r.Get("/imports/{id}", GetImport(s.importAdmin))
+		r.Post("/imports/{id}/check", CheckImport(s.importAdmin, s.logger))
+		r.Get("/campaigns", ListCampaigns(s.rewardAdmin))
+		r.Post("/campaigns", CreateCampaign(s.rewardAdmin, s.logger))
+		r.Get("/campaigns/{id}", GetCampaign(s.rewardAdmin))
+		r.Get("/campaigns/{id}/budget", GetCampaignBudget(s.rewardAdmin))
+		r.Get("/budgets", ListBudgets(s.rewardAdmin))
+		r.Get("/entitlements", ListEntitlements(s.rewardAdmin))
+		r.Post("/entitlements/{action}", UpdateEntitlements(s.rewardAdmin, s.logger))
+		r.Get("/entitlements/{id}/audit", ListEntitlementAudit(s.rewardAdmin))
+	})
+
+	r.NotFound(noMatchHandler(http.StatusNotFound))
+	r.MethodNotAllowed(noMatchHandler(http.StatusMethodNotAllowed))
+	return r
+}
+
+var errMissingTopic = errors.New("topic required")
@@ -19,6 +19,13 @@ type Server struct {
 
 	driverService      driverService
 	leaderboardService leaderboardService
+	loyaltyWebhook     loyaltyWebhook
+	rewardService      rewardService
+	tierService        tierService
+	badgeService       badgeService
+	rewardAdmin        rewardAdmin
+	workerAdmin        workerAdmin
+	importAdmin        importAdmin
 	authenticator      authenticator
 	databaseChecker    databaseChecker
 	tracing            tracing
@@ -33,6 +40,10 @@ func New(
 	config Config,
 	ds driverService,
 	ls leaderboardService,
+	lw loyaltyWebhook,
+	rs rewardService,
+	ts tierService,
+	bs badgeService,
 	authenticator authenticator,
 	tracing tracing,
 	version Version,
@@ -51,10 +62,15 @@ func New(
 	s := &Server{
 		driverService:      ds,
 		leaderboardService: ls,
+		loyaltyWebhook:     lw,
+		rewardService:      rs,
+		tierService:        ts,
+		badgeService:       bs,
 		authenticator:      authenticator,
 		tracing:            tracing,
 		Version:            version,
 		logger:             l,
+		config:             c,
 	}
 	s.Server = &http.Server{
 		Addr:         c.Addr,
@@ -91,9 +107,14 @@ func (s *Server) Routes() http.Handler {
 	//r.Get("/fighters/{id}", GetFighterByID(s.service))
 
 	// Leaderboard Endpoints
-	r.Get("/leaderboard/ranking/{id}", GetDriverRating(s.driverService))
+	r.Get("/leaderboard/ranking/{id}", GetDriverRating(s.driverService, s.tierService, s.badgeService, s.logger))
+	r.Get("/leaderboard/ranking/{id}/rewards", GetDriverEntitlements(s.rewardService))
+	r.Get("/leaderboard/ranking/{id}/tiers", GetDriverTierHistory(s.tierService))
 	r.Get("/leaderboard/{scope}", GetLeaderboard(s.leaderboardService))
 
+	// Webhook Endpoints
+	r.Post("/webhooks/openloyalty", LoyaltyWebhook(s.loyaltyWebhook, s.config.LoyaltyWebhookSecret, s.logger))
+
 	// Private endpoints
 	r.Route("/", func(r chi.Router) {
 		r.Use(authMiddleware(s.authenticator))
@@ -156,6 +177,9 @@ type Config struct {
 	ShutdownTimeout time.Duration
 	ReadTimeout     time.Duration
 	WriteTimeout    time.Duration
+	// LoyaltyWebhookSecret signs Open Loyalty webhooks, empty disables the
+	// webhook endpoint.
+	LoyaltyWebhookSecret string
 }
 
 func (c Config) setDefaults() Config {
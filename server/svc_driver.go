@@ -2,17 +2,47 @@ package server
 
 import (
 	"context"
+	"log/slog"
 	"net/http"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/achievement"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/tier"
 )
 
 type driverService interface {
 	GetDriver(ctx context.Context, id string) (driver driver.Driver, err error)
+	GetLoyalty(ctx context.Context, id string) (loyalty driver.Loyalty, err error)
 }
 
-func GetDriverRating(ds driverService) http.HandlerFunc {
+type tierService interface {
+	GetTier(ctx context.Context, driverID string) (tier.Assignment, error)
+	ListTierHistory(ctx context.Context, driverID string) ([]tier.Change, error)
+}
+
+type badgeService interface {
+	ListBadges(ctx context.Context, driverID string) ([]achievement.Badge, error)
+}
+
+// rankingResponse is the driver ranking with the current tier, badges and
+// the optional loyalty account.
+type rankingResponse struct {
+	driver.Driver
+	// Tier is omitted when the tier is unavailable.
+	Tier string `json:"tier,omitempty"`
+	// Badges are omitted when unavailable.
+	Badges  []achievement.Badge `json:"badges,omitempty"`
+	Loyalty *driver.Loyalty     `json:"loyalty,omitempty"`
+	// LoyaltyUnavailable is set when loyalty is included but the provider
+	// failed.
+	LoyaltyUnavailable bool `json:"loyalty_unavailable,omitempty"`
+}
+
+// GetDriverRating returns the driver ranking, `?include=loyalty` embeds the
+// loyalty points and rewards of the driver.
+func GetDriverRating(ds driverService, ts tierService, bs badgeService, logger *slog.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		id := chi.URLParam(r, "id")
@@ -22,8 +52,54 @@ func GetDriverRating(ds driverService) http.HandlerFunc {
 			return
 		}
 
-		encodeJSONResp(w, c, http.StatusOK)
+		resp := rankingResponse{Driver: c}
+		if t, err := ts.GetTier(r.Context(), id); err != nil {
+			logger.WarnContext(r.Context(), "tier unavailable", "driver_id", id, "error", err)
+		} else {
+			resp.Tier = t.Tier
+		}
+		if b, err := bs.ListBadges(r.Context(), id); err != nil {
+			logger.WarnContext(r.Context(), "badges unavailable", "driver_id", id, "error", err)
+		} else {
+			resp.Badges = b
+		}
+		if includes(r, "loyalty") {
+			l, err := ds.GetLoyalty(r.Context(), id)
+			if err != nil {
+				logger.WarnContext(r.Context(), "loyalty unavailable", "driver_id", id, "error", err)
+				resp.LoyaltyUnavailable = true
+			} else {
+				resp.Loyalty = &l
+			}
+		}
+		encodeJSONResp(w, resp, http.StatusOK)
+	}
+}
+
+// GetDriverTierHistory returns the tier changes of the driver, latest first.
+func GetDriverTierHistory(ts tierService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		list, err := ts.ListTierHistory(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []tier.Change{}
+		}
+		encodeJSONResp(w, list, http.StatusOK)
+	}
+}
+
+// includes reports whether the comma separated include query has name.
+func includes(r *http.Request, name string) bool {
+	for _, v := range strings.Split(r.URL.Query().Get("include"), ",") {
+		if strings.TrimSpace(v) == name {
+			return true
+		}
 	}
+	return false
 }
 
 // func ListTier(s tierService) http.HandlerFunc {
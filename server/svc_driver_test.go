@@ -0,0 +1,124 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/achievement"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/tier"
+)
+
+func TestGetDriverRating(t *testing.T) {
+	badges := []achievement.Badge{{ID: achievement.BadgeFirst100Trips, DriverID: "driver-1"}}
+	loyalty := driver.Loyalty{MemberID: "customer-1", Points: 120, Rewards: []driver.Reward{{ID: "reward-1", Name: "Free ride", CostInPoints: 100}}}
+
+	tests := []struct {
+		name        string
+		url         string
+		loyaltyErr  error
+		tierErr     error
+		want        rankingResponse
+		wantLoyalty int
+	}{
+		{"without loyalty", "/leaderboard/ranking/driver-1", nil, nil, rankingResponse{Driver: driver.Driver{DriverID: "driver-1"}, Tier: tier.Gold, Badges: badges}, 0},
+		{"tier unavailable", "/leaderboard/ranking/driver-1", nil, context.DeadlineExceeded, rankingResponse{Driver: driver.Driver{DriverID: "driver-1"}, Badges: badges}, 0},
+		{
+			"with loyalty",
+			"/leaderboard/ranking/driver-1?include=loyalty",
+			nil, nil,
+			rankingResponse{Driver: driver.Driver{DriverID: "driver-1"}, Tier: tier.Gold, Badges: badges, Loyalty: &loyalty},
+			1,
+		},
+		{
+			"loyalty unavailable",
+			"/leaderboard/ranking/driver-1?include=badges,loyalty",
+			context.DeadlineExceeded, nil,
+			rankingResponse{Driver: driver.Driver{DriverID: "driver-1"}, Tier: tier.Gold, Badges: badges, LoyaltyUnavailable: true},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			ds := &mockDriverService{
+				GetDriverFn: func(ctx context.Context, id string) (driver.Driver, error) {
+					return driver.Driver{DriverID: id}, nil
+				},
+				GetLoyaltyFn: func(ctx context.Context, id string) (driver.Loyalty, error) {
+					calls++
+					if tt.loyaltyErr != nil {
+						return driver.Loyalty{}, tt.loyaltyErr
+					}
+					return loyalty, nil
+				},
+			}
+			ts := &mockTierService{GetTierFn: func(ctx context.Context, id string) (tier.Assignment, error) {
+				return tier.Assignment{DriverID: id, Tier: tier.Gold}, tt.tierErr
+			}}
+			bs := &mockBadgeService{ListBadgesFn: func(ctx context.Context, id string) ([]achievement.Badge, error) {
+				return badges, nil
+			}}
+			r := chi.NewRouter()
+			r.Get("/leaderboard/ranking/{id}", GetDriverRating(ds, ts, bs, slog.New(slog.NewTextHandler(io.Discard, nil))))
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("GetDriverRating() status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			var got rankingResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+				t.Fatalf("decoding payload failed: %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetDriverRating() body = %s, want %+v", w.Body, tt.want)
+			}
+			if calls != tt.wantLoyalty {
+				t.Errorf("GetLoyalty() calls = %d, want %d", calls, tt.wantLoyalty)
+			}
+		})
+	}
+}
+
+type mockDriverService struct {
+	GetDriverFn  func(ctx context.Context, id string) (driver.Driver, error)
+	GetLoyaltyFn func(ctx context.Context, id string) (driver.Loyalty, error)
+}
+
+func (m *mockDriverService) GetDriver(ctx context.Context, id string) (driver.Driver, error) {
+	return m.GetDriverFn(ctx, id)
+}
+
+func (m *mockDriverService) GetLoyalty(ctx context.Context, id string) (driver.Loyalty, error) {
+	return m.GetLoyaltyFn(ctx, id)
+}
+
+type mockTierService struct {
+	GetTierFn         func(ctx context.Context, driverID string) (tier.Assignment, error)
+	ListTierHistoryFn func(ctx context.Context, driverID string) ([]tier.Change, error)
+}
+
+func (m *mockTierService) GetTier(ctx context.Context, driverID string) (tier.Assignment, error) {
+	return m.GetTierFn(ctx, driverID)
+}
+
+func (m *mockTierService) ListTierHistory(ctx context.Context, driverID string) ([]tier.Change, error) {
+	return m.ListTierHistoryFn(ctx, driverID)
+}
+
+type mockBadgeService struct {
+	ListBadgesFn func(ctx context.Context, driverID string) ([]achievement.Badge, error)
+}
+
+func (m *mockBadgeService) ListBadges(ctx context.Context, driverID string) ([]achievement.Badge, error) {
+	return m.ListBadgesFn(ctx, driverID)
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/open_loyalty"
+)
+
+// defaultImports is the number of latest imports listed.
+const defaultImports = 20
+
+type importAdmin interface {
+	ListImports(ctx context.Context, limit int) ([]open_loyalty.Import, error)
+	GetImport(ctx context.Context, id string) (open_loyalty.Import, error)
+	CheckImport(ctx context.Context, id string) (open_loyalty.Import, error)
+}
+
+// ListImports returns the latest loyalty member imports with the status of
+// each member.
+func ListImports(ia importAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultImports
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				encodeJSONError(w, errors.New("invalid limit"), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		imports, err := ia.ListImports(r.Context(), limit)
+		if err != nil {
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if imports == nil {
+			imports = []open_loyalty.Import{}
+		}
+		encodeJSONResp(w, imports, http.StatusOK)
+	}
+}
+
+func GetImport(ia importAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		imp, err := ia.GetImport(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			encodeImportError(w, err)
+			return
+		}
+		encodeJSONResp(w, imp, http.StatusOK)
+	}
+}
+
+// CheckImport polls the import status from the provider and retries failed
+// members.
+func CheckImport(ia importAdmin, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		imp, err := ia.CheckImport(r.Context(), id)
+		if err != nil {
+			encodeImportError(w, err)
+			return
+		}
+
+		logger.InfoContext(r.Context(), "import checked",
+			"import_id", id, "status", imp.Status, "user_id", userFromContext(r.Context()))
+		encodeJSONResp(w, imp, http.StatusOK)
+	}
+}
+
+func encodeImportError(w http.ResponseWriter, err error) {
+	if errors.Is(err, open_loyalty.ErrImportNotFound) {
+		encodeJSONError(w, err, http.StatusNotFound)
+		return
+	}
+	encodeJSONError(w, err, http.StatusInternalServerError)
+}
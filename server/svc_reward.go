@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/reward"
+)
+
+type rewardService interface {
+	ListEntitlements(ctx context.Context, f reward.EntitlementFilter) ([]reward.Entitlement, error)
+}
+
+type rewardAdmin interface {
+	rewardService
+	CreateCampaign(ctx context.Context, c reward.Campaign) (reward.Campaign, error)
+	GetCampaign(ctx context.Context, id string) (reward.Campaign, error)
+	ListCampaigns(ctx context.Context) ([]reward.Campaign, error)
+	UpdateStatus(ctx context.Context, t reward.Transition) ([]reward.Entitlement, error)
+	ListAudit(ctx context.Context, entitlementID string) ([]reward.AuditEntry, error)
+	Budget(ctx context.Context, campaignID string) (reward.BudgetReport, error)
+	Budgets(ctx context.Context) ([]reward.BudgetReport, error)
+}
+
+// entitlementActions are the statuses of the entitlement actions.
+var entitlementActions = map[string]string{
+	"approve": reward.StatusApproved,
+	"reject":  reward.StatusRejected,
+	"paid":    reward.StatusPaid,
+	"failed":  reward.StatusFailed,
+}
+
+// GetDriverEntitlements returns the latest rewards earned by the driver.
+func GetDriverEntitlements(rs rewardService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		f := reward.EntitlementFilter{DriverID: chi.URLParam(r, "id"), Period: r.URL.Query().Get("period")}
+		listEntitlements(w, r, rs, f)
+	}
+}
+
+// ListEntitlements returns the latest entitlements filtered by campaign_id,
+// period, zone and driver_id.
+func ListEntitlements(ra rewardAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		f := reward.EntitlementFilter{
+			CampaignID: q.Get("campaign_id"),
+			Period:     q.Get("period"),
+			Zone:       q.Get("zone"),
+			DriverID:   q.Get("driver_id"),
+			Status:     q.Get("status"),
+		}
+		listEntitlements(w, r, ra, f)
+	}
+}
+
+func listEntitlements(w http.ResponseWriter, r *http.Request, rs rewardService, f reward.EntitlementFilter) {
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			encodeJSONError(w, errors.New("invalid limit"), http.StatusBadRequest)
+			return
+		}
+		f.Limit = n
+	}
+
+	list, err := rs.ListEntitlements(r.Context(), f)
+	if err != nil {
+		encodeJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []reward.Entitlement{}
+	}
+	encodeJSONResp(w, list, http.StatusOK)
+}
+
+func ListCampaigns(ra rewardAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := ra.ListCampaigns(r.Context())
+		if err != nil {
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []reward.Campaign{}
+		}
+		encodeJSONResp(w, list, http.StatusOK)
+	}
+}
+
+func GetCampaign(ra rewardAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := ra.GetCampaign(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			if errors.Is(err, reward.ErrCampaignNotFound) {
+				encodeJSONError(w, err, http.StatusNotFound)
+				return
+			}
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		encodeJSONResp(w, c, http.StatusOK)
+	}
+}
+
+// GetCampaignBudget returns the committed and projected spend of the
+// campaign against its budgets per zone.
+func GetCampaignBudget(ra rewardAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		b, err := ra.Budget(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			if errors.Is(err, reward.ErrCampaignNotFound) {
+				encodeJSONError(w, err, http.StatusNotFound)
+				return
+			}
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		encodeJSONResp(w, b, http.StatusOK)
+	}
+}
+
+// ListBudgets returns the spend of the active campaigns.
+func ListBudgets(ra rewardAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := ra.Budgets(r.Context())
+		if err != nil {
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []reward.BudgetReport{}
+		}
+		encodeJSONResp(w, list, http.StatusOK)
+	}
+}
+
+// CreateCampaign creates an active campaign evaluated at the next period
+// close.
+func CreateCampaign(ra rewardAdmin, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var c reward.Campaign
+		if err := decodeJSONReq(r, &c); err != nil {
+			encodeJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		if err := c.Validate(); err != nil {
+			encodeJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		c.Active = true
+
+		c, err := ra.CreateCampaign(r.Context(), c)
+		if err != nil {
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		logger.InfoContext(r.Context(), "campaign created",
+			"campaign_id", c.ID, "user_id", userFromContext(r.Context()))
+		encodeJSONResp(w, c, http.StatusCreated)
+	}
+}
+
+// UpdateEntitlements approves, rejects or marks paid or failed the
+// entitlements of the request `{"ids": [...], "reason": "..."}` as the
+// authenticated user. All entitlements are updated or none.
+func UpdateEntitlements(ra rewardAdmin, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, ok := entitlementActions[chi.URLParam(r, "action")]
+		if !ok {
+			encodeJSONError(w, errors.New(http.StatusText(http.StatusNotFound)), http.StatusNotFound)
+			return
+		}
+		var t reward.Transition
+		if err := decodeJSONReq(r, &t); err != nil {
+			encodeJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		t.Status = status
+		t.Actor = userFromContext(r.Context())
+
+		list, err := ra.UpdateStatus(r.Context(), t)
+		if err != nil {
+			switch {
+			case errors.Is(err, reward.ErrInvalidRequest):
+				encodeJSONError(w, err, http.StatusBadRequest)
+			case errors.Is(err, reward.ErrInvalidTransition):
+				encodeJSONError(w, err, http.StatusConflict)
+			default:
+				encodeJSONError(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		logger.InfoContext(r.Context(), "entitlements updated",
+			"status", status, "ids", t.IDs, "reason", t.Reason, "user_id", t.Actor)
+		encodeJSONResp(w, list, http.StatusOK)
+	}
+}
+
+// ListEntitlementAudit returns the status changes of the entitlement, oldest
+// first.
+func ListEntitlementAudit(ra rewardAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := ra.ListAudit(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if list == nil {
+			list = []reward.AuditEntry{}
+		}
+		encodeJSONResp(w, list, http.StatusOK)
+	}
+}
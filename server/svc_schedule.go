@@ -0,0 +1,79 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+// defaultScheduleRuns is the number of latest runs listed per schedule.
+const defaultScheduleRuns = 5
+
+type schedule struct {
+	worker.ScheduleStats
+	Runs []worker.ScheduleRun `json:"runs"`
+}
+
+func ListSchedules(wa workerAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := defaultScheduleRuns
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				encodeJSONError(w, errors.New("invalid limit"), http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		stats := wa.Schedules()
+		ss := make([]schedule, 0, len(stats))
+		for _, st := range stats {
+			runs, err := wa.ScheduleRuns(r.Context(), st.Name, limit)
+			if err != nil {
+				encodeJSONError(w, err, http.StatusInternalServerError)
+				return
+			}
+			// Recorded runs includes runs of other replicas.
+			if len(runs) > 0 {
+				st.LastRun = &runs[0]
+			}
+			ss = append(ss, schedule{ScheduleStats: st, Runs: runs})
+		}
+		encodeJSONResp(w, ss, http.StatusOK)
+	}
+}
+
+// RunSchedule triggers the schedule in the background and responds with
+// the schedule details.
+func RunSchedule(wa workerAdmin, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := chi.URLParam(r, "name")
+		userID := userFromContext(r.Context())
+
+		if err := wa.RunSchedule(name, userID); err != nil {
+			switch {
+			case errors.Is(err, worker.ErrScheduleNotFound):
+				encodeJSONError(w, err, http.StatusNotFound)
+			case errors.Is(err, worker.ErrScheduleRunning):
+				encodeJSONError(w, err, http.StatusConflict)
+			default:
+				encodeJSONError(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+
+		logger.InfoContext(r.Context(), "schedule triggered", "schedule", name, "user_id", userID)
+		for _, st := range wa.Schedules() {
+			if st.Name == name {
+				encodeJSONResp(w, st, http.StatusAccepted)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+// Webhook signature headers, the signature is the hex HMAC-SHA256 of
+// "{timestamp}.{body}" with the webhook secret.
+const (
+	webhookSignatureHeader = "X-Webhook-Signature"
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	// webhookTolerance rejects deliveries signed too long ago to be replayed.
+	webhookTolerance = 5 * time.Minute
+	maxWebhookBody   = 1 << 20
+)
+
+// Open Loyalty webhook event types.
+const (
+	rewardRedeemedWebhook = "reward.redeemed"
+	levelChangedWebhook   = "member.level_changed"
+)
+
+type loyaltyWebhook interface {
+	ApplyLoyaltyEvent(ctx context.Context, e driver.LoyaltyEvent) (ok bool, err error)
+}
+
+// loyaltyWebhookRequest represents an Open Loyalty webhook delivery, the
+// member is identified by the loyalty card number which is the driver id.
+type loyaltyWebhookRequest struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      struct {
+		LoyaltyCardNumber string  `json:"loyaltyCardNumber"`
+		LevelName         string  `json:"levelName"`
+		RewardID          string  `json:"rewardId"`
+		RewardName        string  `json:"rewardName"`
+		CostInPoints      float64 `json:"costInPoints"`
+	} `json:"data"`
+}
+
+// LoyaltyWebhook receives Open Loyalty reward redemptions and member level
+// changes. Deliveries are verified with the secret, repeated deliveries and
+// other event types are acknowledged without applying them.
+func LoyaltyWebhook(lw loyaltyWebhook, secret string, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if secret == "" {
+			encodeJSONError(w, errors.New(http.StatusText(http.StatusNotFound)), http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+		if err != nil {
+			encodeJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		if err = verifyWebhook(r.Header, body, secret, time.Now()); err != nil {
+			encodeJSONError(w, err, http.StatusUnauthorized)
+			return
+		}
+
+		var req loyaltyWebhookRequest
+		if err = json.Unmarshal(body, &req); err != nil {
+			encodeJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+		if req.ID == "" || req.Data.LoyaltyCardNumber == "" {
+			encodeJSONError(w, errors.New("missing event id or loyalty card number"), http.StatusBadRequest)
+			return
+		}
+
+		e := driver.LoyaltyEvent{ID: req.ID, DriverID: req.Data.LoyaltyCardNumber, OccurredAt: req.CreatedAt}
+		switch req.Type {
+		case rewardRedeemedWebhook:
+			e.Type = driver.LoyaltyEventRewardRedeemed
+			e.Reward = &driver.Reward{ID: req.Data.RewardID, Name: req.Data.RewardName, CostInPoints: req.Data.CostInPoints}
+			e.Points = req.Data.CostInPoints
+		case levelChangedWebhook:
+			e.Type = driver.LoyaltyEventTierChanged
+			e.Tier = req.Data.LevelName
+		default:
+			logger.InfoContext(r.Context(), "loyalty webhook ignored", "event_id", req.ID, "type", req.Type)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		ok, err := lw.ApplyLoyaltyEvent(r.Context(), e)
+		if err != nil {
+			logger.ErrorContext(r.Context(), "failed to apply loyalty webhook", "event_id", req.ID, "error", err)
+			encodeJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			logger.InfoContext(r.Context(), "duplicate loyalty webhook", "event_id", req.ID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func verifyWebhook(h http.Header, body []byte, secret string, now time.Time) error {
+	ts := h.Get(webhookTimestampHeader)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("invalid webhook timestamp")
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > webhookTolerance || d < -webhookTolerance {
+		return errors.New("webhook timestamp outside tolerance")
+	}
+
+	sig, err := hex.DecodeString(h.Get(webhookSignatureHeader))
+	if err != nil {
+		return errors.New("invalid webhook signature")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return errors.New("invalid webhook signature")
+	}
+	return nil
+}
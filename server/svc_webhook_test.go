@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+func TestLoyaltyWebhook(t *testing.T) {
+	const secret = "secret"
+	redeemed := `{"id":"evt-1","type":"reward.redeemed","createdAt":"2024-05-06T10:00:00Z","data":{"loyaltyCardNumber":"driver-1","rewardId":"reward-1","rewardName":"Free ride","costInPoints":100}}`
+	sign := func(ts time.Time, body string) (string, string) {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(strconv.FormatInt(ts.Unix(), 10) + "." + body))
+		return strconv.FormatInt(ts.Unix(), 10), hex.EncodeToString(mac.Sum(nil))
+	}
+	occurred := time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		body      string
+		signedAt  time.Time
+		signature string
+		applyOK   bool
+		applyErr  error
+		wantCode  int
+		want      []driver.LoyaltyEvent
+	}{
+		{
+			"reward redeemed", redeemed, time.Now(), "", true, nil, http.StatusNoContent,
+			[]driver.LoyaltyEvent{{
+				ID: "evt-1", Type: driver.LoyaltyEventRewardRedeemed, DriverID: "driver-1", Points: 100, OccurredAt: occurred,
+				Reward: &driver.Reward{ID: "reward-1", Name: "Free ride", CostInPoints: 100},
+			}},
+		},
+		{
+			"level changed",
+			`{"id":"evt-2","type":"member.level_changed","createdAt":"2024-05-06T10:00:00Z","data":{"loyaltyCardNumber":"driver-1","levelName":"Gold"}}`,
+			time.Now(), "", true, nil, http.StatusNoContent,
+			[]driver.LoyaltyEvent{{ID: "evt-2", Type: driver.LoyaltyEventTierChanged, DriverID: "driver-1", Tier: "Gold", OccurredAt: occurred}},
+		},
+		{"duplicate delivery is acknowledged", redeemed, time.Now(), "", false, nil, http.StatusNoContent, []driver.LoyaltyEvent{{}}},
+		{"other events are ignored", `{"id":"evt-3","type":"member.registered","data":{"loyaltyCardNumber":"driver-1"}}`, time.Now(), "", true, nil, http.StatusNoContent, nil},
+		{"invalid signature", redeemed, time.Now(), "00", true, nil, http.StatusUnauthorized, nil},
+		{"replayed delivery", redeemed, time.Now().Add(-time.Hour), "", true, nil, http.StatusUnauthorized, nil},
+		{"apply failed is redelivered", redeemed, time.Now(), "", false, errors.New("redis down"), http.StatusInternalServerError, []driver.LoyaltyEvent{{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var applied []driver.LoyaltyEvent
+			lw := &mockLoyaltyWebhook{ApplyLoyaltyEventFn: func(ctx context.Context, e driver.LoyaltyEvent) (bool, error) {
+				applied = append(applied, e)
+				return tt.applyOK, tt.applyErr
+			}}
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/openloyalty", strings.NewReader(tt.body))
+			ts, sig := sign(tt.signedAt, tt.body)
+			if tt.signature != "" {
+				sig = tt.signature
+			}
+			req.Header.Set(webhookTimestampHeader, ts)
+			req.Header.Set(webhookSignatureHeader, sig)
+			w := httptest.NewRecorder()
+			LoyaltyWebhook(lw, secret, slog.New(slog.NewTextHandler(io.Discard, nil))).ServeHTTP(w, req)
+
+			if w.Code != tt.wantCode {
+				t.Errorf("LoyaltyWebhook() status = %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if len(applied) != len(tt.want) {
+				t.Fatalf("applied events = %+v, want %+v", applied, tt.want)
+			}
+			if len(tt.want) > 0 && tt.want[0].ID != "" && !reflect.DeepEqual(applied, tt.want) {
+				t.Errorf("applied events = %+v, want %+v", applied, tt.want)
+			}
+		})
+	}
+}
+
+type mockLoyaltyWebhook struct {
+	ApplyLoyaltyEventFn func(ctx context.Context, e driver.LoyaltyEvent) (bool, error)
+}
+
+func (m *mockLoyaltyWebhook) ApplyLoyaltyEvent(ctx context.Context, e driver.LoyaltyEvent) (bool, error) {
+	return m.ApplyLoyaltyEventFn(ctx, e)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+type workerAdmin interface {
+	Pause(topic string) error
+	Resume(topic string) error
+	Stats() worker.Stats
+	Schedules() []worker.ScheduleStats
+	ScheduleRuns(ctx context.Context, name string, limit int) ([]worker.ScheduleRun, error)
+	RunSchedule(name, triggeredBy string) error
+}
+
+func GetWorkerStats(wa workerAdmin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		encodeJSONResp(w, wa.Stats(), http.StatusOK)
+	}
+}
+
+func PauseWorkerTopic(wa workerAdmin, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topic := chi.URLParam(r, "topic")
+		if topic == "" {
+			encodeJSONError(w, errMissingTopic, http.StatusBadRequest)
+			return
+		}
+		if err := wa.Pause(topic); err != nil {
+			encodeJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		logger.InfoContext(r.Context(), "worker topic paused", "topic", topic, "user_id", userFromContext(r.Context()))
+		encodeJSONResp(w, wa.Stats().Topics[topic], http.StatusOK)
+	}
+}
+
+func ResumeWorkerTopic(wa workerAdmin, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		topic := chi.URLParam(r, "topic")
+		if topic == "" {
+			encodeJSONError(w, errMissingTopic, http.StatusBadRequest)
+			return
+		}
+		if err := wa.Resume(topic); err != nil {
+			encodeJSONError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		logger.InfoContext(r.Context(), "worker topic resumed", "topic", topic, "user_id", userFromContext(r.Context()))
+		encodeJSONResp(w, wa.Stats().Topics[topic], http.StatusOK)
+	}
+}
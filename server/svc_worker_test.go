@@ -0,0 +1,158 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/open_loyalty"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	var triggeredBy string
+	wa := &mockWorkerAdmin{
+		PauseFn: func(topic string) error {
+			if topic != "trips" {
+				return errors.New("topic not handled")
+			}
+			return nil
+		},
+		StatsFn: func() worker.Stats {
+			return worker.Stats{Topics: map[string]worker.TopicStats{"trips": {Paused: true}}}
+		},
+		SchedulesFn: func() []worker.ScheduleStats {
+			return []worker.ScheduleStats{{Name: "close-period", Running: triggeredBy != ""}}
+		},
+		ScheduleRunsFn: func(ctx context.Context, name string, limit int) ([]worker.ScheduleRun, error) {
+			return []worker.ScheduleRun{{Name: name, TriggeredBy: "other-replica"}}, nil
+		},
+		RunScheduleFn: func(name, by string) error {
+			switch {
+			case name != "close-period":
+				return worker.ErrScheduleNotFound
+			case triggeredBy != "":
+				return worker.ErrScheduleRunning
+			}
+			triggeredBy = by
+			return nil
+		},
+	}
+	auth := &mockAuthenticator{VerifyTokenFn: func(ctx context.Context, token string) (map[string]interface{}, error) {
+		if token != "valid" {
+			return nil, errors.New("invalid token")
+		}
+		return map[string]interface{}{"id": "admin-1"}, nil
+	}}
+	ia := &mockImportAdmin{
+		GetImportFn: func(ctx context.Context, id string) (open_loyalty.Import, error) {
+			if id != "import-1" {
+				return open_loyalty.Import{}, open_loyalty.ErrImportNotFound
+			}
+			return open_loyalty.Import{ID: id, Status: open_loyalty.ImportPartial}, nil
+		},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := NewAdmin(Config{}, wa, ia, nil, auth, &mockTracing{}, Version{}, logger)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{"missing token", http.MethodGet, "/admin/worker", "", http.StatusForbidden, "missing bearer token"},
+		{"invalid token", http.MethodGet, "/admin/worker", "invalid", http.StatusForbidden, "invalid token"},
+		{"stats", http.MethodGet, "/admin/worker", "valid", http.StatusOK, `"paused":true`},
+		{"pause", http.MethodPost, "/admin/worker/topics/trips/pause", "valid", http.StatusOK, `"paused":true`},
+		{"pause unknown topic", http.MethodPost, "/admin/worker/topics/other/pause", "valid", http.StatusBadRequest, "topic not handled"},
+		{"resume", http.MethodPost, "/admin/worker/topics/trips/resume", "valid", http.StatusOK, `"paused":true`},
+		{"schedules", http.MethodGet, "/admin/schedules", "valid", http.StatusOK, `"last_run":{"name":"close-period","tick":"0001-01-01T00:00:00Z","triggered_by":"other-replica"`},
+		{"invalid runs limit", http.MethodGet, "/admin/schedules?limit=0", "valid", http.StatusBadRequest, "invalid limit"},
+		{"run schedule", http.MethodPost, "/admin/schedules/close-period/run", "valid", http.StatusAccepted, `"running":true`},
+		{"run running schedule", http.MethodPost, "/admin/schedules/close-period/run", "valid", http.StatusConflict, "schedule is running"},
+		{"run unknown schedule", http.MethodPost, "/admin/schedules/other/run", "valid", http.StatusNotFound, "schedule not found"},
+		{"imports", http.MethodGet, "/admin/imports", "valid", http.StatusOK, `"status":"partially_failed"`},
+		{"check import", http.MethodPost, "/admin/imports/import-1/check", "valid", http.StatusOK, `"id":"import-1"`},
+		{"unknown import", http.MethodGet, "/admin/imports/other", "valid", http.StatusNotFound, "import not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://localhost"+tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			s.Handler.ServeHTTP(w, req)
+
+			resp := w.Result()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantCode)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %s, want contains %s", body, tt.wantBody)
+			}
+		})
+	}
+	if triggeredBy != "admin-1" {
+		t.Errorf("schedule triggered by %q, want admin-1", triggeredBy)
+	}
+}
+
+type mockWorkerAdmin struct {
+	PauseFn        func(topic string) error
+	StatsFn        func() worker.Stats
+	SchedulesFn    func() []worker.ScheduleStats
+	ScheduleRunsFn func(ctx context.Context, name string, limit int) ([]worker.ScheduleRun, error)
+	RunScheduleFn  func(name, triggeredBy string) error
+}
+
+func (m *mockWorkerAdmin) Pause(topic string) error  { return m.PauseFn(topic) }
+func (m *mockWorkerAdmin) Resume(topic string) error { return nil }
+func (m *mockWorkerAdmin) Stats() worker.Stats       { return m.StatsFn() }
+func (m *mockWorkerAdmin) Schedules() []worker.ScheduleStats {
+	return m.SchedulesFn()
+}
+func (m *mockWorkerAdmin) ScheduleRuns(ctx context.Context, name string, limit int) ([]worker.ScheduleRun, error) {
+	return m.ScheduleRunsFn(ctx, name, limit)
+}
+func (m *mockWorkerAdmin) RunSchedule(name, triggeredBy string) error {
+	return m.RunScheduleFn(name, triggeredBy)
+}
+
+type mockImportAdmin struct {
+	GetImportFn func(ctx context.Context, id string) (open_loyalty.Import, error)
+}
+
+func (m *mockImportAdmin) ListImports(ctx context.Context, limit int) ([]open_loyalty.Import, error) {
+	imp, err := m.GetImportFn(ctx, "import-1")
+	return []open_loyalty.Import{imp}, err
+}
+func (m *mockImportAdmin) GetImport(ctx context.Context, id string) (open_loyalty.Import, error) {
+	return m.GetImportFn(ctx, id)
+}
+func (m *mockImportAdmin) CheckImport(ctx context.Context, id string) (open_loyalty.Import, error) {
+	return m.GetImportFn(ctx, id)
+}
+
+type mockAuthenticator struct {
+	VerifyTokenFn func(ctx context.Context, token string) (map[string]interface{}, error)
+}
+
+func (m *mockAuthenticator) VerifyToken(ctx context.Context, token string) (map[string]interface{}, error) {
+	return m.VerifyTokenFn(ctx, token)
+}
+
+type mockTracing struct{}
+
+func (m *mockTracing) Middleware() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler { return next }
+}
@@ -0,0 +1,34 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+// fence saves the lease token of the schedule run in ctx, writes of tx are
+// rejected with worker.ErrStaleLease when a later lease of the schedule
+// already wrote. Writes outside schedule runs are not fenced.
+func fence(ctx context.Context, tx pgx.Tx) error {
+	lease, ok := worker.LeaseFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO schedule_fences (schedule, token, updated_at)
+		VALUES ($1, $2, now())
+		ON CONFLICT (schedule) DO UPDATE SET token = EXCLUDED.token, updated_at = EXCLUDED.updated_at
+		WHERE schedule_fences.token <= EXCLUDED.token`,
+		lease.Schedule, lease.Token,
+	)
+	if err != nil {
+		return fmt.Errorf("could not fence schedule %s: %s", lease.Schedule, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: schedule %s token %d", worker.ErrStaleLease, lease.Schedule, lease.Token)
+	}
+	return nil
+}
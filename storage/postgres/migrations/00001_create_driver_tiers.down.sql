@@ -1 +1 @@
-DROP TABLE fighters;
\ No newline at end of file
+DROP TABLE tiers;
@@ -6,6 +6,3 @@ CREATE TABLE tiers (
     last_name text,
     PRIMARY KEY(id)
 );
-
--- demo purposes
-INSERT INTO fighters (first_name, last_name) VALUES ('Dave', 'Grohl');
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE reward_entitlements;
+DROP TABLE leaderboard_standings;
+DROP TABLE reward_campaigns;
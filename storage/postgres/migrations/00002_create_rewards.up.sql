@@ -0,0 +1,34 @@
+CREATE TABLE reward_campaigns (
+    id uuid DEFAULT uuid_generate_v4(),
+    name text NOT NULL,
+    zones text[] NOT NULL DEFAULT '{}',
+    bands jsonb NOT NULL,
+    active boolean NOT NULL DEFAULT true,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY(id)
+);
+
+-- leaderboard of each zone frozen at period close
+CREATE TABLE leaderboard_standings (
+    period text NOT NULL,
+    zone text NOT NULL,
+    drivers jsonb NOT NULL,
+    frozen_at timestamptz NOT NULL,
+    PRIMARY KEY(period, zone)
+);
+
+CREATE TABLE reward_entitlements (
+    id uuid DEFAULT uuid_generate_v4(),
+    campaign_id uuid NOT NULL REFERENCES reward_campaigns(id),
+    period text NOT NULL,
+    zone text NOT NULL,
+    driver_id text NOT NULL,
+    rank int NOT NULL,
+    score double precision NOT NULL,
+    reward jsonb NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY(id),
+    UNIQUE(campaign_id, period, zone, driver_id)
+);
+
+CREATE INDEX reward_entitlements_driver_id_idx ON reward_entitlements (driver_id, created_at DESC);
@@ -0,0 +1,8 @@
+DROP TABLE reward_entitlement_audit;
+
+ALTER TABLE reward_entitlements
+    DROP COLUMN status,
+    DROP COLUMN reason,
+    DROP COLUMN payout_attempt,
+    DROP COLUMN published_at,
+    DROP COLUMN updated_at;
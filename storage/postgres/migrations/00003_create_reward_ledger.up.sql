@@ -0,0 +1,21 @@
+ALTER TABLE reward_entitlements
+    ADD COLUMN status text NOT NULL DEFAULT 'pending',
+    ADD COLUMN reason text NOT NULL DEFAULT '',
+    ADD COLUMN payout_attempt integer NOT NULL DEFAULT 0,
+    ADD COLUMN published_at timestamptz,
+    ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now();
+
+CREATE INDEX reward_entitlements_status_idx ON reward_entitlements (status, updated_at);
+
+CREATE TABLE reward_entitlement_audit (
+    id bigserial,
+    entitlement_id uuid NOT NULL REFERENCES reward_entitlements(id),
+    from_status text NOT NULL,
+    to_status text NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    actor text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY(id)
+);
+
+CREATE INDEX reward_entitlement_audit_entitlement_id_idx ON reward_entitlement_audit (entitlement_id, created_at);
@@ -0,0 +1,5 @@
+DROP TABLE reward_budget_alerts;
+
+ALTER TABLE reward_campaigns
+    DROP COLUMN budget,
+    DROP COLUMN zone_budgets;
@@ -0,0 +1,16 @@
+ALTER TABLE reward_campaigns
+    ADD COLUMN budget double precision NOT NULL DEFAULT 0,
+    ADD COLUMN zone_budgets jsonb NOT NULL DEFAULT '{}';
+
+CREATE TABLE reward_budget_alerts (
+    campaign_id uuid NOT NULL REFERENCES reward_campaigns(id),
+    zone text NOT NULL,
+    metric text NOT NULL,
+    threshold int NOT NULL,
+    budget double precision NOT NULL,
+    spend double precision NOT NULL,
+    alerted_at timestamptz NOT NULL DEFAULT now(),
+    -- published_at is set once the alert is delivered
+    published_at timestamptz,
+    PRIMARY KEY(campaign_id, zone, metric, threshold, budget)
+);
@@ -0,0 +1,9 @@
+DROP TABLE driver_tier_history;
+DROP TABLE driver_tiers;
+
+CREATE TABLE tiers (
+    id uuid DEFAULT uuid_generate_v4(),
+    frequency float,
+    last_name text,
+    PRIMARY KEY(id)
+);
@@ -0,0 +1,29 @@
+DROP TABLE IF EXISTS tiers;
+
+CREATE TABLE driver_tiers (
+    driver_id text NOT NULL,
+    tier text NOT NULL,
+    score double precision NOT NULL,
+    rfm jsonb NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY(driver_id)
+);
+
+-- tier changes of each recalculation, drivers can change tier more than once
+-- in a period
+CREATE TABLE driver_tier_history (
+    id bigserial,
+    driver_id text NOT NULL,
+    from_tier text NOT NULL,
+    to_tier text NOT NULL,
+    score double precision NOT NULL,
+    rfm jsonb NOT NULL,
+    period text NOT NULL,
+    changed_at timestamptz NOT NULL DEFAULT now(),
+    -- published_at is set once the change is delivered
+    published_at timestamptz,
+    PRIMARY KEY(id),
+    UNIQUE(driver_id, changed_at)
+);
+
+CREATE INDEX driver_tier_history_unpublished_idx ON driver_tier_history (changed_at, id) WHERE published_at IS NULL;
@@ -0,0 +1,5 @@
+CREATE TABLE schedule_fences (
+    schedule text PRIMARY KEY,
+    token bigint NOT NULL,
+    updated_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,456 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/reward"
+)
+
+// defaultEntitlements is the number of entitlements listed without limit.
+const defaultEntitlements = 100
+
+func (c *Client) CreateCampaign(ctx context.Context, campaign reward.Campaign) (reward.Campaign, error) {
+	bands, err := json.Marshal(campaign.Bands)
+	if err != nil {
+		return reward.Campaign{}, fmt.Errorf("could not marshal campaign bands: %s", err)
+	}
+	if campaign.Zones == nil {
+		campaign.Zones = []string{}
+	}
+	if campaign.ZoneBudgets == nil {
+		campaign.ZoneBudgets = map[string]float64{}
+	}
+	zoneBudgets, err := json.Marshal(campaign.ZoneBudgets)
+	if err != nil {
+		return reward.Campaign{}, fmt.Errorf("could not marshal campaign zone budgets: %s", err)
+	}
+
+	err = c.db.QueryRow(ctx, `
+		INSERT INTO reward_campaigns (name, zones, bands, budget, zone_budgets, active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id::text, created_at`,
+		campaign.Name, campaign.Zones, bands, campaign.Budget, zoneBudgets, campaign.Active,
+	).Scan(&campaign.ID, &campaign.CreatedAt)
+	if err != nil {
+		return reward.Campaign{}, fmt.Errorf("could not create campaign: %s", err)
+	}
+	return campaign, nil
+}
+
+func (c *Client) GetCampaign(ctx context.Context, id string) (reward.Campaign, error) {
+	rows, err := c.db.Query(ctx, `
+		SELECT id::text, name, zones, bands, budget, zone_budgets, active, created_at
+		FROM reward_campaigns WHERE id::text = $1`, id)
+	if err != nil {
+		return reward.Campaign{}, fmt.Errorf("could not get campaign: %s", err)
+	}
+	list, err := scanCampaigns(rows)
+	if err != nil {
+		return reward.Campaign{}, err
+	}
+	if len(list) == 0 {
+		return reward.Campaign{}, reward.ErrCampaignNotFound
+	}
+	return list[0], nil
+}
+
+func (c *Client) ListCampaigns(ctx context.Context, activeOnly bool) ([]reward.Campaign, error) {
+	rows, err := c.db.Query(ctx, `
+		SELECT id::text, name, zones, bands, budget, zone_budgets, active, created_at
+		FROM reward_campaigns WHERE active OR NOT $1
+		ORDER BY created_at`, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("could not list campaigns: %s", err)
+	}
+	return scanCampaigns(rows)
+}
+
+func scanCampaigns(rows pgx.Rows) ([]reward.Campaign, error) {
+	defer rows.Close()
+
+	var list []reward.Campaign
+	for rows.Next() {
+		var (
+			campaign    reward.Campaign
+			bands       []byte
+			zoneBudgets []byte
+		)
+		err := rows.Scan(&campaign.ID, &campaign.Name, &campaign.Zones, &bands, &campaign.Budget, &zoneBudgets,
+			&campaign.Active, &campaign.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan campaign: %s", err)
+		}
+		if err = json.Unmarshal(bands, &campaign.Bands); err != nil {
+			return nil, fmt.Errorf("could not unmarshal campaign bands: %s", err)
+		}
+		if err = json.Unmarshal(zoneBudgets, &campaign.ZoneBudgets); err != nil {
+			return nil, fmt.Errorf("could not unmarshal campaign zone budgets: %s", err)
+		}
+		list = append(list, campaign)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read campaigns: %s", err)
+	}
+	return list, nil
+}
+
+// FreezeStandings saves the standings unless the period zone is frozen, the
+// frozen standings are returned.
+func (c *Client) FreezeStandings(ctx context.Context, s reward.Standings) (reward.Standings, error) {
+	drivers, err := json.Marshal(s.Drivers)
+	if err != nil {
+		return reward.Standings{}, fmt.Errorf("could not marshal standings: %s", err)
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return reward.Standings{}, fmt.Errorf("could not begin transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+	if err = fence(ctx, tx); err != nil {
+		return reward.Standings{}, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO leaderboard_standings (period, zone, drivers, frozen_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (period, zone) DO NOTHING`,
+		s.Period, s.Zone, drivers, s.FrozenAt,
+	)
+	if err != nil {
+		return reward.Standings{}, fmt.Errorf("could not freeze standings: %s", err)
+	}
+
+	frozen := reward.Standings{Period: s.Period, Zone: s.Zone}
+	err = tx.QueryRow(ctx, `
+		SELECT drivers, frozen_at FROM leaderboard_standings
+		WHERE period = $1 AND zone = $2`, s.Period, s.Zone,
+	).Scan(&drivers, &frozen.FrozenAt)
+	if err != nil {
+		return reward.Standings{}, fmt.Errorf("could not get standings: %s", err)
+	}
+	if err = json.Unmarshal(drivers, &frozen.Drivers); err != nil {
+		return reward.Standings{}, fmt.Errorf("could not unmarshal standings: %s", err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return reward.Standings{}, fmt.Errorf("could not commit standings: %s", err)
+	}
+	return frozen, nil
+}
+
+// LastStandings returns the standings of the zone frozen last, false when the
+// zone has none.
+func (c *Client) LastStandings(ctx context.Context, zone string) (reward.Standings, bool, error) {
+	var (
+		s       = reward.Standings{Zone: zone}
+		drivers []byte
+	)
+	err := c.db.QueryRow(ctx, `
+		SELECT period, drivers, frozen_at FROM leaderboard_standings
+		WHERE zone = $1 ORDER BY period DESC LIMIT 1`, zone,
+	).Scan(&s.Period, &drivers, &s.FrozenAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return reward.Standings{}, false, nil
+		}
+		return reward.Standings{}, false, fmt.Errorf("could not get last standings: %s", err)
+	}
+	if err = json.Unmarshal(drivers, &s.Drivers); err != nil {
+		return reward.Standings{}, false, fmt.Errorf("could not unmarshal standings: %s", err)
+	}
+	return s, true, nil
+}
+
+// SaveEntitlements inserts the entitlements in a transaction, entitlements
+// of the campaign, period, zone and driver already saved are skipped.
+func (c *Client) SaveEntitlements(ctx context.Context, list []reward.Entitlement) (int, error) {
+	if len(list) == 0 {
+		return 0, nil
+	}
+
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("could not begin transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+	if err = fence(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	saved := 0
+	for _, e := range list {
+		r, err := json.Marshal(e.Reward)
+		if err != nil {
+			return 0, fmt.Errorf("could not marshal reward: %s", err)
+		}
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO reward_entitlements (campaign_id, period, zone, driver_id, rank, score, reward, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (campaign_id, period, zone, driver_id) DO NOTHING`,
+			e.CampaignID, e.Period, e.Zone, e.DriverID, e.Rank, e.Score, r, e.Status,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("could not save entitlement: %s", err)
+		}
+		saved += int(tag.RowsAffected())
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("could not commit entitlements: %s", err)
+	}
+	return saved, nil
+}
+
+// ListEntitlements returns the latest entitlements matching the filter.
+func (c *Client) ListEntitlements(ctx context.Context, f reward.EntitlementFilter) ([]reward.Entitlement, error) {
+	var (
+		where []string
+		args  []any
+	)
+	for _, cond := range []struct{ column, value string }{
+		{"campaign_id::text", f.CampaignID},
+		{"period", f.Period},
+		{"zone", f.Zone},
+		{"driver_id", f.DriverID},
+		{"status", f.Status},
+	} {
+		if cond.value == "" {
+			continue
+		}
+		args = append(args, cond.value)
+		where = append(where, fmt.Sprintf("%s = $%d", cond.column, len(args)))
+	}
+	query := "SELECT " + entitlementColumns + " FROM reward_entitlements"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultEntitlements
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, rank LIMIT $%d", len(args))
+
+	rows, err := c.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not list entitlements: %s", err)
+	}
+	return scanEntitlements(rows)
+}
+
+const entitlementColumns = `id::text, campaign_id::text, period, zone, driver_id, rank, score, reward,
+	status, reason, payout_attempt, published_at, created_at, updated_at`
+
+func scanEntitlements(rows pgx.Rows) ([]reward.Entitlement, error) {
+	defer rows.Close()
+
+	var list []reward.Entitlement
+	for rows.Next() {
+		var (
+			e reward.Entitlement
+			r []byte
+		)
+		err := rows.Scan(&e.ID, &e.CampaignID, &e.Period, &e.Zone, &e.DriverID, &e.Rank, &e.Score, &r,
+			&e.Status, &e.Reason, &e.PayoutAttempt, &e.PublishedAt, &e.CreatedAt, &e.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan entitlement: %s", err)
+		}
+		if err = json.Unmarshal(r, &e.Reward); err != nil {
+			return nil, fmt.Errorf("could not unmarshal reward: %s", err)
+		}
+		list = append(list, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read entitlements: %s", err)
+	}
+	return list, nil
+}
+
+// UpdateEntitlementStatus locks the entitlements and moves them to the
+// status with an audit entry each. Approving starts a new payout attempt and
+// clears published_at to publish it.
+func (c *Client) UpdateEntitlementStatus(ctx context.Context, t reward.Transition) ([]reward.Entitlement, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		SELECT id::text, status FROM reward_entitlements
+		WHERE id::text = ANY($1) FOR UPDATE`, t.IDs)
+	if err != nil {
+		return nil, fmt.Errorf("could not lock entitlements: %s", err)
+	}
+	current := map[string]string{}
+	for rows.Next() {
+		var id, status string
+		if err = rows.Scan(&id, &status); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("could not scan entitlement status: %s", err)
+		}
+		current[id] = status
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read entitlement status: %s", err)
+	}
+	for _, id := range t.IDs {
+		status, ok := current[id]
+		if !ok {
+			return nil, fmt.Errorf("%w: entitlement %s not found", reward.ErrInvalidTransition, id)
+		}
+		if !slices.Contains(t.From, status) {
+			return nil, fmt.Errorf("%w: entitlement %s is %s", reward.ErrInvalidTransition, id, status)
+		}
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO reward_entitlement_audit (entitlement_id, from_status, to_status, reason, actor)
+		SELECT id, status, $2, $3, $4 FROM reward_entitlements WHERE id::text = ANY($1)`,
+		t.IDs, t.Status, t.Reason, t.Actor,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not save entitlement audit: %s", err)
+	}
+	rows, err = tx.Query(ctx, `
+		UPDATE reward_entitlements SET status = $2, reason = $3, updated_at = now(),
+			payout_attempt = CASE WHEN $2 = 'approved' THEN payout_attempt + 1 ELSE payout_attempt END,
+			published_at = CASE WHEN $2 = 'approved' THEN NULL ELSE published_at END
+		WHERE id::text = ANY($1)
+		RETURNING `+entitlementColumns,
+		t.IDs, t.Status, t.Reason,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not update entitlements: %s", err)
+	}
+	list, err := scanEntitlements(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not commit entitlements: %s", err)
+	}
+	return list, nil
+}
+
+func (c *Client) ListAudit(ctx context.Context, entitlementID string) ([]reward.AuditEntry, error) {
+	rows, err := c.db.Query(ctx, `
+		SELECT id, entitlement_id::text, from_status, to_status, reason, actor, created_at
+		FROM reward_entitlement_audit WHERE entitlement_id::text = $1
+		ORDER BY created_at, id`, entitlementID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list entitlement audit: %s", err)
+	}
+	defer rows.Close()
+
+	var list []reward.AuditEntry
+	for rows.Next() {
+		var a reward.AuditEntry
+		err = rows.Scan(&a.ID, &a.EntitlementID, &a.FromStatus, &a.ToStatus, &a.Reason, &a.Actor, &a.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan entitlement audit: %s", err)
+		}
+		list = append(list, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read entitlement audit: %s", err)
+	}
+	return list, nil
+}
+
+// ListUnpublishedPayouts returns approved entitlements without published
+// payout, oldest first.
+func (c *Client) ListUnpublishedPayouts(ctx context.Context, limit int) ([]reward.Entitlement, error) {
+	rows, err := c.db.Query(ctx, "SELECT "+entitlementColumns+`
+		FROM reward_entitlements WHERE status = 'approved' AND published_at IS NULL
+		ORDER BY updated_at LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not list unpublished payouts: %s", err)
+	}
+	return scanEntitlements(rows)
+}
+
+func (c *Client) MarkPublished(ctx context.Context, id string, at time.Time) error {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+	if err = fence(ctx, tx); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE reward_entitlements SET published_at = $2
+		WHERE id::text = $1 AND status = 'approved'`, id, at)
+	if err != nil {
+		return fmt.Errorf("could not mark payout %s published: %s", id, err)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return fmt.Errorf("could not commit payout %s: %s", id, err)
+	}
+	return nil
+}
+
+// SumEntitlementCosts sums the reward cost of the campaign entitlements by
+// zone and status, cash rewards without cost cost the amount.
+func (c *Client) SumEntitlementCosts(ctx context.Context, campaignID string) ([]reward.ZoneCost, error) {
+	rows, err := c.db.Query(ctx, `
+		SELECT zone, status, SUM(COALESCE(NULLIF((reward->>'cost')::float8, 0),
+			CASE WHEN reward->>'type' = 'cash' THEN (reward->>'amount')::float8 ELSE 0 END))
+		FROM reward_entitlements WHERE campaign_id::text = $1
+		GROUP BY zone, status`, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("could not sum entitlement costs: %s", err)
+	}
+	defer rows.Close()
+
+	var list []reward.ZoneCost
+	for rows.Next() {
+		var zc reward.ZoneCost
+		if err = rows.Scan(&zc.Zone, &zc.Status, &zc.Cost); err != nil {
+			return nil, fmt.Errorf("could not scan entitlement cost: %s", err)
+		}
+		list = append(list, zc)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read entitlement costs: %s", err)
+	}
+	return list, nil
+}
+
+// SaveBudgetAlert saves the alert unless published for the campaign zone,
+// metric, threshold and budget, alerts not published yet are updated.
+func (c *Client) SaveBudgetAlert(ctx context.Context, a reward.BudgetAlert) (bool, error) {
+	tag, err := c.db.Exec(ctx, `
+		INSERT INTO reward_budget_alerts (campaign_id, zone, metric, threshold, budget, spend, alerted_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (campaign_id, zone, metric, threshold, budget) DO UPDATE
+		SET spend = EXCLUDED.spend, alerted_at = EXCLUDED.alerted_at
+		WHERE reward_budget_alerts.published_at IS NULL`,
+		a.CampaignID, a.Zone, a.Metric, a.Threshold, a.Budget, a.Spend, a.AlertedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("could not save budget alert: %s", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (c *Client) MarkBudgetAlertPublished(ctx context.Context, a reward.BudgetAlert, at time.Time) error {
+	_, err := c.db.Exec(ctx, `
+		UPDATE reward_budget_alerts SET published_at = $6
+		WHERE campaign_id = $1 AND zone = $2 AND metric = $3 AND threshold = $4 AND budget = $5`,
+		a.CampaignID, a.Zone, a.Metric, a.Threshold, a.Budget, at,
+	)
+	if err != nil {
+		return fmt.Errorf("could not mark budget alert published: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/tier"
+)
+
+func (c *Client) GetTier(ctx context.Context, driverID string) (tier.Assignment, bool, error) {
+	var (
+		a   = tier.Assignment{DriverID: driverID}
+		rfm []byte
+	)
+	err := c.db.QueryRow(ctx, `
+		SELECT tier, score, rfm, updated_at FROM driver_tiers WHERE driver_id = $1`, driverID,
+	).Scan(&a.Tier, &a.Score, &rfm, &a.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return tier.Assignment{}, false, nil
+		}
+		return tier.Assignment{}, false, fmt.Errorf("could not get tier: %s", err)
+	}
+	if err = json.Unmarshal(rfm, &a.RFM); err != nil {
+		return tier.Assignment{}, false, fmt.Errorf("could not unmarshal tier rfm: %s", err)
+	}
+	return a, true, nil
+}
+
+// ListTiers returns the current tier of the drivers by driver id.
+func (c *Client) ListTiers(ctx context.Context) (map[string]tier.Assignment, error) {
+	rows, err := c.db.Query(ctx, `SELECT driver_id, tier, score, rfm, updated_at FROM driver_tiers`)
+	if err != nil {
+		return nil, fmt.Errorf("could not list tiers: %s", err)
+	}
+	defer rows.Close()
+
+	list := map[string]tier.Assignment{}
+	for rows.Next() {
+		var (
+			a   tier.Assignment
+			rfm []byte
+		)
+		if err = rows.Scan(&a.DriverID, &a.Tier, &a.Score, &rfm, &a.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan tier: %s", err)
+		}
+		if err = json.Unmarshal(rfm, &a.RFM); err != nil {
+			return nil, fmt.Errorf("could not unmarshal tier rfm: %s", err)
+		}
+		list[a.DriverID] = a
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read tiers: %s", err)
+	}
+	return list, nil
+}
+
+// SaveTiers upserts the tiers and inserts the changes in a transaction,
+// changes of the driver already saved in the recalculation are skipped.
+func (c *Client) SaveTiers(ctx context.Context, list []tier.Assignment, changes []tier.Change) ([]tier.Change, error) {
+	tx, err := c.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %s", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, a := range list {
+		rfm, err := json.Marshal(a.RFM)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal tier rfm: %s", err)
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO driver_tiers (driver_id, tier, score, rfm, updated_at)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (driver_id) DO UPDATE
+			SET tier = EXCLUDED.tier, score = EXCLUDED.score, rfm = EXCLUDED.rfm, updated_at = EXCLUDED.updated_at`,
+			a.DriverID, a.Tier, a.Score, rfm, a.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not save tier: %s", err)
+		}
+	}
+
+	var saved []tier.Change
+	for _, ch := range changes {
+		rfm, err := json.Marshal(ch.RFM)
+		if err != nil {
+			return nil, fmt.Errorf("could not marshal tier rfm: %s", err)
+		}
+		err = tx.QueryRow(ctx, `
+			INSERT INTO driver_tier_history (driver_id, from_tier, to_tier, score, rfm, period, changed_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (driver_id, changed_at) DO NOTHING
+			RETURNING id`,
+			ch.DriverID, ch.From, ch.To, ch.Score, rfm, ch.Period, ch.ChangedAt,
+		).Scan(&ch.ID)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
+			}
+			return nil, fmt.Errorf("could not save tier change: %s", err)
+		}
+		saved = append(saved, ch)
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("could not commit tiers: %s", err)
+	}
+	return saved, nil
+}
+
+func (c *Client) ListTierHistory(ctx context.Context, driverID string) ([]tier.Change, error) {
+	rows, err := c.db.Query(ctx, `
+		SELECT id, from_tier, to_tier, score, rfm, period, changed_at
+		FROM driver_tier_history WHERE driver_id = $1
+		ORDER BY changed_at DESC, id DESC`, driverID)
+	if err != nil {
+		return nil, fmt.Errorf("could not list tier history: %s", err)
+	}
+	defer rows.Close()
+
+	var list []tier.Change
+	for rows.Next() {
+		var (
+			ch  = tier.Change{DriverID: driverID}
+			rfm []byte
+		)
+		if err = rows.Scan(&ch.ID, &ch.From, &ch.To, &ch.Score, &rfm, &ch.Period, &ch.ChangedAt); err != nil {
+			return nil, fmt.Errorf("could not scan tier change: %s", err)
+		}
+		if err = json.Unmarshal(rfm, &ch.RFM); err != nil {
+			return nil, fmt.Errorf("could not unmarshal tier rfm: %s", err)
+		}
+		list = append(list, ch)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read tier history: %s", err)
+	}
+	return list, nil
+}
+
+func (c *Client) ListUnpublishedChanges(ctx context.Context, limit int) ([]tier.Change, error) {
+	rows, err := c.db.Query(ctx, `
+		SELECT id, driver_id, from_tier, to_tier, score, rfm, period, changed_at
+		FROM driver_tier_history WHERE published_at IS NULL
+		ORDER BY changed_at, id LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not list unpublished tier changes: %s", err)
+	}
+	defer rows.Close()
+
+	var list []tier.Change
+	for rows.Next() {
+		var (
+			ch  tier.Change
+			rfm []byte
+		)
+		if err = rows.Scan(&ch.ID, &ch.DriverID, &ch.From, &ch.To, &ch.Score, &rfm, &ch.Period, &ch.ChangedAt); err != nil {
+			return nil, fmt.Errorf("could not scan tier change: %s", err)
+		}
+		if err = json.Unmarshal(rfm, &ch.RFM); err != nil {
+			return nil, fmt.Errorf("could not unmarshal tier rfm: %s", err)
+		}
+		list = append(list, ch)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not read unpublished tier changes: %s", err)
+	}
+	return list, nil
+}
+
+func (c *Client) MarkChangePublished(ctx context.Context, id int64, at time.Time) error {
+	_, err := c.db.Exec(ctx, `UPDATE driver_tier_history SET published_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return fmt.Errorf("could not mark tier change %d published: %s", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/achievement"
+)
+
+// dailyEarningsTTL keeps daily earnings until days are closed again.
+const dailyEarningsTTL = 8 * 24 * time.Hour
+
+// UnlockBadge saves the badge by its key only when the driver has not
+// unlocked it.
+func (c *RedisService) UnlockBadge(ctx context.Context, b achievement.Badge) (bool, error) {
+	v, err := json.Marshal(b)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal badge: %v", err)
+	}
+	ok, err := c.Client.HSetNX(ctx, badgesKey(b.DriverID), b.Key(), v).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to unlock badge %s: %v", b.ID, err)
+	}
+	return ok, nil
+}
+
+func (c *RedisService) HasBadge(ctx context.Context, driverID, key string) (bool, error) {
+	ok, err := c.Client.HExists(ctx, badgesKey(driverID), key).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to get badge %s: %v", key, err)
+	}
+	return ok, nil
+}
+
+func (c *RedisService) ListBadges(ctx context.Context, driverID string) ([]achievement.Badge, error) {
+	vals, err := c.Client.HGetAll(ctx, badgesKey(driverID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list badges: %v", err)
+	}
+
+	badges := make([]achievement.Badge, 0, len(vals))
+	for _, v := range vals {
+		var b achievement.Badge
+		if err := json.Unmarshal([]byte(v), &b); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal badge: %v", err)
+		}
+		badges = append(badges, b)
+	}
+	return badges, nil
+}
+
+// GetStreak returns the named streak of the driver, zero without streak.
+func (c *RedisService) GetStreak(ctx context.Context, name, driverID string) (achievement.Streak, error) {
+	var s achievement.Streak
+	val, err := c.Client.Get(ctx, streakKey(name, driverID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return s, nil
+		}
+		return s, fmt.Errorf("failed to get streak: %v", err)
+	}
+	if err := json.Unmarshal([]byte(val), &s); err != nil {
+		return s, fmt.Errorf("failed to unmarshal streak: %v", err)
+	}
+	return s, nil
+}
+
+func (c *RedisService) SetStreak(ctx context.Context, name, driverID string, s achievement.Streak) error {
+	v, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal streak: %v", err)
+	}
+	if err := c.Client.Set(ctx, streakKey(name, driverID), v, 0).Err(); err != nil {
+		return fmt.Errorf("failed to set streak: %v", err)
+	}
+	return nil
+}
+
+func (c *RedisService) AddDailyEarnings(ctx context.Context, zone, day, driverID string, earnings float64) error {
+	key := dailyEarningsKey(zone, day)
+	pipe := c.Client.TxPipeline()
+	pipe.ZIncrBy(ctx, key, earnings, driverID)
+	pipe.Expire(ctx, key, dailyEarningsTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to add daily earnings: %v", err)
+	}
+	return nil
+}
+
+func (c *RedisService) TopDailyEarner(ctx context.Context, zone, day string) (string, bool, error) {
+	ids, err := c.Client.ZRevRange(ctx, dailyEarningsKey(zone, day), 0, 0).Result()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get top daily earner: %v", err)
+	}
+	if len(ids) == 0 {
+		return "", false, nil
+	}
+	return ids[0], true, nil
+}
+
+func badgesKey(driverID string) string {
+	return fmt.Sprintf("driver_badges:%s", driverID)
+}
+
+func streakKey(name, driverID string) string {
+	return fmt.Sprintf("achievement_streak:%s:%s", name, driverID)
+}
+
+func dailyEarningsKey(zone, day string) string {
+	return fmt.Sprintf("daily_earnings:%s:%s", zone, day)
+}
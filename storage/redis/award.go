@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+// awardTTL keeps award claims longer than periods are re-closed.
+const awardTTL = 180 * 24 * time.Hour
+
+// claimScript saves the award when not claimed, with a lease token the claim
+// is rejected with -1 when a later token of the schedule was seen.
+var claimScript = redis.NewScript(`
+if ARGV[3] ~= "0" then
+	local seen = tonumber(redis.call("GET", KEYS[2]) or "0")
+	if tonumber(ARGV[3]) < seen then
+		return -1
+	end
+	redis.call("SET", KEYS[2], ARGV[3])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+// ClaimAward saves the award only when the award key is not claimed, claims
+// of a schedule run with a stale lease return worker.ErrStaleLease.
+func (c *RedisService) ClaimAward(ctx context.Context, r award.Record) (bool, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal award: %v", err)
+	}
+
+	lease, _ := worker.LeaseFromContext(ctx)
+	keys := []string{awardKey(r.Key), fenceSeenKey(lease.Schedule)}
+	n, err := claimScript.Run(ctx, c.Client, keys, b, awardTTL.Milliseconds(), lease.Token).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim award %s: %v", r.Key, err)
+	}
+	if n < 0 {
+		return false, fmt.Errorf("%w: schedule %s token %d", worker.ErrStaleLease, lease.Schedule, lease.Token)
+	}
+	return n == 1, nil
+}
+
+func (c *RedisService) SaveAward(ctx context.Context, r award.Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to marshal award: %v", err)
+	}
+	if err = c.Client.SetArgs(ctx, awardKey(r.Key), b, redis.SetArgs{KeepTTL: true}).Err(); err != nil {
+		return fmt.Errorf("failed to save award %s: %v", r.Key, err)
+	}
+	return nil
+}
+
+func (c *RedisService) ReleaseAward(ctx context.Context, key string) error {
+	if err := c.Client.Del(ctx, awardKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to release award %s: %v", key, err)
+	}
+	return nil
+}
+
+// FreezeStandings saves the drivers of the period scope unless frozen and
+// returns the frozen drivers.
+func (c *RedisService) FreezeStandings(ctx context.Context, period, scope string, driverIDs []string) ([]string, error) {
+	b, err := json.Marshal(driverIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal award standings: %v", err)
+	}
+	key := awardStandingsKey(period, scope)
+	if err = c.Client.SetNX(ctx, key, b, awardTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to freeze award standings: %v", err)
+	}
+	v, err := c.Client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get award standings: %v", err)
+	}
+	var frozen []string
+	if err = json.Unmarshal(v, &frozen); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal award standings: %v", err)
+	}
+	return frozen, nil
+}
+
+// fenceSeenKey is the latest lease token of the schedule that claimed awards.
+func fenceSeenKey(schedule string) string {
+	return fmt.Sprintf("schedule_fence_seen:{%s}", schedule)
+}
+
+func awardStandingsKey(period, scope string) string {
+	return fmt.Sprintf("award_standings:%s:%s", period, scope)
+}
+
+func awardKey(key string) string {
+	return fmt.Sprintf("loyalty_award:%s", key)
+}
@@ -0,0 +1,144 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+// ErrLeaseLost returned when renewing or releasing a lease no longer held.
+var ErrLeaseLost = errors.New("lease lost")
+
+// acquireScript sets the lease if not exists and assigns a fencing token
+// incremented per lease name, returns token and holder value of the lease.
+var acquireScript = redis.NewScript(`
+if redis.call("SET", KEYS[1], "", "NX", "PX", ARGV[2]) then
+	local token = redis.call("INCR", KEYS[2])
+	local val = ARGV[1] .. "|" .. token .. "|" .. ARGV[3]
+	redis.call("SET", KEYS[1], val, "PX", ARGV[2])
+	return {1, val}
+end
+return {0, redis.call("GET", KEYS[1])}
+`)
+
+// renewScript extends the lease ttl only when still held.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Locker acquires schedule leases with fencing tokens.
+type Locker struct {
+	client *Client
+	logger *slog.Logger
+}
+
+func NewLocker(client *Client, logger *slog.Logger) *Locker {
+	return &Locker{client: client, logger: logger}
+}
+
+// Acquire acquires the lease of key, lease keys must be formatted as
+// "{name}:..." hash tags so the fencing counter lives in the same slot.
+func (l *Locker) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (worker.Lease, bool, error) {
+	now := time.Now()
+	keys := []string{key, fenceKey(key)}
+	res, err := acquireScript.Run(ctx, l.client, keys, holder, ttl.Milliseconds(), now.UnixMilli()).Slice()
+	if err != nil {
+		return worker.Lease{}, false, fmt.Errorf("redis: acquire lease %s: %s", key, err)
+	}
+	if len(res) != 2 {
+		return worker.Lease{}, false, fmt.Errorf("redis: acquire lease %s: unexpected reply %v", key, res)
+	}
+
+	val, _ := res[1].(string)
+	lease, err := parseLease(key, val)
+	if err != nil {
+		return worker.Lease{}, false, err
+	}
+	acquired, _ := res[0].(int64)
+	return lease, acquired == 1, nil
+}
+
+// Renew extends the lease ttl.
+func (l *Locker) Renew(ctx context.Context, lease worker.Lease, ttl time.Duration) error {
+	n, err := renewScript.Run(ctx, l.client, []string{lease.Key}, leaseValue(lease), ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("redis: renew lease %s: %s", lease.Key, err)
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Release keeps the lease key until its ttl expires so the tick is not
+// acquired again by other replicas, it only verifies the lease is still held.
+func (l *Locker) Release(ctx context.Context, lease worker.Lease) error {
+	val, err := l.client.Get(ctx, lease.Key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return ErrLeaseLost
+		}
+		return fmt.Errorf("redis: release lease %s: %s", lease.Key, err)
+	}
+	if val != leaseValue(lease) {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Unlock deletes the lease key when still held so the lease can be acquired
+// again before its ttl.
+func (l *Locker) Unlock(ctx context.Context, lease worker.Lease) error {
+	n, err := releaseScript.Run(ctx, l.client, []string{lease.Key}, leaseValue(lease)).Int()
+	if err != nil {
+		return fmt.Errorf("redis: unlock lease %s: %s", lease.Key, err)
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// fenceKey returns fencing counter key of the lease hash tag, ex.
+// "schedule:{close-period}:1714924800" -> "schedule:{close-period}:fence".
+func fenceKey(key string) string {
+	if i := strings.Index(key, "}"); i >= 0 {
+		return key[:i+1] + ":fence"
+	}
+	return key + ":fence"
+}
+
+func leaseValue(lease worker.Lease) string {
+	return fmt.Sprintf("%s|%d|%d", lease.Holder, lease.Token, lease.AcquiredAt.UnixMilli())
+}
+
+func parseLease(key, val string) (worker.Lease, error) {
+	parts := strings.Split(val, "|")
+	if len(parts) != 3 {
+		return worker.Lease{}, fmt.Errorf("redis: invalid lease %s value: %q", key, val)
+	}
+	token, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return worker.Lease{}, fmt.Errorf("redis: invalid lease %s token: %s", key, err)
+	}
+	ms, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return worker.Lease{}, fmt.Errorf("redis: invalid lease %s time: %s", key, err)
+	}
+	return worker.Lease{
+		Key:        key,
+		Holder:     parts[0],
+		Token:      token,
+		AcquiredAt: time.UnixMilli(ms),
+	}, nil
+}
@@ -0,0 +1,103 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/open_loyalty"
+)
+
+const (
+	// loyaltyImportTTL keeps imports long enough to check their status.
+	loyaltyImportTTL = 30 * 24 * time.Hour
+	// maxLoyaltyImports is the number of latest import ids kept.
+	maxLoyaltyImports = 100
+)
+
+// SaveImport stores the member import, new imports are added to the latest
+// imports.
+func (c *RedisService) SaveImport(ctx context.Context, imp open_loyalty.Import) error {
+	b, err := json.Marshal(imp)
+	if err != nil {
+		return fmt.Errorf("redis: marshal loyalty import: %s", err)
+	}
+
+	err = c.Client.SetArgs(ctx, loyaltyImportKey(imp.ID), b, redis.SetArgs{TTL: loyaltyImportTTL, Get: true}).Err()
+	if err == nil {
+		return nil
+	}
+	if err != redis.Nil {
+		return fmt.Errorf("redis: save loyalty import %s: %s", imp.ID, err)
+	}
+
+	pipe := c.Client.TxPipeline()
+	pipe.LPush(ctx, "loyalty_imports", imp.ID)
+	pipe.LTrim(ctx, "loyalty_imports", 0, maxLoyaltyImports-1)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis: save loyalty import %s: %s", imp.ID, err)
+	}
+	return nil
+}
+
+// GetImport returns the member import, ok is false when it is not found or
+// expired.
+func (c *RedisService) GetImport(ctx context.Context, id string) (open_loyalty.Import, bool, error) {
+	b, err := c.Client.Get(ctx, loyaltyImportKey(id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return open_loyalty.Import{}, false, nil
+		}
+		return open_loyalty.Import{}, false, fmt.Errorf("redis: get loyalty import %s: %s", id, err)
+	}
+
+	var imp open_loyalty.Import
+	if err = json.Unmarshal(b, &imp); err != nil {
+		return open_loyalty.Import{}, false, fmt.Errorf("redis: unmarshal loyalty import %s: %s", id, err)
+	}
+	return imp, true, nil
+}
+
+// ListImports returns the latest member imports, latest first.
+func (c *RedisService) ListImports(ctx context.Context, limit int) ([]open_loyalty.Import, error) {
+	if limit <= 0 || limit > maxLoyaltyImports {
+		limit = maxLoyaltyImports
+	}
+	ids, err := c.Client.LRange(ctx, "loyalty_imports", 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis: list loyalty imports: %s", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, loyaltyImportKey(id))
+	}
+	vals, err := c.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis: get loyalty imports: %s", err)
+	}
+
+	imports := make([]open_loyalty.Import, 0, len(vals))
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var imp open_loyalty.Import
+		if err = json.Unmarshal([]byte(s), &imp); err != nil {
+			c.logger.Error("invalid loyalty import", "import_id", ids[i], "err", err)
+			continue
+		}
+		imports = append(imports, imp)
+	}
+	return imports, nil
+}
+
+func loyaltyImportKey(id string) string {
+	return fmt.Sprintf("loyalty_import:%s", id)
+}
@@ -0,0 +1,65 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/worker"
+)
+
+// maxScheduleRuns is the number of latest runs kept per schedule.
+const maxScheduleRuns = 100
+
+// ScheduleRuns stores schedule run history in a capped list per schedule.
+type ScheduleRuns struct {
+	client *Client
+	logger *slog.Logger
+}
+
+func NewScheduleRuns(client *Client, logger *slog.Logger) *ScheduleRuns {
+	return &ScheduleRuns{client: client, logger: logger}
+}
+
+func (r *ScheduleRuns) RecordRun(ctx context.Context, run worker.ScheduleRun) error {
+	b, err := json.Marshal(run)
+	if err != nil {
+		return fmt.Errorf("redis: marshal schedule run: %s", err)
+	}
+
+	key := scheduleRunsKey(run.Name)
+	pipe := r.client.TxPipeline()
+	pipe.LPush(ctx, key, b)
+	pipe.LTrim(ctx, key, 0, maxScheduleRuns-1)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("redis: record schedule run %s: %s", run.Name, err)
+	}
+	return nil
+}
+
+// ListRuns returns latest runs of the schedule, latest first.
+func (r *ScheduleRuns) ListRuns(ctx context.Context, name string, limit int) ([]worker.ScheduleRun, error) {
+	if limit <= 0 || limit > maxScheduleRuns {
+		limit = maxScheduleRuns
+	}
+	vals, err := r.client.LRange(ctx, scheduleRunsKey(name), 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis: list schedule runs %s: %s", name, err)
+	}
+
+	runs := make([]worker.ScheduleRun, 0, len(vals))
+	for _, v := range vals {
+		var run worker.ScheduleRun
+		if err = json.Unmarshal([]byte(v), &run); err != nil {
+			r.logger.Error("invalid schedule run", "schedule", name, "err", err)
+			continue
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+func scheduleRunsKey(name string) string {
+	return fmt.Sprintf("schedule_runs:%s", name)
+}
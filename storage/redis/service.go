@@ -9,8 +9,20 @@ import (
 
 	"github.com/redis/go-redis/v9"
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/leaderboard"
 )
 
+// refreshTokenTTL is the Open Loyalty refresh token lifetime.
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// releaseScript deletes the key only when the value matches.
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
 type RedisService struct {
 	Client *Client
 	logger *slog.Logger
@@ -39,25 +51,13 @@ func (c *RedisService) GetJWTToken(ctx context.Context) (string, error) {
 	return val, nil
 }
 
-func (c *RedisService) GetJWTExpiry(ctx context.Context) (time.Duration, error) {
-	ttl, err := c.Client.TTL(ctx, "jwt_token").Result()
-
-	if err != nil {
-		if err == redis.Nil {
-			return 0, nil
-		}
-		return 0, err
-	}
-
-	return ttl, nil
-}
-
+// GetRefreshToken returns empty token when not found.
 func (c *RedisService) GetRefreshToken(ctx context.Context) (string, error) {
 	token, err := c.Client.Get(ctx, "refresh_token").Result()
 
 	if err != nil {
 		if err == redis.Nil {
-			return "", fmt.Errorf("refresh token not found")
+			return "", nil
 		}
 		return "", err
 	}
@@ -65,18 +65,41 @@ func (c *RedisService) GetRefreshToken(ctx context.Context) (string, error) {
 	return token, nil
 }
 
-func (c *RedisService) SetAuthenticationTokens(ctx context.Context, JWTToken string, refreshToken string) error {
-	_, err := c.Client.Set(ctx, "jwt_token", JWTToken, time.Hour*23).Result()
-	if err != nil {
+// SetAuthenticationTokens stores the JWT token until it expires and the
+// refresh token for refreshTokenTTL.
+func (c *RedisService) SetAuthenticationTokens(ctx context.Context, JWTToken string, refreshToken string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return fmt.Errorf("jwt token expired at %s", expiresAt)
+	}
+
+	pipe := c.Client.TxPipeline()
+	pipe.Set(ctx, "jwt_token", JWTToken, ttl)
+	pipe.Set(ctx, "refresh_token", refreshToken, refreshTokenTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
 		return err
 	}
-	_, err = c.Client.Set(ctx, "refresh_token", refreshToken, time.Hour*23).Result()
+
+	return nil
+}
+
+// AcquireTokenLock locks refreshing tokens to the holder until ttl.
+func (c *RedisService) AcquireTokenLock(ctx context.Context, holder string, ttl time.Duration) (bool, error) {
+	ok, err := c.Client.SetNX(ctx, "jwt_token_lock", holder, ttl).Result()
 	if err != nil {
-		return err
+		return false, fmt.Errorf("failed to acquire token lock: %v", err)
 	}
+	return ok, nil
+}
 
+// ReleaseTokenLock removes the lock only when held by the holder.
+func (c *RedisService) ReleaseTokenLock(ctx context.Context, holder string) error {
+	if err := releaseScript.Run(ctx, c.Client, []string{"jwt_token_lock"}, holder).Err(); err != nil {
+		return fmt.Errorf("failed to release token lock: %v", err)
+	}
 	return nil
 }
+
 func (c *RedisService) CheckHighestNetEarnings(ctx context.Context, netEarnings float64, serviceZone string) (float64, error) {
 	key := fmt.Sprintf("highest_net_earnings:%s", serviceZone)
 	expiration := time.Hour * 26
@@ -105,6 +128,19 @@ func (c *RedisService) CheckHighestNetEarnings(ctx context.Context, netEarnings
 	return cachedNetEarnings, nil
 }
 
+func (c *RedisService) GetHighestNetEarnings(ctx context.Context, serviceZone string) (float64, error) {
+	key := fmt.Sprintf("highest_net_earnings:%s", serviceZone)
+	val, err := c.Client.Get(ctx, key).Float64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return val, nil
+}
+
 func (c *RedisService) SetDriverRating(ctx context.Context, driver driver.Driver) (err error) {
 	driverKey := fmt.Sprintf("driver:%s", driver.DriverID)
 
@@ -162,6 +198,22 @@ func (c *RedisService) GetActiveLeaderboard(ctx context.Context, scope string) (
 	return &drivers, nil
 }
 
+func (c *RedisService) GetLeaderboardScores(ctx context.Context, scope string, offset, count int64) ([]leaderboard.Score, error) {
+	key := fmt.Sprintf("driver_leaderboard:%s", scope)
+
+	list, err := c.Client.ZRevRangeWithScores(ctx, key, offset, offset+count-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leaderboard scores: %v", err)
+	}
+
+	scores := make([]leaderboard.Score, 0, len(list))
+	for _, z := range list {
+		id, _ := z.Member.(string)
+		scores = append(scores, leaderboard.Score{DriverID: id, Score: z.Score})
+	}
+	return scores, nil
+}
+
 func (c *RedisService) GetPreviousLeaderboard(ctx context.Context) (string, error) {
 	val, err := c.Client.Get(ctx, "previous_top_bikers").Result()
 
@@ -199,3 +251,286 @@ func (c *RedisService) RefreshLeaderboard(ctx context.Context, driver driver.Dri
 
 	return nil
 }
+
+// contributionTTL keeps trip contributions long enough for late refunds and
+// disputes.
+const contributionTTL = 90 * 24 * time.Hour
+
+func (c *RedisService) GetTripContribution(ctx context.Context, tripRequestID string) (driver.Contribution, bool, error) {
+	key := fmt.Sprintf("trip_contribution:%s", tripRequestID)
+	val, err := c.Client.Get(ctx, key).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return driver.Contribution{}, false, nil
+		}
+		return driver.Contribution{}, false, fmt.Errorf("failed to get trip contribution: %v", err)
+	}
+
+	var contribution driver.Contribution
+	if err := json.Unmarshal([]byte(val), &contribution); err != nil {
+		return driver.Contribution{}, false, fmt.Errorf("failed to unmarshal trip contribution: %v", err)
+	}
+	return contribution, true, nil
+}
+
+func (c *RedisService) SetTripContribution(ctx context.Context, contribution driver.Contribution) error {
+	key := fmt.Sprintf("trip_contribution:%s", contribution.TripRequestID)
+	b, err := json.Marshal(contribution)
+	if err != nil {
+		return fmt.Errorf("failed to marshal trip contribution: %v", err)
+	}
+	if err := c.Client.Set(ctx, key, b, contributionTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set trip contribution in Redis: %v", err)
+	}
+	return nil
+}
+
+// scoreScript saves the trip contribution with the driver rating and its
+// leaderboard score at once, with ARGV[1] "nx" nothing is saved when the trip
+// already has a contribution.
+var scoreScript = redis.NewScript(`
+if ARGV[1] == "nx" and redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+redis.call("SET", KEYS[2], ARGV[4])
+redis.call("ZADD", KEYS[3], ARGV[5], ARGV[6])
+return 1
+`)
+
+// SaveTripScore saves the driver rating, leaderboard score and the trip
+// contribution atomically, returns false without replace when the trip
+// contribution is already saved.
+func (c *RedisService) SaveTripScore(ctx context.Context, d driver.Driver, contribution driver.Contribution, replace bool) (bool, error) {
+	cb, err := json.Marshal(contribution)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal trip contribution: %v", err)
+	}
+	db, err := json.Marshal(d)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal driver data: %v", err)
+	}
+
+	mode := "nx"
+	if replace {
+		mode = ""
+	}
+	keys := []string{
+		fmt.Sprintf("trip_contribution:%s", contribution.TripRequestID),
+		fmt.Sprintf("driver:%s", d.DriverID),
+		fmt.Sprintf("driver_leaderboard:%s", d.ServiceZone),
+	}
+	n, err := scoreScript.Run(ctx, c.Client, keys, mode, cb, contributionTTL.Milliseconds(), db, d.Rating.Average, d.DriverID).Int()
+	if err != nil {
+		return false, fmt.Errorf("failed to save trip score: %v", err)
+	}
+	return n == 1, nil
+}
+
+func (c *RedisService) IsPenaltyApplied(ctx context.Context, id string) (bool, error) {
+	n, err := c.Client.Exists(ctx, fmt.Sprintf("penalty_applied:%s", id)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check applied penalty: %v", err)
+	}
+	return n > 0, nil
+}
+
+func (c *RedisService) SetPenaltyApplied(ctx context.Context, id string, ttl time.Duration) error {
+	if err := c.Client.Set(ctx, fmt.Sprintf("penalty_applied:%s", id), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set applied penalty: %v", err)
+	}
+	return nil
+}
+
+func (c *RedisService) GetDriverProfile(ctx context.Context, driverID string) (driver.Profile, bool, error) {
+	val, err := c.Client.Get(ctx, fmt.Sprintf("driver_profile:%s", driverID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return driver.Profile{}, false, nil
+		}
+		return driver.Profile{}, false, fmt.Errorf("failed to get driver profile: %v", err)
+	}
+
+	var p driver.Profile
+	if err := json.Unmarshal([]byte(val), &p); err != nil {
+		return driver.Profile{}, false, fmt.Errorf("failed to unmarshal driver profile: %v", err)
+	}
+	return p, true, nil
+}
+
+func (c *RedisService) SetDriverProfile(ctx context.Context, p driver.Profile) error {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to marshal driver profile: %v", err)
+	}
+	if err := c.Client.Set(ctx, fmt.Sprintf("driver_profile:%s", p.DriverID), b, 0).Err(); err != nil {
+		return fmt.Errorf("failed to set driver profile in Redis: %v", err)
+	}
+	return nil
+}
+
+// GetDriverProfiles returns profiles by driver id, drivers without profile
+// are omitted.
+func (c *RedisService) GetDriverProfiles(ctx context.Context, driverIDs []string) (map[string]driver.Profile, error) {
+	profiles := map[string]driver.Profile{}
+	if len(driverIDs) == 0 {
+		return profiles, nil
+	}
+
+	keys := make([]string, len(driverIDs))
+	for i, id := range driverIDs {
+		keys[i] = fmt.Sprintf("driver_profile:%s", id)
+	}
+	vals, err := c.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get driver profiles: %v", err)
+	}
+
+	for _, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var p driver.Profile
+		if err := json.Unmarshal([]byte(s), &p); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal driver profile: %v", err)
+		}
+		profiles[p.DriverID] = p
+	}
+	return profiles, nil
+}
+
+func (c *RedisService) GetLoyalty(ctx context.Context, driverID string) (driver.Loyalty, bool, error) {
+	val, err := c.Client.Get(ctx, fmt.Sprintf("loyalty_member:%s", driverID)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return driver.Loyalty{}, false, nil
+		}
+		return driver.Loyalty{}, false, fmt.Errorf("failed to get loyalty: %v", err)
+	}
+
+	var l driver.Loyalty
+	if err := json.Unmarshal([]byte(val), &l); err != nil {
+		return driver.Loyalty{}, false, fmt.Errorf("failed to unmarshal loyalty: %v", err)
+	}
+	return l, true, nil
+}
+
+func (c *RedisService) SetLoyalty(ctx context.Context, driverID string, l driver.Loyalty, ttl time.Duration) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loyalty: %v", err)
+	}
+	if err := c.Client.Set(ctx, fmt.Sprintf("loyalty_member:%s", driverID), b, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to set loyalty in Redis: %v", err)
+	}
+	return nil
+}
+
+// ClaimLoyaltyEvent marks the loyalty event as applied, ok is false when it
+// was already claimed.
+func (c *RedisService) ClaimLoyaltyEvent(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	ok, err := c.Client.SetNX(ctx, fmt.Sprintf("loyalty_event:%s", id), time.Now().Unix(), ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim loyalty event %s: %v", id, err)
+	}
+	return ok, nil
+}
+
+func (c *RedisService) ReleaseLoyaltyEvent(ctx context.Context, id string) error {
+	if err := c.Client.Del(ctx, fmt.Sprintf("loyalty_event:%s", id)).Err(); err != nil {
+		return fmt.Errorf("failed to release loyalty event %s: %v", id, err)
+	}
+	return nil
+}
+
+// addLoyaltyEventScript saves the event by id and keeps the latest ARGV[4]
+// events of the driver ordered by occurrence, adding an event again replaces
+// it.
+var addLoyaltyEventScript = redis.NewScript(`
+redis.call("HSET", KEYS[2], ARGV[1], ARGV[2])
+redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+local n = redis.call("ZCARD", KEYS[1]) - tonumber(ARGV[4])
+if n > 0 then
+	local old = redis.call("ZRANGE", KEYS[1], 0, n - 1)
+	redis.call("ZREMRANGEBYRANK", KEYS[1], 0, n - 1)
+	redis.call("HDEL", KEYS[2], unpack(old))
+end
+return 1
+`)
+
+// AddLoyaltyEvent adds the event to the latest max events of the driver,
+// events are kept once by id.
+func (c *RedisService) AddLoyaltyEvent(ctx context.Context, e driver.LoyaltyEvent, max int) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal loyalty event: %v", err)
+	}
+	keys := []string{loyaltyEventIDsKey(e.DriverID), loyaltyEventsKey(e.DriverID)}
+	if err = addLoyaltyEventScript.Run(ctx, c.Client, keys, e.ID, b, e.OccurredAt.UnixMilli(), max).Err(); err != nil {
+		return fmt.Errorf("failed to add loyalty event %s: %v", e.ID, err)
+	}
+	return nil
+}
+
+// ListLoyaltyEvents returns the latest events of the driver, newest first.
+func (c *RedisService) ListLoyaltyEvents(ctx context.Context, driverID string, limit int) ([]driver.LoyaltyEvent, error) {
+	ids, err := c.Client.ZRevRange(ctx, loyaltyEventIDsKey(driverID), 0, int64(limit)-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loyalty events: %v", err)
+	}
+	if len(ids) == 0 {
+		return []driver.LoyaltyEvent{}, nil
+	}
+	vals, err := c.Client.HMGet(ctx, loyaltyEventsKey(driverID), ids...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loyalty events: %v", err)
+	}
+
+	events := make([]driver.LoyaltyEvent, 0, len(vals))
+	for _, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		var e driver.LoyaltyEvent
+		if err := json.Unmarshal([]byte(s), &e); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal loyalty event: %v", err)
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+func loyaltyEventIDsKey(driverID string) string {
+	return fmt.Sprintf("loyalty_event_ids:%s", driverID)
+}
+
+func loyaltyEventsKey(driverID string) string {
+	return fmt.Sprintf("loyalty_event_data:%s", driverID)
+}
+
+// ListDrivers returns all drivers with ratings.
+func (c *RedisService) ListDrivers(ctx context.Context) ([]driver.Driver, error) {
+	var drivers []driver.Driver
+	iter := c.Client.Scan(ctx, 0, "driver:*", 500).Iterator()
+	for iter.Next(ctx) {
+		val, err := c.Client.Get(ctx, iter.Val()).Result()
+		if err != nil {
+			if err == redis.Nil {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get driver data from Redis: %v", err)
+		}
+
+		var d driver.Driver
+		if err := json.Unmarshal([]byte(val), &d); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal driver data: %v", err)
+		}
+		drivers = append(drivers, d)
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan drivers: %v", err)
+	}
+	return drivers, nil
+}
@@ -0,0 +1,94 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// capTripScript counts the trip once in the trips and earnings of the cap
+// hash and keeps the earnings counted under the trip field, -1 when over the
+// trip cap. A trip counted already returns its counted earnings, so retries
+// are not counted again. Expiry is only set on caps without expiry, so the
+// reset is not pushed back by later trips.
+var capTripScript = redis.NewScript(`
+local field = "trip:" .. ARGV[1]
+if ARGV[1] ~= "" then
+	local counted = redis.call("HGET", KEYS[1], field)
+	if counted then
+		return counted
+	end
+end
+local counted = tonumber(ARGV[2])
+local maxTrips, maxEarnings = tonumber(ARGV[3]), tonumber(ARGV[4])
+if maxTrips > 0 and tonumber(redis.call("HGET", KEYS[1], "trips") or "0") >= maxTrips then
+	counted = -1
+else
+	if maxEarnings > 0 then
+		local earned = tonumber(redis.call("HGET", KEYS[1], "earnings") or "0")
+		counted = math.max(math.min(counted, maxEarnings - earned), 0)
+	end
+	redis.call("HINCRBY", KEYS[1], "trips", 1)
+	redis.call("HINCRBYFLOAT", KEYS[1], "earnings", counted)
+end
+if ARGV[1] ~= "" then
+	redis.call("HSET", KEYS[1], field, tostring(counted))
+end
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[5])
+end
+return tostring(counted)
+`)
+
+// releaseTripScript gives back the trip and earnings counted for the trip.
+var releaseTripScript = redis.NewScript(`
+local field = "trip:" .. ARGV[1]
+local counted = redis.call("HGET", KEYS[1], field)
+if not counted then
+	return 0
+end
+redis.call("HDEL", KEYS[1], field)
+if tonumber(counted) >= 0 then
+	redis.call("HINCRBY", KEYS[1], "trips", -1)
+	redis.call("HINCRBYFLOAT", KEYS[1], "earnings", -tonumber(counted))
+end
+return 1
+`)
+
+// CapSource counts the trips and earnings of capped days once per trip.
+type CapSource struct {
+	client *Client
+	logger *slog.Logger
+}
+
+func NewCapSource(client *Client, logger *slog.Logger) *CapSource {
+	return &CapSource{client: client, logger: logger}
+}
+
+func (s *CapSource) Count(ctx context.Context, key, tripID string, earnings float64, maxTrips int, maxEarnings float64, expr time.Duration) (float64, bool, error) {
+	// Counted earnings are replied as a bulk string.
+	v, err := capTripScript.Run(ctx, s.client, []string{key},
+		tripID, earnings, maxTrips, maxEarnings, expr.Milliseconds()).Text()
+	if err != nil {
+		return 0, false, fmt.Errorf("redis: count trip cap %s: %s", key, err)
+	}
+	counted, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("redis: invalid trip cap %s: %s", key, err)
+	}
+	if counted < 0 {
+		return 0, false, nil
+	}
+	return counted, true, nil
+}
+
+func (s *CapSource) Release(ctx context.Context, key, tripID string) error {
+	if err := releaseTripScript.Run(ctx, s.client, []string{key}, tripID).Err(); err != nil {
+		return fmt.Errorf("redis: release trip cap %s: %s", key, err)
+	}
+	return nil
+}
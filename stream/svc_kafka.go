@@ -11,7 +11,14 @@ import (
 )
 
 var (
-	TripTopic = "trips"
+	TripTopic               = "trips"
+	DriverCancellationTopic = "driver_cancellations"
+	DriverComplaintTopic    = "driver_complaints"
+	DriverProfileTopic      = "driver_profiles"
+	RewardPayoutTopic       = "reward_payouts"
+	BudgetAlertTopic        = "budget_alerts"
+	TierChangeTopic         = "driver_tier_changes"
+	BadgeTopic              = "driver_badges"
 )
 
 func NewKafkaService(l *slog.Logger, producer kafka.Writer) *KafkaService {
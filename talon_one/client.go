@@ -0,0 +1,176 @@
+package talon_one
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/httpretry"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+const (
+	// Integration API Endpoints
+	customerProfilesEndpoint = "/v2/customer_profiles"
+	eventsEndpoint           = "/v2/events"
+)
+
+// APIError represents a non 2xx response from Talon.One.
+type APIError struct {
+	Endpoint   string
+	StatusCode int
+	Message    string `json:"message"`
+	// Body is the raw response body.
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Body
+	}
+	return fmt.Sprintf("talon one %s: %d %s: %s", e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode), msg)
+}
+
+// Response returns the status code and Retry-After of the response.
+func (e *APIError) Response() (int, time.Duration) {
+	return e.StatusCode, e.RetryAfter
+}
+
+// Creates a new instance of the Talon.One API client with the provided configuration.
+func NewTalonOneClient(config *Config, l *slog.Logger) *TalonOneClient {
+	client := &TalonOneClient{
+		Client: &http.Client{
+			Timeout: 30 * time.Second, // default
+			Transport: otelhttp.NewTransport(http.DefaultTransport,
+				otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+					return fmt.Sprintf("talon_one %s %s", r.Method, r.URL.Path)
+				}),
+			),
+		},
+		MaxRetries:      httpretry.DefaultMaxRetries,
+		RetryBackoff:    httpretry.DefaultBackoff,
+		RetryMaxBackoff: httpretry.DefaultMaxBackoff,
+
+		BaseURL: config.URL,
+		APIKey:  config.APIKey,
+		Logger:  l,
+	}
+
+	if config.ClientTimeout != nil {
+		client.Client.Timeout = *config.ClientTimeout
+	}
+	if config.MaxRetries != nil {
+		client.MaxRetries = *config.MaxRetries
+	}
+	if config.RetryBackoff > 0 {
+		client.RetryBackoff = config.RetryBackoff
+	}
+	if config.RetryMaxBackoff > 0 {
+		client.RetryMaxBackoff = config.RetryMaxBackoff
+	}
+	threshold, cooldown := httpretry.DefaultBreakerThreshold, httpretry.DefaultBreakerCooldown
+	if config.BreakerThreshold > 0 {
+		threshold = config.BreakerThreshold
+	}
+	if config.BreakerCooldown > 0 {
+		cooldown = config.BreakerCooldown
+	}
+	client.breaker = httpretry.NewBreaker(threshold, cooldown)
+
+	return client
+}
+
+type (
+	customerProfile struct {
+		IntegrationID string         `json:"integrationId"`
+		Attributes    map[string]any `json:"attributes"`
+	}
+
+	updateCustomerProfilesRequest struct {
+		CustomerProfiles []customerProfile `json:"customerProfiles"`
+	}
+
+	eventRequest struct {
+		ProfileID  string         `json:"profileId"`
+		Type       string         `json:"type"`
+		Attributes map[string]any `json:"attributes"`
+	}
+)
+
+// Updates attributes of customer profiles, profiles are created when not
+// found.
+func (c *TalonOneClient) UpdateCustomerProfiles(ctx context.Context, profiles []customerProfile) error {
+	q := url.Values{"runRuleEngine": {"false"}}
+	return c.do(ctx, http.MethodPut, customerProfilesEndpoint+"?"+q.Encode(), updateCustomerProfilesRequest{CustomerProfiles: profiles})
+}
+
+// Tracks a custom event of a customer profile.
+func (c *TalonOneClient) TrackEvent(ctx context.Context, event eventRequest) error {
+	return c.do(ctx, http.MethodPost, eventsEndpoint, event)
+}
+
+// do sends the JSON payload, transport errors, 429 and 5xx responses are
+// retried with backoff. Events carry an idempotency key attribute so retried
+// events are safe to repeat.
+func (c *TalonOneClient) do(ctx context.Context, method, endpoint string, payload any) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	p := httpretry.Policy{
+		MaxRetries: c.MaxRetries,
+		Backoff:    c.RetryBackoff,
+		MaxBackoff: c.RetryMaxBackoff,
+		Idempotent: true,
+		OnRetry: func(attempt int, wait time.Duration, err error) {
+			c.Logger.WarnContext(ctx, "retrying talon one request",
+				"endpoint", endpoint, "attempt", attempt, "wait", wait, "error", err)
+		},
+	}
+	return httpretry.Do(ctx, p, c.breaker, func() error {
+		req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+endpoint, bytes.NewReader(jsonData))
+		if err != nil {
+			return fmt.Errorf("%w: %w", httpretry.ErrNotSent, err)
+		}
+		req.Header.Set("Authorization", fmt.Sprintf("%s %s", "ApiKey-v1", c.APIKey))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		return c.send(ctx, req)
+	})
+}
+
+func (c *TalonOneClient) send(ctx context.Context, req *http.Request) error {
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	rawBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		c.Logger.ErrorContext(ctx, "error reading response body", "error", err)
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{}
+		_ = json.Unmarshal(rawBody, apiErr)
+		apiErr.Endpoint = req.URL.Path
+		apiErr.StatusCode = resp.StatusCode
+		apiErr.Body = string(rawBody)
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+			apiErr.RetryAfter = time.Duration(s) * time.Second
+		}
+		return apiErr
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package talon_one
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/httpretry"
+)
+
+func TestTalonOneClient_retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		codes     []int
+		wantErr   bool
+		wantCalls int
+	}{
+		{"rate limited then tracked", []int{429, 201}, false, 2},
+		{"server error then tracked", []int{503, 201}, false, 2},
+		{"rejected not retried", []int{400, 201}, true, 1},
+		{"retries run out", []int{503, 503, 503}, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				code := tt.codes[min(calls, len(tt.codes)-1)]
+				calls++
+				if code == http.StatusTooManyRequests {
+					w.Header().Set("Retry-After", "1")
+				}
+				w.WriteHeader(code)
+			}))
+			defer srv.Close()
+
+			maxRetries := 2
+			c := NewTalonOneClient(&Config{
+				URL:             srv.URL,
+				MaxRetries:      &maxRetries,
+				RetryBackoff:    time.Millisecond,
+				RetryMaxBackoff: time.Millisecond,
+			}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			err := c.TrackEvent(context.Background(), eventRequest{ProfileID: "driver-1", Type: leaderboardEvent})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TrackEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestTalonOneClient_breaker(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	maxRetries := 0
+	c := NewTalonOneClient(&Config{URL: srv.URL, MaxRetries: &maxRetries, BreakerThreshold: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for i := 0; i < 2; i++ {
+		if err := c.TrackEvent(context.Background(), eventRequest{ProfileID: "driver-1"}); err == nil {
+			t.Fatalf("TrackEvent() #%d error = nil", i)
+		}
+	}
+	if err := c.TrackEvent(context.Background(), eventRequest{ProfileID: "driver-1"}); !errors.Is(err, httpretry.ErrCircuitOpen) {
+		t.Fatalf("TrackEvent() error = %v, want %v", err, httpretry.ErrCircuitOpen)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 while open", calls)
+	}
+}
@@ -0,0 +1,147 @@
+package talon_one
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+const (
+	// Customer profile attributes and custom event of the leaderboard result,
+	// these are created in the Talon.One application.
+	pointsAttribute  = "leaderboard_points"
+	rankAttribute    = "leaderboard_rank"
+	leaderboardEvent = "leaderboard_result"
+	awardEvent       = "leaderboard_award"
+	// idempotencyAttribute is unique per event of a driver and period, the
+	// campaign rules skip events with a key already seen.
+	idempotencyAttribute = "idempotency_key"
+	periodAttribute      = "period"
+	maxProfilesUpdate    = 1000
+	// eventWorkers limits concurrent event requests.
+	eventWorkers = 8
+)
+
+type TalonOneService struct {
+	Client *TalonOneClient
+	logger *slog.Logger
+}
+
+// Creates a new instance of the Talon.One Service client with the provided configuration.
+func NewProviderService(client TalonOneClient, logger *slog.Logger) *TalonOneService {
+	return &TalonOneService{
+		Client: &client,
+		logger: logger,
+	}
+}
+
+// ImportDriverRating sets the rating average as points and the list order as
+// rank of the driver customer profiles, then tracks a leaderboard result event
+// per driver for the campaign rules.
+func (s *TalonOneService) ImportDriverRating(ctx context.Context, list []driver.Driver) (err error) {
+	if len(list) == 0 {
+		return nil
+	}
+
+	for start := 0; start < len(list); start += maxProfilesUpdate {
+		end := min(start+maxProfilesUpdate, len(list))
+		profiles := make([]customerProfile, 0, end-start)
+		for i, d := range list[start:end] {
+			profiles = append(profiles, customerProfile{
+				IntegrationID: d.DriverID,
+				Attributes:    resultAttributes(d, start+i+1),
+			})
+		}
+		if err = s.Client.UpdateCustomerProfiles(ctx, profiles); err != nil {
+			return fmt.Errorf("failed to update customer profiles: %w", err)
+		}
+	}
+
+	if err = s.trackResults(ctx, list); err != nil {
+		return err
+	}
+
+	s.logger.InfoContext(ctx, "customer profiles updated", "profiles", len(list))
+	return nil
+}
+
+// trackResults tracks the leaderboard result event of the drivers in the
+// current period, retried and repeated imports of the period have the same
+// idempotency key.
+func (s *TalonOneService) trackResults(ctx context.Context, list []driver.Driver) error {
+	period := award.Period(time.Now())
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, eventWorkers)
+	for i, d := range list {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			attrs := resultAttributes(d, i+1)
+			attrs[periodAttribute] = period
+			attrs[idempotencyAttribute] = fmt.Sprintf("%s:%s:%s", leaderboardEvent, period, d.DriverID)
+			err := s.Client.TrackEvent(ctx, eventRequest{
+				ProfileID:  d.DriverID,
+				Type:       leaderboardEvent,
+				Attributes: attrs,
+			})
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("failed to track event of %s: %w", d.DriverID, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// AwardPoints tracks a leaderboard award event of the driver, the campaign
+// rules of the event adds the points to the driver loyalty profile.
+func (s *TalonOneService) AwardPoints(ctx context.Context, a driver.Award) error {
+	err := s.Client.TrackEvent(ctx, eventRequest{
+		ProfileID: a.DriverID,
+		Type:      awardEvent,
+		Attributes: map[string]any{
+			"award_key":          a.Key,
+			idempotencyAttribute: a.Key,
+			periodAttribute:      a.Period,
+			rankAttribute:        a.Rank,
+			pointsAttribute:      a.Points,
+		},
+	})
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError {
+			return fmt.Errorf("failed to award %s: %w: %w", a.Key, driver.ErrAwardRejected, err)
+		}
+		return fmt.Errorf("failed to award %s: %w", a.Key, err)
+	}
+
+	s.logger.InfoContext(ctx, "points awarded", "award_key", a.Key, "driver_id", a.DriverID, "points", a.Points)
+	return nil
+}
+
+// GetLoyalty is not supported, loyalty balances are read with the
+// management API which uses a separate key.
+func (s *TalonOneService) GetLoyalty(ctx context.Context, driverID string) (driver.Loyalty, error) {
+	return driver.Loyalty{}, driver.ErrLoyaltyUnsupported
+}
+
+func resultAttributes(d driver.Driver, rank int) map[string]any {
+	return map[string]any{
+		pointsAttribute: d.Rating.Average,
+		rankAttribute:   rank,
+	}
+}
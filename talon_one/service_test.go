@@ -0,0 +1,114 @@
+package talon_one
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/award"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+func TestTalonOneService_ImportDriverRating(t *testing.T) {
+	list := []driver.Driver{
+		{DriverID: "driver-1", Rating: driver.Rating{Average: 4.5}},
+		{DriverID: "driver-2", Rating: driver.Rating{Average: 3}},
+	}
+
+	tests := []struct {
+		name       string
+		list       []driver.Driver
+		eventCode  int
+		wantErr    bool
+		wantUpdate []customerProfile
+		wantEvents []string
+	}{
+		{
+			"updates profiles and tracks results",
+			list,
+			http.StatusCreated,
+			false,
+			[]customerProfile{
+				{IntegrationID: "driver-1", Attributes: map[string]any{pointsAttribute: 4.5, rankAttribute: 1.0}},
+				{IntegrationID: "driver-2", Attributes: map[string]any{pointsAttribute: 3.0, rankAttribute: 2.0}},
+			},
+			[]string{"driver-1", "driver-2"},
+		},
+		{
+			"event rejected",
+			list[:1],
+			http.StatusBadRequest,
+			true,
+			[]customerProfile{
+				{IntegrationID: "driver-1", Attributes: map[string]any{pointsAttribute: 4.5, rankAttribute: 1.0}},
+			},
+			[]string{"driver-1"},
+		},
+		{"empty list", nil, http.StatusCreated, false, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu     sync.Mutex
+				update []customerProfile
+				events []string
+			)
+			mux := http.NewServeMux()
+			mux.HandleFunc("PUT "+customerProfilesEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "ApiKey-v1 key" {
+					t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+				}
+				var req updateCustomerProfilesRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				update = append(update, req.CustomerProfiles...)
+			})
+			mux.HandleFunc("POST "+eventsEndpoint, func(w http.ResponseWriter, r *http.Request) {
+				var req eventRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				if req.Type != leaderboardEvent {
+					t.Errorf("event type = %s, want %s", req.Type, leaderboardEvent)
+				}
+				period := award.Period(time.Now())
+				if key := fmt.Sprintf("%s:%s:%s", leaderboardEvent, period, req.ProfileID); req.Attributes[idempotencyAttribute] != key {
+					t.Errorf("idempotency key = %v, want %s", req.Attributes[idempotencyAttribute], key)
+				}
+				mu.Lock()
+				events = append(events, req.ProfileID)
+				mu.Unlock()
+				w.WriteHeader(tt.eventCode)
+				io.WriteString(w, `{"message":"unknown event type"}`)
+			})
+			srv := httptest.NewServer(mux)
+			defer srv.Close()
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			s := NewProviderService(*NewTalonOneClient(&Config{URL: srv.URL, APIKey: "key"}, logger), logger)
+
+			err := s.ImportDriverRating(context.Background(), tt.list)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ImportDriverRating() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var apiErr *APIError
+			if tt.wantErr && (!errors.As(err, &apiErr) || apiErr.Message != "unknown event type") {
+				t.Errorf("error = %v, want api error", err)
+			}
+			if !reflect.DeepEqual(update, tt.wantUpdate) {
+				t.Errorf("updated profiles = %+v, want %+v", update, tt.wantUpdate)
+			}
+			sort.Strings(events)
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("events = %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package talon_one
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/httpretry"
+)
+
+type (
+	// TalonOneClient is a struct that represents the Talon.One Integration
+	// API client.
+	TalonOneClient struct {
+		Client  *http.Client
+		Logger  *slog.Logger
+		BaseURL string
+		APIKey  string
+		// MaxRetries of 429, 5xx and transport errors after the first attempt.
+		MaxRetries      int
+		RetryBackoff    time.Duration
+		RetryMaxBackoff time.Duration
+
+		breaker *httpretry.Breaker
+	}
+	// Config holds the configuration values for the Talon.One API client.
+	Config struct {
+		URL           string
+		APIKey        string
+		ClientTimeout *time.Duration
+		// MaxRetries defaults to 3 when nil, zero disables retries.
+		MaxRetries       *int
+		RetryBackoff     time.Duration
+		RetryMaxBackoff  time.Duration
+		BreakerThreshold int
+		BreakerCooldown  time.Duration
+	}
+)
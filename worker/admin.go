@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Stats represents worker consumption details.
+type Stats struct {
+	QueueDepth int                   `json:"queue_depth"`
+	InFlight   []InFlightJob         `json:"in_flight"`
+	Topics     map[string]TopicStats `json:"topics"`
+	Schedules  []ScheduleStats       `json:"schedules"`
+}
+
+// InFlightJob represents a job currently handled by a worker.
+type InFlightJob struct {
+	WorkerID  int       `json:"worker_id"`
+	Topic     string    `json:"topic"`
+	Key       string    `json:"key"`
+	Partition int32     `json:"partition"`
+	Offset    int64     `json:"offset"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// TopicStats represents consumption details of a topic.
+type TopicStats struct {
+	Paused    bool  `json:"paused"`
+	Held      int   `json:"held"`
+	Succeeded int64 `json:"succeeded"`
+	Failed    int64 `json:"failed"`
+	// LastOffsets holds the last committed offset by partition.
+	LastOffsets map[int32]int64 `json:"last_offsets"`
+}
+
+// topicPauser is implemented by job listeners that can pause consumption
+// of a topic at the source.
+type topicPauser interface {
+	Pause(topic string) error
+	Resume(topic string) error
+}
+
+// Pause stops processing jobs of the topic, jobs already received are held
+// until the topic is resumed.
+func (w *Worker) Pause(topic string) error {
+	if _, ok := w.router[topic]; !ok {
+		return fmt.Errorf("topic not handled: %s", topic)
+	}
+
+	w.mu.Lock()
+	w.topicStats(topic).Paused = true
+	w.mu.Unlock()
+
+	if p, ok := w.listener.(topicPauser); ok {
+		if err := p.Pause(topic); err != nil {
+			return fmt.Errorf("listener pause: %s", err)
+		}
+	}
+	w.logger.Info("topic paused", "topic", topic)
+	return nil
+}
+
+// Resume continues processing jobs of the topic starting with held jobs.
+func (w *Worker) Resume(topic string) error {
+	if _, ok := w.router[topic]; !ok {
+		return fmt.Errorf("topic not handled: %s", topic)
+	}
+
+	if p, ok := w.listener.(topicPauser); ok {
+		if err := p.Resume(topic); err != nil {
+			return fmt.Errorf("listener resume: %s", err)
+		}
+	}
+
+	w.mu.Lock()
+	w.topicStats(topic).Paused = false
+	// Wakes up workers to process held jobs.
+	close(w.resumed)
+	w.resumed = make(chan struct{})
+	w.mu.Unlock()
+	w.logger.Info("topic resumed", "topic", topic)
+	return nil
+}
+
+// Stats returns current worker consumption details.
+func (w *Worker) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s := Stats{
+		QueueDepth: len(w.queue),
+		InFlight:   []InFlightJob{},
+		Topics:     map[string]TopicStats{},
+		Schedules:  w.Schedules(),
+	}
+	for _, shard := range w.shards {
+		s.QueueDepth += len(shard)
+	}
+	for _, j := range w.inFlight {
+		s.InFlight = append(s.InFlight, j)
+	}
+	sort.Slice(s.InFlight, func(i, j int) bool {
+		return s.InFlight[i].WorkerID < s.InFlight[j].WorkerID
+	})
+	for t := range w.router {
+		ts := *w.topicStats(t)
+		ts.LastOffsets = map[int32]int64{}
+		for p, o := range w.topics[t].LastOffsets {
+			ts.LastOffsets[p] = o
+		}
+		s.Topics[t] = ts
+	}
+	return s
+}
+
+// topicStats returns topic stats that needs to be guarded by mu.
+func (w *Worker) topicStats(topic string) *TopicStats {
+	ts, ok := w.topics[topic]
+	if !ok {
+		ts = &TopicStats{LastOffsets: map[int32]int64{}}
+		w.topics[topic] = ts
+	}
+	return ts
+}
+
+func (w *Worker) isPaused(topic string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.topicStats(topic).Paused
+}
+
+// resumedC returns a channel that is closed when any topic resumes.
+func (w *Worker) resumedC() <-chan struct{} {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.resumed
+}
+
+func (w *Worker) startJob(workerID int, job Job) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inFlight[workerID] = InFlightJob{
+		WorkerID:  workerID,
+		Topic:     job.Topic,
+		Key:       job.Key,
+		Partition: job.Partition,
+		Offset:    job.Offset,
+		StartedAt: time.Now(),
+	}
+}
+
+// finishJob records the handler result, the offset is only recorded when the
+// job was committed.
+func (w *Worker) finishJob(workerID int, job Job, err error, committed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.inFlight, workerID)
+
+	ts := w.topicStats(job.Topic)
+	if err != nil {
+		ts.Failed++
+		return
+	}
+	ts.Succeeded++
+	if committed {
+		ts.LastOffsets[job.Partition] = job.Offset
+	}
+}
+
+func (w *Worker) holdJob(topic string, n int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.topicStats(topic).Held += n
+}
@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed standard 5 field cron expression
+// (minute hour day-of-month month day-of-week) evaluated in Location.
+//
+// Supports lists (1,15), ranges (1-5), steps (*/15, 0-30/10), month and
+// weekday names (JAN, MON), descriptors (@hourly, @daily, @midnight,
+// @weekly, @monthly, @yearly, @annually) and an optional CRON_TZ= or TZ=
+// prefix, ex. "CRON_TZ=Asia/Manila 0 0 * * MON". Expressions without a
+// time zone are evaluated in time.Local.
+type Cron struct {
+	Expr     string
+	Location *time.Location
+
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar follows cron semantics where day-of-month and
+	// day-of-week matches either one if both are restricted.
+	domStar, dowStar bool
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	cronWeekdays = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	fieldMinute = cronField{"minute", 0, 59, nil}
+	fieldHour   = cronField{"hour", 0, 23, nil}
+	fieldDom    = cronField{"day-of-month", 1, 31, nil}
+	fieldMonth  = cronField{"month", 1, 12, cronMonths}
+	// Day-of-week accepts 7 as sunday.
+	fieldDow = cronField{"day-of-week", 0, 7, cronWeekdays}
+)
+
+// ParseCron parses cron expression.
+func ParseCron(expr string) (*Cron, error) {
+	c := &Cron{Expr: expr, Location: time.Local}
+
+	spec := strings.TrimSpace(expr)
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		tz, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(tz, "=")
+		loc, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("cron: invalid time zone %q: %s", name, err)
+		}
+		c.Location = loc
+		spec = strings.TrimSpace(rest)
+	}
+	if d, ok := cronDescriptors[strings.ToLower(spec)]; ok {
+		spec = d
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron: expected 5 fields, got %d in %q", len(fields), expr)
+	}
+
+	var err error
+	if c.minute, err = fieldMinute.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if c.hour, err = fieldHour.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if c.dom, err = fieldDom.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if c.month, err = fieldMonth.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if c.dow, err = fieldDow.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*" || fields[2] == "?"
+	c.dowStar = fields[4] == "*" || fields[4] == "?"
+
+	return c, nil
+}
+
+// parse returns bitset of values matched by the field expression.
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step, hasStep := strings.Cut(part, "/")
+
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = v, v
+			// "5/15" means starting from 5 until max.
+			if hasStep {
+				hi = f.max
+			}
+		}
+		if lo > hi {
+			return 0, fmt.Errorf("cron: invalid %s range %q", f.name, part)
+		}
+
+		n := 1
+		if hasStep {
+			var err error
+			if n, err = strconv.Atoi(step); err != nil || n <= 0 {
+				return 0, fmt.Errorf("cron: invalid %s step %q", f.name, part)
+			}
+		}
+		for v := lo; v <= hi; v += n {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("cron: invalid %s value %q", f.name, s)
+	}
+	return v, nil
+}
+
+// Next returns the next activation time after t in the cron location.
+// It returns zero time if there is no activation within five years.
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.In(c.Location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.Location)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.Location)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			// Adding an hour instead of rebuilding the date handles DST
+			// transitions where the wall clock skips or repeats an hour.
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c *Cron) String() string {
+	return c.Expr
+}
@@ -0,0 +1,160 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCron_Next(t *testing.T) {
+	manila, err := time.LoadLocation("Asia/Manila")
+	if err != nil {
+		t.Fatalf("load location: %s", err)
+	}
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load location: %s", err)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		t    time.Time
+		want time.Time
+	}{
+		{
+			"weekly monday midnight manila",
+			"CRON_TZ=Asia/Manila 0 0 * * MON",
+			time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), // Wed 20:00 Manila
+			time.Date(2024, 5, 6, 0, 0, 0, 0, manila),
+		},
+		{
+			"strictly after activation",
+			"CRON_TZ=Asia/Manila 0 0 * * MON",
+			time.Date(2024, 5, 6, 0, 0, 0, 0, manila),
+			time.Date(2024, 5, 13, 0, 0, 0, 0, manila),
+		},
+		{
+			"every 15 minutes",
+			"TZ=UTC */15 * * * *",
+			time.Date(2024, 5, 1, 10, 7, 30, 0, time.UTC),
+			time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			"range and list",
+			"TZ=UTC 30 9-17/4 * * 1,3",
+			time.Date(2024, 5, 1, 17, 31, 0, 0, time.UTC), // Wed
+			time.Date(2024, 5, 6, 9, 30, 0, 0, time.UTC),
+		},
+		{
+			"monthly descriptor",
+			"CRON_TZ=Asia/Manila @monthly",
+			time.Date(2024, 12, 15, 0, 0, 0, 0, manila),
+			time.Date(2025, 1, 1, 0, 0, 0, 0, manila),
+		},
+		{
+			"day-of-month or day-of-week",
+			"TZ=UTC 0 0 13 * FRI",
+			time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC), // Sun
+			time.Date(2024, 9, 6, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"sunday as 7",
+			"TZ=UTC 0 0 * * 7",
+			time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"leap day",
+			"TZ=UTC 0 0 29 FEB *",
+			time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			"skipped dst hour",
+			"CRON_TZ=America/New_York 30 2 * * *",
+			time.Date(2024, 3, 10, 0, 0, 0, 0, ny),
+			time.Date(2024, 3, 11, 2, 30, 0, 0, ny),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron() error = %v", err)
+			}
+			if got := c.Next(tt.t); !got.Equal(tt.want) {
+				t.Errorf("Next() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+		"CRON_TZ=Mars/Base 0 0 * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q) error = nil", expr)
+		}
+	}
+}
+
+func TestSchedule_NextRuns(t *testing.T) {
+	fn := func(ctx context.Context) error { return nil }
+	if _, err := NewCronSchedule("TZ=UTC 0 0 30 2 *", fn); err == nil {
+		t.Error("NewCronSchedule() never running cron error = nil")
+	}
+
+	s, err := NewCronSchedule("CRON_TZ=Asia/Manila 0 0 * * MON", fn)
+	if err != nil {
+		t.Fatalf("NewCronSchedule() error = %v", err)
+	}
+	got := s.NextRuns(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), 3)
+	want := []string{"2024-05-06T00:00:00+08:00", "2024-05-13T00:00:00+08:00", "2024-05-20T00:00:00+08:00"}
+	if len(got) != len(want) {
+		t.Fatalf("NextRuns() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Format(time.RFC3339) != want[i] {
+			t.Errorf("NextRuns()[%d] = %s, want %s", i, got[i].Format(time.RFC3339), want[i])
+		}
+	}
+}
+
+func TestSchedule_NextRunsInterval(t *testing.T) {
+	fn := func(ctx context.Context) error { return nil }
+	for _, interval := range []time.Duration{0, 30 * time.Second, 90 * time.Second} {
+		if _, err := NewIntervalSchedule(interval, fn); err == nil {
+			t.Errorf("NewIntervalSchedule(%s) error = nil", interval)
+		}
+	}
+
+	s, err := NewIntervalSchedule(10*time.Minute, fn)
+	if err != nil {
+		t.Fatalf("NewIntervalSchedule() error = %v", err)
+	}
+	// Replicas started at different times share the runs.
+	for _, start := range []time.Time{
+		time.Date(2024, 5, 1, 8, 3, 0, 0, time.UTC),
+		time.Date(2024, 5, 1, 8, 9, 59, 0, time.UTC),
+	} {
+		got := s.NextRuns(start, 2)
+		want := []string{"2024-05-01T08:10:00Z", "2024-05-01T08:20:00Z"}
+		for i := range want {
+			if got[i].Format(time.RFC3339) != want[i] {
+				t.Errorf("NextRuns(%s)[%d] = %s, want %s", start.Format(time.RFC3339), i, got[i].Format(time.RFC3339), want[i])
+			}
+		}
+	}
+}
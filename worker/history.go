@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TriggeredBySchedule is the run trigger of scheduled ticks, manual runs are
+// triggered by the requesting user id.
+const TriggeredBySchedule = "schedule"
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrScheduleRunning  = errors.New("schedule is running")
+)
+
+type RunStatus string
+
+const (
+	RunSucceeded RunStatus = "succeeded"
+	RunFailed    RunStatus = "failed"
+)
+
+// ScheduleRun represents an executed run of a schedule.
+type ScheduleRun struct {
+	Name        string    `json:"name"`
+	Tick        time.Time `json:"tick"`
+	TriggeredBy string    `json:"triggered_by"`
+	Holder      string    `json:"holder"`
+	Token       int64     `json:"token,omitempty"`
+	Status      RunStatus `json:"status"`
+	StartedAt   time.Time `json:"started_at"`
+	FinishedAt  time.Time `json:"finished_at"`
+	Err         string    `json:"err,omitempty"`
+}
+
+// ScheduleStats represents scheduled job run details.
+type ScheduleStats struct {
+	Name    string    `json:"name"`
+	Spec    string    `json:"spec"`
+	NextRun time.Time `json:"next_run"`
+	Running bool      `json:"running"`
+	// Lease is the last known lease of the schedule, held either by this or
+	// other replica.
+	Lease *Lease `json:"lease,omitempty"`
+	// LastRun is the last run executed by this replica.
+	LastRun *ScheduleRun `json:"last_run,omitempty"`
+}
+
+// runRecorder persists schedule runs shared between replicas.
+type runRecorder interface {
+	RecordRun(ctx context.Context, run ScheduleRun) error
+	ListRuns(ctx context.Context, name string, limit int) ([]ScheduleRun, error)
+}
+
+// scheduleState holds run details shared by copies of the schedule.
+type scheduleState struct {
+	mu    sync.Mutex
+	stats ScheduleStats
+}
+
+func (st *scheduleState) update(fn func(s *ScheduleStats)) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	fn(&st.stats)
+}
+
+func (st *scheduleState) get() ScheduleStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.stats
+}
+
+// start flags the schedule running, returns false if already running.
+func (st *scheduleState) start() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.stats.Running {
+		return false
+	}
+	st.stats.Running = true
+	return true
+}
+
+func (st *scheduleState) finish() {
+	st.update(func(s *ScheduleStats) { s.Running = false })
+}
+
+// SetRunRecorder enables persisting schedule runs.
+func (w *Worker) SetRunRecorder(r runRecorder) {
+	w.recorder = r
+}
+
+// Schedules returns details of registered schedules.
+func (w *Worker) Schedules() []ScheduleStats {
+	ss := make([]ScheduleStats, 0, len(w.schedules))
+	for _, s := range w.schedules {
+		ss = append(ss, s.state.get())
+	}
+	return ss
+}
+
+// ScheduleRuns returns the latest recorded runs of the schedule from all
+// replicas, latest first.
+func (w *Worker) ScheduleRuns(ctx context.Context, name string, limit int) ([]ScheduleRun, error) {
+	if _, err := w.schedule(name); err != nil {
+		return nil, err
+	}
+	if w.recorder == nil {
+		return []ScheduleRun{}, nil
+	}
+	runs, err := w.recorder.ListRuns(ctx, name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("recorder.ListRuns: %s", err)
+	}
+	return runs, nil
+}
+
+// RunSchedule triggers the schedule on demand in the background, returns
+// ErrScheduleRunning when the schedule is running on any replica.
+func (w *Worker) RunSchedule(name, triggeredBy string) error {
+	s, err := w.schedule(name)
+	if err != nil {
+		return err
+	}
+	if !s.state.start() {
+		return ErrScheduleRunning
+	}
+
+	s.locker, s.leaseTTL, s.holder, s.recorder = w.locker, w.leaseTTL, w.holder, w.recorder
+	run := ScheduleRun{Tick: time.Now(), TriggeredBy: triggeredBy}
+	if s.locker == nil {
+		go func() {
+			defer s.state.finish()
+			s.call(context.Background(), run)
+		}()
+		return nil
+	}
+
+	running, ok, err := s.acquire(context.Background(), s.runningKey())
+	if err != nil {
+		s.state.finish()
+		return fmt.Errorf("schedule lease: %s", err)
+	}
+	if !ok {
+		s.state.finish()
+		return fmt.Errorf("%w: held by %s", ErrScheduleRunning, running.Holder)
+	}
+	go func() {
+		defer s.state.finish()
+		s.callLeased(context.Background(), run, running)
+	}()
+	return nil
+}
+
+func (w *Worker) schedule(name string) (Schedule, error) {
+	for _, s := range w.schedules {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Schedule{}, fmt.Errorf("%w: %s", ErrScheduleNotFound, name)
+}
@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultLeaseTTL is the schedule lease ttl when not set.
+const defaultLeaseTTL = 30 * time.Second
+
+// ErrStaleLease is returned by writes of a schedule run holding a lease
+// older than the latest lease seen by the storage.
+var ErrStaleLease = errors.New("stale schedule lease")
+
+// Lease represents a lock held by a replica to run a schedule tick.
+type Lease struct {
+	Key string `json:"key"`
+	// Schedule is the schedule name of the lease, writes are fenced by
+	// schedule.
+	Schedule string `json:"schedule,omitempty"`
+	Holder   string `json:"holder"`
+	// Token is a fencing token that increases on every acquired lease of the
+	// schedule, writes of a tick can be rejected when older than the latest.
+	Token      int64     `json:"token"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// scheduleLocker acquires leases to guarantee a single replica runs a tick.
+type scheduleLocker interface {
+	// Acquire returns the lease and true when acquired, otherwise returns the
+	// lease of the current holder and false.
+	Acquire(ctx context.Context, key, holder string, ttl time.Duration) (Lease, bool, error)
+	Renew(ctx context.Context, lease Lease, ttl time.Duration) error
+	// Release ends the lease, lease is kept until ttl so replicas with late
+	// clocks skips the tick.
+	Release(ctx context.Context, lease Lease) error
+	// Unlock deletes the lease when still held so it can be acquired again.
+	Unlock(ctx context.Context, lease Lease) error
+}
+
+type leaseCtxKey struct{}
+
+// LeaseFromContext returns the running lease of the schedule run, writes of
+// the run are rejected with ErrStaleLease once a later run wrote.
+func LeaseFromContext(ctx context.Context) (Lease, bool) {
+	l, ok := ctx.Value(leaseCtxKey{}).(Lease)
+	return l, ok
+}
+
+// SetLocker enables schedule leases so a tick runs on a single replica.
+func (w *Worker) SetLocker(l scheduleLocker, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+	w.locker = l
+	w.leaseTTL = ttl
+}
+
+// leaseKey returns lease key of the tick, tick is truncated to minute to
+// tolerate clock differences between replicas.
+func (s *Schedule) leaseKey(tick time.Time) string {
+	return fmt.Sprintf("schedule:{%s}:%d", s.Name, tick.Truncate(time.Minute).Unix())
+}
+
+// runningKey returns the lease key held by ticks and manual runs while the
+// schedule is running on any replica.
+func (s *Schedule) runningKey() string {
+	return fmt.Sprintf("schedule:{%s}:running", s.Name)
+}
+
+// acquire returns the lease of key and true when acquired, errors are logged
+// and skip the run rather than risk running it on multiple replicas.
+func (s *Schedule) acquire(ctx context.Context, key string) (Lease, bool, error) {
+	lease, ok, err := s.locker.Acquire(ctx, key, s.holder, s.leaseTTL)
+	if err != nil {
+		s.logger.Error("schedule lease acquire", "schedule", s.Name, "key", key, "err", err)
+		return Lease{}, false, err
+	}
+	lease.Schedule = s.Name
+	s.state.update(func(st *ScheduleStats) { st.Lease = &lease })
+	return lease, ok, nil
+}
+
+// renew keeps the leases while fn is running and cancels the context when
+// any lease is lost.
+func (s *Schedule) renew(ctx context.Context, cancel context.CancelFunc, leases ...Lease) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		t := time.NewTicker(s.leaseTTL / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				for _, lease := range leases {
+					if err := s.locker.Renew(ctx, lease, s.leaseTTL); err != nil {
+						s.logger.Error("schedule lease lost", "schedule", s.Name, "key", lease.Key, "token", lease.Token, "err", err)
+						cancel()
+						return
+					}
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// leaseHolder returns replica identity holding leases.
+func leaseHolder() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedule_SingleRunnerPerTick(t *testing.T) {
+	locker := &memLocker{leases: map[string]Lease{}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	var runs atomic.Int32
+	var tokens sync.Map
+	fn := func(ctx context.Context) error {
+		runs.Add(1)
+		lease, ok := LeaseFromContext(ctx)
+		if !ok {
+			return errors.New("missing lease")
+		}
+		tokens.Store(lease.Token, lease.Holder)
+		return nil
+	}
+
+	// Replicas with the same schedule name.
+	replicas := make([]*Worker, 3)
+	for i := range replicas {
+		w := New(&mockListener{}, 1, logger)
+		w.SetLocker(locker, time.Minute)
+		s, err := NewCronSchedule("TZ=UTC 0 0 * * *", fn)
+		if err != nil {
+			t.Fatalf("NewCronSchedule() error = %v", err)
+		}
+		s.Name = "close-period"
+		w.SetSchedule(s)
+		replicas[i] = w
+	}
+
+	ticks := []time.Time{
+		time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC),
+		// Late clock on the same tick.
+		time.Date(2024, 5, 6, 0, 0, 20, 0, time.UTC),
+		time.Date(2024, 5, 7, 0, 0, 0, 0, time.UTC),
+	}
+	for _, tick := range ticks {
+		var wg sync.WaitGroup
+		for i, w := range replicas {
+			wg.Add(1)
+			go func(holder string, s Schedule) {
+				defer wg.Done()
+				s.locker, s.leaseTTL, s.holder = w.locker, w.leaseTTL, holder
+				s.tick(context.Background(), tick)
+			}(string(rune('a'+i)), w.schedules[0])
+		}
+		wg.Wait()
+	}
+
+	if got := runs.Load(); got != 2 {
+		t.Errorf("runs = %d, want 2", got)
+	}
+	// Runs hold the running lease acquired after the tick lease.
+	for _, token := range []int64{2, 4} {
+		if _, ok := tokens.Load(token); !ok {
+			t.Errorf("fencing token %d not used", token)
+		}
+	}
+
+	var ran int
+	for _, w := range replicas {
+		st := w.Schedules()[0]
+		if st.Lease == nil || st.Lease.Token < 3 {
+			t.Errorf("lease = %+v, want lease of the last tick", st.Lease)
+		}
+		if st.LastRun != nil {
+			ran++
+		}
+	}
+	if ran == 0 {
+		t.Error("no replica recorded last run")
+	}
+}
+
+// memLocker is an in-memory scheduleLocker that never expires leases.
+type memLocker struct {
+	mu     sync.Mutex
+	fence  int64
+	leases map[string]Lease
+}
+
+func (m *memLocker) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (Lease, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.leases[key]; ok {
+		return l, false, nil
+	}
+	m.fence++
+	l := Lease{Key: key, Holder: holder, Token: m.fence, AcquiredAt: time.Now()}
+	m.leases[key] = l
+	return l, true, nil
+}
+
+func (m *memLocker) Renew(ctx context.Context, lease Lease, ttl time.Duration) error { return nil }
+
+func (m *memLocker) Release(ctx context.Context, lease Lease) error { return nil }
+
+func (m *memLocker) Unlock(ctx context.Context, lease Lease) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.leases[lease.Key]; ok && l.Token == lease.Token {
+		delete(m.leases, lease.Key)
+	}
+	return nil
+}
+
+func TestWorker_RunScheduleWhileTickRunning(t *testing.T) {
+	locker := &memLocker{leases: map[string]Lease{}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	ticked := make(chan struct{})
+	replicas := make([]*Worker, 2)
+	for i := range replicas {
+		w := New(&mockListener{}, 1, logger)
+		w.SetLocker(locker, time.Minute)
+		s, err := NewCronSchedule("TZ=UTC 0 0 * * *", func(ctx context.Context) error {
+			select {
+			case started <- struct{}{}:
+				<-release
+			default:
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("NewCronSchedule() error = %v", err)
+		}
+		s.Name = "close-period"
+		w.SetSchedule(s)
+		replicas[i] = w
+	}
+
+	s := replicas[0].schedules[0]
+	s.locker, s.leaseTTL, s.holder = locker, time.Minute, "a"
+	go func() {
+		defer close(ticked)
+		s.tick(context.Background(), time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC))
+	}()
+	<-started
+
+	if err := replicas[1].RunSchedule("close-period", "admin"); !errors.Is(err, ErrScheduleRunning) {
+		t.Errorf("RunSchedule() during tick error = %v, want %v", err, ErrScheduleRunning)
+	}
+	close(release)
+	<-ticked
+
+	if err := replicas[1].RunSchedule("close-period", "admin"); err != nil {
+		t.Errorf("RunSchedule() after tick error = %v", err)
+	}
+}
+
+func TestWorker_RunSchedule(t *testing.T) {
+	w := New(&mockListener{}, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.SetLocker(&memLocker{leases: map[string]Lease{}}, time.Minute)
+	rec := &memRecorder{recorded: make(chan ScheduleRun, 1)}
+	w.SetRunRecorder(rec)
+
+	release := make(chan struct{})
+	s, err := NewCronSchedule("@daily", func(ctx context.Context) error {
+		<-release
+		return errors.New("period not closed")
+	})
+	if err != nil {
+		t.Fatalf("NewCronSchedule() error = %v", err)
+	}
+	s.Name = "close-period"
+	w.SetSchedule(s)
+
+	if err = w.RunSchedule("unknown", "admin"); !errors.Is(err, ErrScheduleNotFound) {
+		t.Errorf("RunSchedule() unknown error = %v, want %v", err, ErrScheduleNotFound)
+	}
+	if err = w.RunSchedule("close-period", "admin"); err != nil {
+		t.Fatalf("RunSchedule() error = %v", err)
+	}
+	if err = w.RunSchedule("close-period", "admin"); !errors.Is(err, ErrScheduleRunning) {
+		t.Errorf("RunSchedule() running error = %v, want %v", err, ErrScheduleRunning)
+	}
+	close(release)
+
+	run := <-rec.recorded
+	if run.Name != "close-period" || run.TriggeredBy != "admin" || run.Status != RunFailed ||
+		run.Err != "period not closed" || run.Token != 1 || run.Holder == "" {
+		t.Errorf("recorded run = %+v", run)
+	}
+}
+
+type memRecorder struct {
+	recorded chan ScheduleRun
+}
+
+func (m *memRecorder) RecordRun(ctx context.Context, run ScheduleRun) error {
+	m.recorded <- run
+	return nil
+}
+
+func (m *memRecorder) ListRuns(ctx context.Context, name string, limit int) ([]ScheduleRun, error) {
+	return nil, nil
+}
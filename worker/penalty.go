@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/penalty"
+)
+
+type penaltyWriter interface {
+	ApplyPenalty(ctx context.Context, e penalty.Event) error
+}
+
+// ConsumeDriverPenalty applies penalty of the offense kind consumed from the
+// driver cancellation or complaint topics.
+func ConsumeDriverPenalty(w penaltyWriter, kind penalty.Kind) JobHandler {
+	return func(ctx context.Context, job Job) error {
+		var e penalty.Event
+		if err := json.Unmarshal(job.Payload, &e); err != nil {
+			return fmt.Errorf("decode penalty event: %s", err)
+		}
+		e.Kind = kind
+		if err := e.Validate(); err != nil {
+			return err
+		}
+
+		if err := w.ApplyPenalty(ctx, e); err != nil {
+			return fmt.Errorf("failed to apply penalty: %s", err)
+		}
+		return nil
+	}
+}
+
+// PenaltyDriverKey keys penalty jobs by driver id so penalties and trips of
+// the same driver are processed in order.
+func PenaltyDriverKey(job Job) string {
+	var e penalty.Event
+	if err := json.Unmarshal(job.Payload, &e); err != nil {
+		return ""
+	}
+	return e.DriverID
+}
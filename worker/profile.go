@@ -0,0 +1,32 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+)
+
+type profileWriter interface {
+	SaveProfile(ctx context.Context, p driver.Profile) error
+}
+
+// ConsumeDriverProfile stores driver profiles consumed from the driver
+// profile topic.
+func ConsumeDriverProfile(w profileWriter) JobHandler {
+	return func(ctx context.Context, job Job) error {
+		var p driver.Profile
+		if err := json.Unmarshal(job.Payload, &p); err != nil {
+			return fmt.Errorf("decode driver profile: %s", err)
+		}
+		if err := p.Validate(); err != nil {
+			return err
+		}
+
+		if err := w.SaveProfile(ctx, p); err != nil {
+			return fmt.Errorf("failed to save driver profile: %s", err)
+		}
+		return nil
+	}
+}
@@ -4,42 +4,210 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
 	"time"
 )
 
+// CatchUp is a policy for runs missed while the previous run is still
+// executing or the process was suspended.
+type CatchUp int
+
+const (
+	// CatchUpSkip skips missed runs and waits for the next activation.
+	CatchUpSkip CatchUp = iota
+	// CatchUpOnce runs once immediately for all missed runs.
+	CatchUpOnce
+)
+
 type Schedule struct {
+	// Name identifies the schedule across replicas for leases.
+	Name string
+	// Reset is the clock of the first run, zero aligns runs to Frequency.
 	Reset     time.Time
 	Frequency time.Duration
-	Fn        func(ctx context.Context) error
+	// Cron overrides Reset and Frequency when set.
+	Cron *Cron
+	// Jitter delays each run by a random duration up to Jitter to spread
+	// the load of schedules sharing the same activation time.
+	Jitter  time.Duration
+	CatchUp CatchUp
+	Fn      func(ctx context.Context) error
 
-	done   chan struct{}
-	logger *slog.Logger
+	done     chan struct{}
+	logger   *slog.Logger
+	state    *scheduleState
+	locker   scheduleLocker
+	leaseTTL time.Duration
+	holder   string
+	recorder runRecorder
 }
 
 func (s *Schedule) run(ctx context.Context) error {
-	starts := ComputeResetOffset(time.Now(), s.Reset)
-	s.logger.Info(fmt.Sprintln("schedule starts in", starts))
-	t := time.NewTimer(starts)
-	<-t.C
-
-	s.logger.Info(fmt.Sprintln("schedule started at", t))
-	ticker := time.NewTicker(s.Frequency)
-	go func() {
-		for {
-			select {
-			case <-s.done:
-				s.logger.Info("schedule exited")
-				return
-			case tk := <-ticker.C:
-				s.logger.Info(fmt.Sprintln("schedule executed at", tk))
-				if err := s.Fn(ctx); err != nil {
-					s.logger.Error("schedule error", "err", err)
-				}
-			}
+	next := s.first(time.Now())
+	for {
+		if next.IsZero() {
+			return fmt.Errorf("schedule %s has no next run", s)
+		}
+		wait := time.Until(next) + s.jitter()
+		s.state.update(func(st *ScheduleStats) { st.NextRun = next })
+		s.logger.Info(fmt.Sprintln("schedule next run at", next, "in", wait))
+
+		t := time.NewTimer(wait)
+		select {
+		case <-s.done:
+			t.Stop()
+			s.logger.Info("schedule exited")
+			return nil
+		case tk := <-t.C:
+			s.logger.Info(fmt.Sprintln("schedule executed at", tk))
+			s.tick(ctx, next)
 		}
-	}()
 
-	return nil
+		now := time.Now()
+		following := s.next(next)
+		if following.After(now) {
+			next = following
+			continue
+		}
+
+		// Activations passed while running, catch up based on policy.
+		switch s.CatchUp {
+		case CatchUpOnce:
+			s.logger.Warn("schedule missed runs, catching up once", "missed", following)
+			s.tick(ctx, following)
+		default:
+			s.logger.Warn("schedule missed runs, skipping", "missed", following)
+		}
+		next = s.next(time.Now())
+	}
+}
+
+// exec runs fn of the tick, when locker is set fn only runs if the tick lease
+// and the running lease of the schedule are acquired. Schedule must be
+// started before exec.
+func (s *Schedule) exec(ctx context.Context, run ScheduleRun, key string) {
+	defer s.state.finish()
+
+	if s.locker == nil {
+		s.call(ctx, run)
+		return
+	}
+
+	tick, ok, err := s.acquire(ctx, key)
+	if err != nil {
+		return
+	}
+	if !ok {
+		s.logger.Info("schedule tick held by other replica", "schedule", s.Name, "holder", tick.Holder, "token", tick.Token)
+		return
+	}
+	running, ok, err := s.acquire(ctx, s.runningKey())
+	if err != nil {
+		return
+	}
+	if !ok {
+		s.logger.Warn("schedule running on other replica, skipping tick", "schedule", s.Name, "holder", running.Holder, "token", running.Token)
+		return
+	}
+	s.callLeased(ctx, run, running, tick)
+}
+
+// callLeased runs fn with the running lease in context while renewing the
+// leases, the running lease is unlocked after the run.
+func (s *Schedule) callLeased(ctx context.Context, run ScheduleRun, running Lease, held ...Lease) {
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, leaseCtxKey{}, running))
+	defer cancel()
+	stop := s.renew(ctx, cancel, append([]Lease{running}, held...)...)
+	run.Token = running.Token
+	s.call(ctx, run)
+	stop()
+
+	if err := s.locker.Unlock(context.Background(), running); err != nil {
+		s.logger.Error("schedule lease unlock", "schedule", s.Name, "err", err)
+	}
+	for _, lease := range held {
+		if err := s.locker.Release(context.Background(), lease); err != nil {
+			s.logger.Error("schedule lease release", "schedule", s.Name, "err", err)
+		}
+	}
+}
+
+func (s *Schedule) call(ctx context.Context, run ScheduleRun) {
+	run.Name = s.Name
+	run.Holder = s.holder
+	run.StartedAt = time.Now()
+
+	run.Status = RunSucceeded
+	if err := s.Fn(ctx); err != nil {
+		s.logger.Error("schedule error", "schedule", s.Name, "err", err)
+		run.Status = RunFailed
+		run.Err = err.Error()
+	}
+	run.FinishedAt = time.Now()
+	s.state.update(func(st *ScheduleStats) { st.LastRun = &run })
+
+	if s.recorder == nil {
+		return
+	}
+	if err := s.recorder.RecordRun(context.Background(), run); err != nil {
+		s.logger.Error("schedule record run", "schedule", s.Name, "err", err)
+	}
+}
+
+// tick starts the scheduled run of the tick unless the schedule is running.
+func (s *Schedule) tick(ctx context.Context, tick time.Time) {
+	if !s.state.start() {
+		s.logger.Warn("schedule still running, skipping tick", "schedule", s.Name, "tick", tick)
+		return
+	}
+	s.exec(ctx, ScheduleRun{Tick: tick, TriggeredBy: TriggeredBySchedule}, s.leaseKey(tick))
+}
+
+// first returns the first activation after t.
+func (s *Schedule) first(t time.Time) time.Time {
+	if s.Cron != nil {
+		return s.Cron.Next(t)
+	}
+	if s.Reset.IsZero() {
+		return t.Truncate(s.Frequency).Add(s.Frequency)
+	}
+	return t.Add(ComputeResetOffset(t, s.Reset))
+}
+
+// next returns the activation following t.
+func (s *Schedule) next(t time.Time) time.Time {
+	if s.Cron != nil {
+		return s.Cron.Next(t)
+	}
+	return t.Add(s.Frequency)
+}
+
+func (s *Schedule) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.Jitter)
+}
+
+// NextRuns returns the next n activation times after t excluding jitter.
+func (s Schedule) NextRuns(t time.Time, n int) []time.Time {
+	runs := make([]time.Time, 0, n)
+	next := s.first(t)
+	for i := 0; i < n && !next.IsZero(); i++ {
+		runs = append(runs, next)
+		next = s.next(next)
+	}
+	return runs
+}
+
+func (s Schedule) String() string {
+	if s.Cron != nil {
+		return fmt.Sprintf("cron(%s)", s.Cron)
+	}
+	if s.Reset.IsZero() {
+		return fmt.Sprintf("every %s", s.Frequency)
+	}
+	return fmt.Sprintf("every %s from %s", s.Frequency, s.Reset.Format(time.Kitchen))
 }
 
 func NewSchedule(clock string, frequency time.Duration, fn func(ctx context.Context) error) (Schedule, error) {
@@ -58,4 +226,35 @@ func NewSchedule(clock string, frequency time.Duration, fn func(ctx context.Cont
 		Frequency: frequency,
 		Fn:        fn,
 	}, nil
-}
\ No newline at end of file
+}
+
+// NewIntervalSchedule returns schedule that runs fn every interval on runs
+// aligned to the interval so replicas share the same runs, ex. every 10m runs
+// at :00, :10 and :20. Runs are leased by minute, interval must be whole
+// minutes.
+func NewIntervalSchedule(interval time.Duration, fn func(ctx context.Context) error) (Schedule, error) {
+	if interval < time.Minute || interval%time.Minute != 0 {
+		return Schedule{}, fmt.Errorf("interval %s must be whole minutes", interval)
+	}
+	return Schedule{
+		Frequency: interval,
+		Fn:        fn,
+	}, nil
+}
+
+// NewCronSchedule returns schedule that runs fn on cron expression activations,
+// ex. "CRON_TZ=Asia/Manila 0 0 * * MON" runs every monday midnight in Manila.
+func NewCronSchedule(expr string, fn func(ctx context.Context) error) (Schedule, error) {
+	c, err := ParseCron(expr)
+	if err != nil {
+		return Schedule{}, fmt.Errorf("error parsing cron: %w", err)
+	}
+	if c.Next(time.Now()).IsZero() {
+		return Schedule{}, fmt.Errorf("cron %q never runs", expr)
+	}
+
+	return Schedule{
+		Cron: c,
+		Fn:   fn,
+	}, nil
+}
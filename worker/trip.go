@@ -2,7 +2,6 @@ package worker
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 
 	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
@@ -10,21 +9,47 @@ import (
 
 type tripWriter interface {
 	UpdateLeaderboard(ctx context.Context, trip trip.Event) error
+	ReverseTrip(ctx context.Context, trip trip.Event) error
 }
 
-func ConsumeTripCompleted(w tripWriter) JobHandler {
+type tripDecoder interface {
+	Decode(payload []byte) (trip.Envelope, error)
+}
+
+func ConsumeTripCompleted(w tripWriter, dec tripDecoder) JobHandler {
 	return func(ctx context.Context, job Job) error {
-		var d trip.Event
-		if err := json.Unmarshal(job.Payload, &d); err != nil {
-			return fmt.Errorf("json unmarshall: %s", err)
+		env, err := dec.Decode(job.Payload)
+		if err != nil {
+			return fmt.Errorf("decode trip event: %s", err)
+		}
+		if err = env.Validate(); err != nil {
+			return err
 		}
 
-		if d.Status == "complete" {
+		d := env.Data
+		switch {
+		case d.Status == trip.StatusComplete:
 			if err := w.UpdateLeaderboard(ctx, d); err != nil {
 				return fmt.Errorf("failed to update leaderboard: %s", err)
 			}
+		case trip.IsReversal(d.Status):
+			if err := w.ReverseTrip(ctx, d); err != nil {
+				return fmt.Errorf("failed to reverse trip: %s", err)
+			}
 		}
 
 		return nil
 	}
 }
+
+// TripDriverKey keys trip jobs by driver id so trips of the same driver are
+// processed in order. Trip messages are keyed by trip id by the producer.
+func TripDriverKey(dec tripDecoder) KeyFunc {
+	return func(job Job) string {
+		env, err := dec.Decode(job.Payload)
+		if err != nil {
+			return ""
+		}
+		return env.Data.DriverID
+	}
+}
@@ -0,0 +1,119 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+)
+
+// capGrace keeps the daily caps of a trip day after the day ends so late
+// and redelivered trips of the day are counted against the same caps.
+const capGrace = 48 * time.Hour
+
+// TripCaps represents daily per driver caps of completed trips and earnings
+// counted toward the leaderboard, zero disables the cap.
+type TripCaps struct {
+	MaxTrips    int
+	MaxEarnings float64
+	// ResetClock is the time of day caps reset in Location.
+	ResetClock time.Time
+	Location   *time.Location
+}
+
+// capSource counts daily trips and earnings per driver once per trip.
+type capSource interface {
+	// Count counts the trip in the caps of key once per trip id and sets
+	// expiry when the caps have none, returns the earnings counted within
+	// maxEarnings and false when over maxTrips. Trips without id are counted
+	// each time.
+	Count(ctx context.Context, key, tripID string, earnings float64, maxTrips int, maxEarnings float64, expr time.Duration) (float64, bool, error)
+	// Release gives back the trip and earnings counted for the trip id.
+	Release(ctx context.Context, key, tripID string) error
+}
+
+// TripCapper caps the trips and earnings of a driver scored per trip day, so
+// farming many small trips can't win the leaderboard.
+type TripCapper struct {
+	caps   TripCaps
+	source capSource
+	logger *slog.Logger
+}
+
+// NewTripCapper returns capper of the trips scored in the leaderboard.
+func NewTripCapper(src capSource, caps TripCaps, logger *slog.Logger) *TripCapper {
+	return &TripCapper{caps: caps, source: src, logger: logger}
+}
+
+// Cap counts the trip in the daily caps of its trip day, returns the trip with
+// the earnings within the earnings cap and false when over the trip cap.
+// Trips are counted once per trip id, a retried trip gets the caps counted
+// the first time.
+func (c *TripCapper) Cap(ctx context.Context, t trip.Event) (trip.Event, bool, error) {
+	if c.caps.MaxTrips <= 0 && c.caps.MaxEarnings <= 0 {
+		return t, true, nil
+	}
+
+	day, expr := c.day(t.CompletedAt())
+	earned := t.Price.DriverEarnings
+	counted, ok, err := c.source.Count(ctx, capKey(t.DriverID, day), t.TripRequestID,
+		earned, c.caps.MaxTrips, c.caps.MaxEarnings, expr)
+	if err != nil {
+		return t, false, fmt.Errorf("trip cap: source.Count: %s", err)
+	}
+	if !ok {
+		c.logger.InfoContext(ctx, "trip not scored, daily trip cap reached",
+			"driver_id", t.DriverID, "trip_request_id", t.TripRequestID, "day", day, "max_trips", c.caps.MaxTrips)
+		return t, false, nil
+	}
+	// Counts only the earnings within the cap.
+	if counted < earned {
+		c.logger.InfoContext(ctx, "trip earnings capped, daily earnings cap reached",
+			"driver_id", t.DriverID, "trip_request_id", t.TripRequestID, "day", day,
+			"earnings", earned, "counted", counted)
+		t.Price.DriverEarnings = counted
+	}
+	return t, true, nil
+}
+
+// Release gives back the trip and earnings counted in the daily caps of the
+// reversed trip contribution.
+func (c *TripCapper) Release(ctx context.Context, contrib driver.Contribution) error {
+	if c.caps.MaxTrips <= 0 && c.caps.MaxEarnings <= 0 || contrib.TripRequestID == "" {
+		return nil
+	}
+
+	at := contrib.TripAt
+	if at.IsZero() {
+		at = contrib.AppliedAt
+	}
+	day, _ := c.day(at)
+	if err := c.source.Release(ctx, capKey(contrib.DriverID, day), contrib.TripRequestID); err != nil {
+		return fmt.Errorf("trip cap: source.Release: %s", err)
+	}
+	return nil
+}
+
+// capKey returns the key of the daily caps of the driver.
+func capKey(driverID, day string) string {
+	return fmt.Sprintf("trip_caps:%s:%s", driverID, day)
+}
+
+// day returns the cap day of t starting at the reset clock and the expiry of
+// its caps.
+func (c *TripCapper) day(t time.Time) (string, time.Duration) {
+	loc := c.caps.Location
+	if loc == nil {
+		loc = time.Local
+	}
+	t = t.In(loc)
+	reset := time.Date(t.Year(), t.Month(), t.Day(), c.caps.ResetClock.Hour(), c.caps.ResetClock.Minute(), 0, 0, loc)
+	if t.Before(reset) {
+		reset = reset.AddDate(0, 0, -1)
+	}
+	end := reset.AddDate(0, 0, 1)
+	return reset.Format("2006-01-02"), max(time.Until(end), 0) + capGrace
+}
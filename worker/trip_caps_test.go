@@ -0,0 +1,202 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/driver"
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+)
+
+func TestTripCapper_Cap(t *testing.T) {
+	day := time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		caps     TripCaps
+		earnings []float64
+		// days of the trips, defaults to day.
+		days []time.Time
+		// wantScored holds the earnings of scored trips.
+		wantScored []float64
+	}{
+		{
+			"no caps",
+			TripCaps{},
+			[]float64{10, 20, 30},
+			nil,
+			[]float64{10, 20, 30},
+		},
+		{
+			"trip cap",
+			TripCaps{MaxTrips: 2},
+			[]float64{10, 20, 30},
+			nil,
+			[]float64{10, 20},
+		},
+		{
+			"earnings cap",
+			TripCaps{MaxEarnings: 25},
+			[]float64{10, 20, 30},
+			nil,
+			[]float64{10, 15, 0},
+		},
+		{
+			"trip and earnings cap",
+			TripCaps{MaxTrips: 2, MaxEarnings: 15},
+			[]float64{10, 20, 30},
+			nil,
+			[]float64{10, 5},
+		},
+		{
+			"caps by trip day",
+			TripCaps{MaxTrips: 1, Location: time.UTC},
+			[]float64{10, 20, 30},
+			[]time.Time{day, day.AddDate(0, 0, -1), day.Add(time.Hour)},
+			[]float64{10, 20},
+		},
+		{
+			"day starts at reset clock",
+			TripCaps{MaxTrips: 1, ResetClock: time.Date(0, 1, 1, 11, 0, 0, 0, time.UTC), Location: time.UTC},
+			[]float64{10, 20},
+			[]time.Time{day, day.Add(time.Hour)},
+			[]float64{10, 20},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &memCapSource{caps: map[string]*memCaps{}}
+			c := NewTripCapper(src, tt.caps, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			var scored []float64
+			for i, earned := range tt.earnings {
+				e := trip.Event{DriverID: "driver-1", UpdatedAt: day}
+				if tt.days != nil {
+					e.UpdatedAt = tt.days[i]
+				}
+				e.Price.DriverEarnings = earned
+				capped, ok, err := c.Cap(context.Background(), e)
+				if err != nil {
+					t.Fatalf("Cap() error = %v", err)
+				}
+				if ok {
+					scored = append(scored, capped.Price.DriverEarnings)
+				}
+			}
+			if len(scored) != len(tt.wantScored) {
+				t.Fatalf("scored = %v, want %v", scored, tt.wantScored)
+			}
+			for i := range scored {
+				if scored[i] != tt.wantScored[i] {
+					t.Errorf("scored = %v, want %v", scored, tt.wantScored)
+				}
+			}
+		})
+	}
+}
+
+func TestTripCapper_Release(t *testing.T) {
+	day := time.Date(2024, 5, 6, 10, 0, 0, 0, time.UTC)
+	src := &memCapSource{caps: map[string]*memCaps{}}
+	c := NewTripCapper(src, TripCaps{MaxTrips: 2, MaxEarnings: 25, Location: time.UTC}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	cp := func(id string, earned float64) (float64, bool) {
+		e := trip.Event{TripRequestID: id, DriverID: "driver-1", UpdatedAt: day, Price: trip.PriceInfo{DriverEarnings: earned}}
+		capped, ok, err := c.Cap(context.Background(), e)
+		if err != nil {
+			t.Fatalf("Cap() error = %v", err)
+		}
+		return capped.Price.DriverEarnings, ok
+	}
+	cp("trip-1", 10)
+	// A retried trip is not counted again.
+	if got, ok := cp("trip-1", 10); !ok || got != 10 {
+		t.Errorf("retried Cap() = %v, %v, want 10, true", got, ok)
+	}
+	cp("trip-2", 20)
+	if _, ok := cp("trip-3", 5); ok {
+		t.Fatal("Cap() over trip cap = true, want false")
+	}
+
+	// The reversed trip gives back its trip and earnings, the trip over the
+	// cap has nothing to give back.
+	for _, id := range []string{"trip-2", "trip-2", "trip-3"} {
+		contrib := driver.Contribution{TripRequestID: id, DriverID: "driver-1", TripAt: day}
+		if err := c.Release(context.Background(), contrib); err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+	}
+	if got, ok := cp("trip-4", 30); !ok || got != 15 {
+		t.Errorf("Cap() after release = %v, %v, want 15, true", got, ok)
+	}
+}
+
+func TestTripCapper_SourceFailure(t *testing.T) {
+	src := &memCapSource{err: errors.New("source failure")}
+	c := NewTripCapper(src, TripCaps{MaxTrips: 2}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, ok, err := c.Cap(context.Background(), trip.Event{DriverID: "driver-1"}); err == nil || ok {
+		t.Errorf("Cap() = %v, %v, want error", ok, err)
+	}
+}
+
+// memCapSource counts caps like the redis cap source.
+type memCapSource struct {
+	caps map[string]*memCaps
+	err  error
+}
+
+type memCaps struct {
+	trips    int
+	earnings float64
+	// counted are the earnings counted per trip, -1 when over the trip cap.
+	counted map[string]float64
+}
+
+func (m *memCapSource) Count(ctx context.Context, key, tripID string, earnings float64, maxTrips int, maxEarnings float64, expr time.Duration) (float64, bool, error) {
+	if m.err != nil {
+		return 0, false, m.err
+	}
+	c, ok := m.caps[key]
+	if !ok {
+		c = &memCaps{counted: map[string]float64{}}
+		m.caps[key] = c
+	}
+	counted, ok := c.counted[tripID]
+	if !ok || tripID == "" {
+		counted = earnings
+		if maxTrips > 0 && c.trips >= maxTrips {
+			counted = -1
+		} else {
+			if maxEarnings > 0 {
+				counted = max(min(counted, maxEarnings-c.earnings), 0)
+			}
+			c.trips++
+			c.earnings += counted
+		}
+		if tripID != "" {
+			c.counted[tripID] = counted
+		}
+	}
+	return max(counted, 0), counted >= 0, nil
+}
+
+func (m *memCapSource) Release(ctx context.Context, key, tripID string) error {
+	if m.err != nil {
+		return m.err
+	}
+	c, ok := m.caps[key]
+	if !ok {
+		return nil
+	}
+	if counted, ok := c.counted[tripID]; ok {
+		delete(c.counted, tripID)
+		if counted >= 0 {
+			c.trips--
+			c.earnings -= counted
+		}
+	}
+	return nil
+}
@@ -3,7 +3,11 @@ package worker
 import (
 	"context"
 	"fmt"
+	"hash/fnv"
 	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type Mode uint
@@ -19,11 +23,23 @@ const (
 
 const defaultJobQueueSize = 10
 
+// maxHeldJobs is the number of jobs of paused topics a worker holds, a
+// worker holding as many takes no jobs until a topic resumes.
+const maxHeldJobs = 100
+
 // Job represents a task details for a worker.
 type Job struct {
-	Topic   string
+	Topic string
+	// Key is the ordering key of the job, usually the message key from the
+	// job listener. Jobs sharing the same key are processed one at a time
+	// in the order they were received.
+	Key     string
 	Payload []byte
 	Done    func() error
+
+	// Partition and Offset locates the job on the listener source.
+	Partition int32
+	Offset    int64
 }
 
 // JobHandler represents worker handler functions
@@ -32,6 +48,11 @@ type JobHandler func(context.Context, Job) error
 // MiddlewareFunc represents worker job middleware
 type MiddlewareFunc func(JobHandler) JobHandler
 
+// KeyFunc returns the ordering key of a job, overriding the key set by the
+// job listener. ex. trip events are keyed by trip id but has to be ordered
+// by driver id.
+type KeyFunc func(Job) string
+
 // Worker represents a worker that waits for a job and process base on handler.
 type Worker struct {
 	Mode Mode
@@ -39,10 +60,26 @@ type Worker struct {
 	queue       chan Job
 	quit        chan struct{}
 	router      map[string]JobHandler
+	keyFuncs    map[string]KeyFunc
 	middlewares []MiddlewareFunc
 	listener    jobListener
 	schedules   []Schedule
+	locker      scheduleLocker
+	leaseTTL    time.Duration
+	holder      string
+	recorder    runRecorder
 	logger      *slog.Logger
+
+	// shards holds a dedicated job queue per worker, jobs are assigned to
+	// a shard by key to preserve ordering of the same key.
+	shards []chan Job
+	// rr is the round-robin counter for jobs without key.
+	rr atomic.Uint64
+
+	mu       sync.Mutex
+	topics   map[string]*TopicStats
+	inFlight map[int]InFlightJob
+	resumed  chan struct{}
 }
 
 // jobListener provides access to job producers.
@@ -65,10 +102,15 @@ func New(listener jobListener, queueSize int, logger *slog.Logger) *Worker {
 	return &Worker{
 		Mode:     ModeAll,
 		queue:    make(chan Job, queueSize),
-		quit:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
 		router:   map[string]JobHandler{},
+		keyFuncs: map[string]KeyFunc{},
 		listener: listener,
 		logger:   logger,
+		topics:   map[string]*TopicStats{},
+		inFlight: map[int]InFlightJob{},
+		resumed:  make(chan struct{}),
+		holder:   leaseHolder(),
 	}
 }
 
@@ -97,6 +139,8 @@ func (w *Worker) Stop() error {
 		return fmt.Errorf("listener close: %s", err)
 	}
 
+	// Releases workers waiting for paused topics.
+	close(w.quit)
 	close(w.queue)
 
 	return nil
@@ -107,9 +151,18 @@ func (w *Worker) HandleFunc(topic string, f JobHandler) {
 	w.router[topic] = f
 }
 
+// KeyBy registers key function by topic that overrides the job ordering key.
+func (w *Worker) KeyBy(topic string, f KeyFunc) {
+	w.keyFuncs[topic] = f
+}
+
 func (w *Worker) SetSchedule(s Schedule) {
+	if s.Name == "" {
+		s.Name = fmt.Sprintf("schedule-%d", len(w.schedules))
+	}
 	s.logger = w.logger
 	s.done = make(chan struct{}, 1)
+	s.state = &scheduleState{stats: ScheduleStats{Name: s.Name, Spec: s.String()}}
 	w.schedules = append(w.schedules, s)
 }
 
@@ -131,51 +184,160 @@ func (w *Worker) runConsumers() error {
 		return err
 	}
 
-	// Process job received from the job listener.
-	for x := 1; x <= cap(w.queue); x++ {
-		workerID := x // prevent un-predictable values of x when used on go-routine function body.
-		go func() {
-			ctx, cancel := context.WithCancel(context.Background())
-			defer cancel()
-			for {
-				job, ok := <-w.queue
-				if !ok {
-					cancel()
-					stop()
-					w.logger.Info("worker stopped and job queue closed", "worker_id", workerID)
-					return
-				}
-				w.logger.Info("worker received job", "worker_id", workerID)
+	// Process job received from the job listener, each worker owns a shard
+	// so jobs with the same key never run concurrently or out of order.
+	var wg sync.WaitGroup
+	w.shards = make([]chan Job, cap(w.queue))
+	for x := range w.shards {
+		w.shards[x] = make(chan Job, 1)
+		wg.Add(1)
+		go func(workerID int, shard <-chan Job) {
+			defer wg.Done()
+			w.process(workerID, shard)
+		}(x+1, w.shards[x])
+	}
 
-				handle, ok := w.router[job.Topic]
-				if !ok {
-					w.logger.Debug("topic not handled", "topic", job.Topic, "worker_id", workerID)
-					continue
-				}
+	// Dispatch jobs to shards until the job queue is closed.
+	go func() {
+		for job := range w.queue {
+			job.Key = w.jobKey(job)
+			w.shards[w.shardOf(job.Key)] <- job
+		}
+		for _, s := range w.shards {
+			close(s)
+		}
+		wg.Wait()
+		stop()
+		w.logger.Info("workers stopped and job queue closed")
+	}()
 
-				// Execute middlewares on router job handler.
-				for _, m := range w.middlewares {
-					handle = m(handle)
-				}
+	w.logger.Info("worker running", "queue_size", cap(w.queue))
+	return nil
+}
 
-				if err = handle(ctx, job); err != nil {
-					continue
-				}
-				if err = job.Done(); err != nil {
-					w.logger.Error("job done", "err", err, "topic", job.Topic, "worker_id", workerID)
+func (w *Worker) process(workerID int, shard <-chan Job) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// held queues jobs of paused topics in order, held jobs are processed
+	// first when the topic resumes. Listeners that pause at the source stop
+	// sending jobs of paused topics, others are held up to maxHeldJobs.
+	held := map[string][]Job{}
+	stopping := false
+	for {
+		// Resume signal is taken before releasing so a resume in between
+		// is not missed.
+		resumed := w.resumedC()
+		n := 0
+		for topic := range held {
+			w.releaseHeld(ctx, workerID, held, topic)
+			n += len(held[topic])
+		}
+
+		in, quit := shard, (<-chan struct{})(nil)
+		if n >= maxHeldJobs && !stopping {
+			w.logger.Warn("worker holds too many jobs of paused topics, waiting for resume", "worker_id", workerID, "held", n)
+			in, quit = nil, w.quit
+		}
+
+		select {
+		case job, ok := <-in:
+			if !ok {
+				w.logger.Info("worker stopped", "worker_id", workerID)
+				return
+			}
+			if w.isPaused(job.Topic) {
+				// Jobs not committed are received again after restart.
+				if stopping {
 					continue
 				}
+				held[job.Topic] = append(held[job.Topic], job)
+				w.holdJob(job.Topic, 1)
+				continue
+			}
+			w.releaseHeld(ctx, workerID, held, job.Topic)
+			w.handle(ctx, workerID, job)
+
+		case <-resumed:
+		case <-quit:
+			for topic, jobs := range held {
+				w.holdJob(topic, -len(jobs))
 			}
-		}()
+			held = map[string][]Job{}
+			stopping = true
+		}
 	}
+}
 
-	w.logger.Info("worker running", "queue_size", cap(w.queue))
-	return nil
+// releaseHeld processes held jobs of topic when it is no longer paused.
+func (w *Worker) releaseHeld(ctx context.Context, workerID int, held map[string][]Job, topic string) {
+	jobs := held[topic]
+	if len(jobs) == 0 || w.isPaused(topic) {
+		return
+	}
+	delete(held, topic)
+	w.holdJob(topic, -len(jobs))
+	for _, job := range jobs {
+		w.handle(ctx, workerID, job)
+	}
+}
+
+func (w *Worker) handle(ctx context.Context, workerID int, job Job) {
+	w.logger.Info("worker received job", "worker_id", workerID, "key", job.Key)
+
+	handle, ok := w.router[job.Topic]
+	if !ok {
+		w.logger.Debug("topic not handled", "topic", job.Topic, "worker_id", workerID)
+		return
+	}
+
+	// Execute middlewares on router job handler.
+	for _, m := range w.middlewares {
+		handle = m(handle)
+	}
+
+	w.startJob(workerID, job)
+	err := handle(ctx, job)
+	committed := false
+	if err == nil {
+		if derr := job.Done(); derr != nil {
+			w.logger.Error("job done", "err", derr, "topic", job.Topic, "worker_id", workerID)
+		} else {
+			committed = true
+		}
+	}
+	w.finishJob(workerID, job, err, committed)
+}
+
+// jobKey returns the ordering key of the job from topic key function
+// and falls back to the key set by the job listener.
+func (w *Worker) jobKey(job Job) string {
+	fn, ok := w.keyFuncs[job.Topic]
+	if !ok {
+		return job.Key
+	}
+	if k := fn(job); k != "" {
+		return k
+	}
+	return job.Key
+}
+
+// shardOf returns the shard index of a key, jobs without key are
+// distributed round-robin since they have no ordering guarantee.
+func (w *Worker) shardOf(key string) int {
+	n := uint64(len(w.shards))
+	if key == "" {
+		return int(w.rr.Add(1) % n)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(uint64(h.Sum32()) % n)
 }
 
 func (w *Worker) runSchedulers() error {
 	// Start running scheduled tasks.
 	for _, s := range w.schedules {
+		s.locker, s.leaseTTL, s.holder, s.recorder = w.locker, w.leaseTTL, w.holder, w.recorder
 		go func(s Schedule) {
 			if err := s.run(context.Background()); err != nil {
 				w.logger.Error("schedule run", "err", err)
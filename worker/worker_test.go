@@ -0,0 +1,242 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.angkas.com/avengers/microservice/incentive-service/internal/trip"
+)
+
+func TestWorker_perKeyOrder(t *testing.T) {
+	const keys, jobsPerKey = 5, 50
+
+	l := &mockListener{}
+	w := New(l, 4, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var mu sync.Mutex
+	got := map[string][]int{}
+	active := map[string]bool{}
+	var wg sync.WaitGroup
+	wg.Add(keys * jobsPerKey)
+	w.HandleFunc("test", func(ctx context.Context, job Job) error {
+		mu.Lock()
+		if active[job.Key] {
+			t.Errorf("key %s processed concurrently", job.Key)
+		}
+		active[job.Key] = true
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		var seq int
+		fmt.Sscanf(string(job.Payload), "%d", &seq)
+		mu.Lock()
+		got[job.Key] = append(got[job.Key], seq)
+		active[job.Key] = false
+		mu.Unlock()
+		return nil
+	})
+	if err := w.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for i := 0; i < jobsPerKey; i++ {
+		for k := 0; k < keys; k++ {
+			l.queue <- Job{
+				Topic:   "test",
+				Key:     fmt.Sprintf("key-%d", k),
+				Payload: []byte(fmt.Sprint(i)),
+				Done:    func() error { wg.Done(); return nil },
+			}
+		}
+	}
+	wg.Wait()
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	for k, seqs := range got {
+		if len(seqs) != jobsPerKey {
+			t.Errorf("key %s processed %d jobs, want %d", k, len(seqs), jobsPerKey)
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Errorf("key %s job %d processed out of order: %v", k, i, seqs)
+				break
+			}
+		}
+	}
+}
+
+func TestWorker_KeyBy(t *testing.T) {
+	w := New(&mockListener{}, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	w.KeyBy("trips", TripDriverKey(trip.NewDecoder(nil)))
+
+	tests := []struct {
+		name string
+		job  Job
+		want string
+	}{
+		{"driver key", Job{Topic: "trips", Key: "trip-1", Payload: []byte(`{"driver_id":"driver-1"}`)}, "driver-1"},
+		{"envelope driver key", Job{Topic: "trips", Key: "trip-1", Payload: []byte(`{"type":"trip.status_changed","version":1,"data":{"driver_id":"driver-2"}}`)}, "driver-2"},
+		{"fallback to listener key", Job{Topic: "trips", Key: "trip-1", Payload: []byte(`{}`)}, "trip-1"},
+		{"no key func", Job{Topic: "other", Key: "msg-1", Payload: []byte(`{"driver_id":"driver-1"}`)}, "msg-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.jobKey(tt.job); got != tt.want {
+				t.Errorf("jobKey() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+type mockListener struct {
+	queue chan<- Job
+}
+
+func (m *mockListener) Listen(topics []string, q chan<- Job) (stop func(), err error) {
+	m.queue = q
+	return func() {}, nil
+}
+
+func (m *mockListener) Close() error {
+	return nil
+}
+
+func TestWorker_PauseResume(t *testing.T) {
+	l := &mockListener{}
+	w := New(l, 2, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	handled := make(chan string, 10)
+	w.HandleFunc("trips", func(ctx context.Context, job Job) error {
+		handled <- string(job.Payload)
+		return nil
+	})
+	w.HandleFunc("other", func(ctx context.Context, job Job) error {
+		handled <- string(job.Payload)
+		return nil
+	})
+	if err := w.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err := w.Pause("unknown"); err == nil {
+		t.Error("Pause() unknown topic error = nil")
+	}
+	if err := w.Pause("trips"); err != nil {
+		t.Fatalf("Pause() error = %v", err)
+	}
+
+	done := func() error { return nil }
+	l.queue <- Job{Topic: "trips", Key: "a", Payload: []byte("trip-1"), Offset: 1, Done: done}
+	l.queue <- Job{Topic: "other", Key: "a", Payload: []byte("other-1"), Offset: 7, Done: done}
+	if got := <-handled; got != "other-1" {
+		t.Fatalf("handled %s, want other topic to continue while trips paused", got)
+	}
+
+	waitStats(t, w, func(s Stats) bool { return s.Topics["trips"].Held == 1 })
+	l.queue <- Job{Topic: "trips", Key: "a", Payload: []byte("trip-2"), Offset: 2, Done: done}
+	waitStats(t, w, func(s Stats) bool { return s.Topics["trips"].Held == 2 })
+
+	if err := w.Resume("trips"); err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	for _, want := range []string{"trip-1", "trip-2"} {
+		if got := <-handled; got != want {
+			t.Errorf("handled %s, want %s", got, want)
+		}
+	}
+
+	// Offsets not committed are not reported.
+	l.queue <- Job{Topic: "other", Key: "b", Payload: []byte("other-2"), Offset: 8, Done: func() error { return errors.New("commit failed") }}
+	if got := <-handled; got != "other-2" {
+		t.Fatalf("handled %s, want other-2", got)
+	}
+
+	waitStats(t, w, func(s Stats) bool { return s.Topics["trips"].Succeeded == 2 && s.Topics["other"].Succeeded == 2 })
+	s := w.Stats()
+	if ts := s.Topics["trips"]; ts.Paused || ts.Held != 0 || ts.LastOffsets[0] != 2 {
+		t.Errorf("trips stats = %+v", ts)
+	}
+	if ts := s.Topics["other"]; ts.LastOffsets[0] != 7 {
+		t.Errorf("other stats = %+v", ts)
+	}
+	if err := w.Stop(); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+}
+
+func TestWorker_PauseHoldsUpToMax(t *testing.T) {
+	for _, resume := range []bool{true, false} {
+		l := &mockListener{}
+		w := New(l, 1, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		handled := make(chan string, maxHeldJobs+1)
+		w.HandleFunc("trips", func(ctx context.Context, job Job) error {
+			handled <- job.Topic
+			return nil
+		})
+		w.HandleFunc("other", func(ctx context.Context, job Job) error {
+			handled <- job.Topic
+			return nil
+		})
+		if err := w.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if err := w.Pause("trips"); err != nil {
+			t.Fatalf("Pause() error = %v", err)
+		}
+
+		done := func() error { return nil }
+		for i := 0; i < maxHeldJobs; i++ {
+			l.queue <- Job{Topic: "trips", Key: "a", Offset: int64(i), Done: done}
+		}
+		waitStats(t, w, func(s Stats) bool { return s.Topics["trips"].Held == maxHeldJobs })
+		// The worker takes no jobs of other topics while holding the max.
+		l.queue <- Job{Topic: "other", Key: "a", Done: done}
+		select {
+		case got := <-handled:
+			t.Fatalf("handled %s, want none while holding max jobs", got)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		if !resume {
+			// Stopping drops the held jobs.
+			if err := w.Stop(); err != nil {
+				t.Fatalf("Stop() error = %v", err)
+			}
+			waitStats(t, w, func(s Stats) bool { return s.Topics["trips"].Held == 0 })
+			continue
+		}
+		if err := w.Resume("trips"); err != nil {
+			t.Fatalf("Resume() error = %v", err)
+		}
+		for i := 0; i < maxHeldJobs; i++ {
+			if got := <-handled; got != "trips" {
+				t.Fatalf("handled %s at %d, want held trips first", got, i)
+			}
+		}
+		if got := <-handled; got != "other" {
+			t.Errorf("handled %s, want other", got)
+		}
+		if err := w.Stop(); err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+	}
+}
+
+func waitStats(t *testing.T, w *Worker, cond func(Stats) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond(w.Stats()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for stats: %+v", w.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}